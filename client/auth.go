@@ -0,0 +1,132 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Errors that end a device login.
+var (
+	ErrAccessDenied      = errors.New("login was denied")
+	ErrDeviceCodeExpired = errors.New("device code expired")
+)
+
+// DeviceCode is a pending device login. Show the user UserCode and
+// VerificationURI, then call PollDeviceLogin.
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+// Token is the result of a login. RefreshToken is empty when the approving
+// browser session had none.
+type Token struct {
+	IDToken      string
+	RefreshToken string
+	ExpiresAt    time.Time
+}
+
+// StartDeviceLogin asks the users service for a device code. It needs no
+// credentials.
+func (c *Client) StartDeviceLogin(ctx context.Context) (*DeviceCode, error) {
+	var code DeviceCode
+	if err := c.do(ctx, http.MethodPost, "users/device/code", nil, nil, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// PollDeviceLogin waits until the user approves or denies the login in their
+// browser, the code expires or ctx is done.
+func (c *Client) PollDeviceLogin(ctx context.Context, code *DeviceCode) (*Token, error) {
+	interval := time.Duration(max(code.Interval, 1)) * time.Second
+	expires := time.Now().Add(time.Duration(code.ExpiresIn) * time.Second)
+
+	timer := time.NewTimer(interval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		var resp struct {
+			IDToken      string `json:"id_token"`
+			RefreshToken string `json:"refresh_token"`
+			ExpiresIn    int    `json:"expires_in"`
+		}
+		err := c.do(ctx, http.MethodPost, "users/device/token", nil, map[string]string{"device_code": code.DeviceCode}, &resp)
+		if err == nil {
+			return &Token{
+				IDToken:      resp.IDToken,
+				RefreshToken: resp.RefreshToken,
+				ExpiresAt:    time.Now().Add(time.Duration(resp.ExpiresIn) * time.Second),
+			}, nil
+		}
+
+		var apiErr *APIError
+		if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+			return nil, err
+		}
+		var body struct {
+			Error string `json:"error"`
+		}
+		json.Unmarshal([]byte(apiErr.Message), &body)
+
+		switch body.Error {
+		case "authorization_pending":
+		case "slow_down":
+			interval += 5 * time.Second
+		case "access_denied":
+			return nil, ErrAccessDenied
+		case "expired_token", "invalid_grant":
+			return nil, ErrDeviceCodeExpired
+		default:
+			return nil, err
+		}
+
+		if time.Now().Add(interval).After(expires) {
+			return nil, ErrDeviceCodeExpired
+		}
+		timer.Reset(interval)
+	}
+}
+
+// RefreshIDToken trades a refresh token for a new ID token.
+func (c *Client) RefreshIDToken(ctx context.Context, refreshToken string) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodPost, "users/refresh", nil, nil)
+	if err != nil {
+		return "", err
+	}
+	req.AddCookie(&http.Cookie{Name: "refresh_token", Value: refreshToken})
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", newAPIError(req, resp)
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	// The users service hands the new token back as a cookie, like to the web app
+	for _, cookie := range resp.Cookies() {
+		if cookie.Name == "id_token" && cookie.Value != "" {
+			return cookie.Value, nil
+		}
+	}
+	return "", fmt.Errorf("%s %s: no id_token in response", req.Method, req.URL.Path)
+}
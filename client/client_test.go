@@ -0,0 +1,570 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+const testToken = "test-id-token"
+
+// fakeAPI is a small in-memory stand-in for the tasks and users services,
+// answering the way the real handlers do.
+type fakeAPI struct {
+	mu     sync.Mutex
+	tasks  []Task
+	nextID int
+	user   User
+
+	// requests records "METHOD path?query" for every call
+	requests []string
+}
+
+func newFakeAPI(t *testing.T) (*fakeAPI, *httptest.Server) {
+	api := &fakeAPI{user: User{UserID: "user-1", Email: "ana@example.com", Timezone: "UTC", DigestFrequency: "NONE"}}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks/read", api.list)
+	mux.HandleFunc("POST /api/tasks/create", api.create)
+	mux.HandleFunc("POST /api/tasks/quick", api.quick)
+	mux.HandleFunc("PUT /api/tasks/update/{id}", api.update)
+	mux.HandleFunc("DELETE /api/tasks/delete/{id}", api.delete)
+	mux.HandleFunc("GET /api/users/me", api.me)
+	mux.HandleFunc("PATCH /api/users/me", api.updateMe)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		api.mu.Lock()
+		api.requests = append(api.requests, r.Method+" "+r.URL.RequestURI())
+		api.mu.Unlock()
+
+		if !authorized(r) {
+			http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return api, srv
+}
+
+func authorized(r *http.Request) bool {
+	if r.Header.Get("Authorization") == "Bearer "+testToken || r.Header.Get("X-User-ID") == "user-1" {
+		return true
+	}
+	cookie, err := r.Cookie("id_token")
+	return err == nil && cookie.Value == testToken
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// taskFromInput decodes a request body the way the service's TaskRequest does.
+func taskFromInput(r *http.Request) (Task, bool) {
+	var req struct {
+		Title    string   `json:"title"`
+		Deadline *string  `json:"deadline"`
+		AllDay   bool     `json:"all_day"`
+		Status   Status   `json:"status"`
+		Priority Priority `json:"priority"`
+		Labels   []string `json:"labels"`
+		Project  *string  `json:"project"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return Task{}, false
+	}
+
+	task := Task{Title: req.Title, Status: req.Status, Priority: req.Priority, Labels: req.Labels}
+	if req.Project != nil {
+		task.Project = *req.Project
+	}
+	if req.Deadline != nil {
+		if d, err := time.Parse(time.RFC3339, *req.Deadline); err == nil {
+			task.Deadline = &d
+		} else if d, err := time.Parse(time.DateOnly, *req.Deadline); err == nil {
+			task.Deadline, task.AllDay = &d, true
+		} else {
+			return Task{}, false
+		}
+	}
+	return task, true
+}
+
+func (api *fakeAPI) list(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+	page, limit = max(page, 1), max(limit, 1)
+	if r.URL.Query().Get("limit") == "" {
+		limit = 10
+	}
+
+	var matching []Task
+	for _, task := range api.tasks {
+		if status := r.URL.Query().Get("status"); status != "" && string(task.Status) != status {
+			continue
+		}
+		matching = append(matching, task)
+	}
+
+	start := min((page-1)*limit, len(matching))
+	end := min(start+limit, len(matching))
+	writeJSON(w, http.StatusOK, map[string]any{"tasks": matching[start:end], "total": len(matching)})
+}
+
+func (api *fakeAPI) create(w http.ResponseWriter, r *http.Request) {
+	task, ok := taskFromInput(r)
+	if !ok {
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	api.nextID++
+	task.TaskID = fmt.Sprintf("task-%d", api.nextID)
+	task.UserID = "user-1"
+	api.tasks = append(api.tasks, task)
+	writeJSON(w, http.StatusCreated, task)
+}
+
+func (api *fakeAPI) quick(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Text string `json:"text"`
+	}
+	json.NewDecoder(r.Body).Decode(&req)
+	if req.Text == "" {
+		http.Error(w, "Title must not be empty", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, Task{TaskID: "quick", Title: req.Text, Status: StatusTodo, Priority: PriorityMedium})
+}
+
+func (api *fakeAPI) update(w http.ResponseWriter, r *http.Request) {
+	in, ok := taskFromInput(r)
+	if !ok {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i := range api.tasks {
+		if api.tasks[i].TaskID == r.PathValue("id") {
+			in.TaskID, in.UserID = api.tasks[i].TaskID, api.tasks[i].UserID
+			api.tasks[i] = in
+			writeJSON(w, http.StatusOK, in)
+			return
+		}
+	}
+	http.Error(w, "Task not found", http.StatusNotFound)
+}
+
+func (api *fakeAPI) delete(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	for i := range api.tasks {
+		if api.tasks[i].TaskID == r.PathValue("id") {
+			api.tasks = append(api.tasks[:i], api.tasks[i+1:]...)
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+	}
+	http.Error(w, "Task not found", http.StatusNotFound)
+}
+
+func (api *fakeAPI) me(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	writeJSON(w, http.StatusOK, api.user)
+}
+
+func (api *fakeAPI) updateMe(w http.ResponseWriter, r *http.Request) {
+	var update ProfileUpdate
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	api.mu.Lock()
+	defer api.mu.Unlock()
+	if update.DigestHour != nil {
+		if *update.DigestHour < 0 || *update.DigestHour > 23 {
+			http.Error(w, "Invalid digest hour", http.StatusBadRequest)
+			return
+		}
+		api.user.DigestHour = *update.DigestHour
+	}
+	if update.Timezone != nil {
+		api.user.Timezone = *update.Timezone
+	}
+	writeJSON(w, http.StatusOK, api.user)
+}
+
+func newTestClient(t *testing.T, srv *httptest.Server, opts ...Option) *Client {
+	if len(opts) == 0 {
+		opts = []Option{WithBearerToken(testToken)}
+	}
+	c, err := New(srv.URL+"/api", opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestAuthentication(t *testing.T) {
+	_, srv := newFakeAPI(t)
+	ctx := context.Background()
+
+	for name, opt := range map[string]Option{
+		"bearer":  WithBearerToken(testToken),
+		"cookie":  WithCookieToken(testToken),
+		"user id": WithUserID("user-1"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := newTestClient(t, srv, opt).ListTasks(ctx, ListOptions{}); err != nil {
+				t.Fatalf("ListTasks: %v", err)
+			}
+		})
+	}
+
+	_, err := newTestClient(t, srv, WithBearerToken("wrong")).ListTasks(ctx, ListOptions{})
+	if !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("error = %v, want ErrUnauthorized", err)
+	}
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "Unauthorized User" {
+		t.Fatalf("unexpected API error %#v", apiErr)
+	}
+}
+
+func TestTaskLifecycle(t *testing.T) {
+	api, srv := newFakeAPI(t)
+	c := newTestClient(t, srv)
+	ctx := context.Background()
+
+	deadline := time.Date(2026, time.November, 3, 23, 30, 0, 0, time.FixedZone("Lisbon", 0))
+	project := "Home"
+	created, err := c.CreateTask(ctx, TaskInput{
+		Title:    "Pay rent",
+		Status:   StatusTodo,
+		Priority: PriorityHigh,
+		Deadline: &deadline,
+		AllDay:   true,
+		Labels:   []string{"finance"},
+		Project:  &project,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if created.TaskID == "" || created.Title != "Pay rent" || created.Priority != PriorityHigh || created.Project != "Home" {
+		t.Fatalf("unexpected task %+v", created)
+	}
+	if created.DeadlineDate() != "2026-11-03" {
+		t.Fatalf("DeadlineDate = %q, want 2026-11-03", created.DeadlineDate())
+	}
+
+	updated, err := c.UpdateTask(ctx, created.TaskID, TaskInput{Title: "Pay rent", Status: StatusDone, Priority: PriorityHigh})
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if updated.Status != StatusDone || updated.Deadline != nil {
+		t.Fatalf("unexpected task %+v", updated)
+	}
+
+	if err := c.DeleteTask(ctx, created.TaskID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if len(api.tasks) != 0 {
+		t.Fatalf("task wasn't deleted: %+v", api.tasks)
+	}
+
+	if err := c.DeleteTask(ctx, created.TaskID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("second DeleteTask error = %v, want ErrNotFound", err)
+	}
+	if _, err := c.UpdateTask(ctx, "missing", TaskInput{Title: "x"}); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("UpdateTask error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestTaskInputEncoding(t *testing.T) {
+	loc := time.FixedZone("UTC+9", 9*60*60)
+	deadline := time.Date(2026, time.March, 1, 8, 15, 0, 0, loc)
+
+	for _, tt := range []struct {
+		allDay bool
+		want   string
+	}{
+		{false, "2026-03-01T08:15:00+09:00"},
+		{true, "2026-03-01"},
+	} {
+		data, err := json.Marshal(TaskInput{Title: "x", Deadline: &deadline, AllDay: tt.allDay})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body map[string]any
+		json.Unmarshal(data, &body)
+		if body["deadline"] != tt.want || body["all_day"] != tt.allDay {
+			t.Errorf("all_day=%v: got deadline %v, want %s", tt.allDay, body["deadline"], tt.want)
+		}
+	}
+
+	data, _ := json.Marshal(TaskInput{Title: "x"})
+	var body map[string]any
+	json.Unmarshal(data, &body)
+	if v, ok := body["deadline"]; !ok || v != nil {
+		t.Errorf("deadline = %v, want null", v)
+	}
+	// A nil project keeps the task's project, an empty one clears it
+	if v, ok := body["project"]; !ok || v != nil {
+		t.Errorf("project = %v, want null", v)
+	}
+	project := ""
+	data, _ = json.Marshal(TaskInput{Title: "x", Project: &project})
+	body = nil
+	json.Unmarshal(data, &body)
+	if v, ok := body["project"]; !ok || v != "" {
+		t.Errorf("cleared project = %v, want \"\"", v)
+	}
+
+	in := Task{Title: "x", Project: "Website"}.Input()
+	if in.Project == nil || *in.Project != "Website" {
+		t.Errorf("Input() project = %v, want Website", in.Project)
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	api, srv := newFakeAPI(t)
+	c := newTestClient(t, srv)
+
+	_, err := c.ListTasks(context.Background(), ListOptions{
+		Status:     StatusTodo,
+		Priority:   PriorityLow,
+		Label:      "home",
+		Due:        DueOverdue,
+		Archive:    ArchiveOnly,
+		Sort:       "deadline",
+		Descending: true,
+		Page:       2,
+		PageSize:   5,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := "GET /api/tasks/read?archive=only&due=overdue&label=home&limit=5&order=desc&page=2&priority=LOW&sort=deadline&status=TODO"
+	if got := api.requests[len(api.requests)-1]; got != want {
+		t.Errorf("request\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestAllTasksPaginates(t *testing.T) {
+	api, srv := newFakeAPI(t)
+	c := newTestClient(t, srv)
+	ctx := context.Background()
+
+	for i := range 7 {
+		status := StatusTodo
+		if i%3 == 0 {
+			status = StatusDone
+		}
+		if _, err := c.CreateTask(ctx, TaskInput{Title: fmt.Sprintf("task %d", i), Status: status, Priority: PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	api.requests = nil
+
+	var titles []string
+	for task, err := range c.AllTasks(ctx, ListOptions{Status: StatusTodo, PageSize: 2}) {
+		if err != nil {
+			t.Fatal(err)
+		}
+		titles = append(titles, task.Title)
+	}
+
+	want := []string{"task 1", "task 2", "task 4", "task 5"}
+	if fmt.Sprint(titles) != fmt.Sprint(want) {
+		t.Errorf("titles = %v, want %v", titles, want)
+	}
+	if len(api.requests) != 2 {
+		t.Errorf("made %d requests, want 2: %v", len(api.requests), api.requests)
+	}
+
+	// Stopping early doesn't fetch further pages
+	api.requests = nil
+	for range c.AllTasks(ctx, ListOptions{PageSize: 2}) {
+		break
+	}
+	if len(api.requests) != 1 {
+		t.Errorf("made %d requests after break, want 1", len(api.requests))
+	}
+}
+
+func TestAllTasksStopsOnError(t *testing.T) {
+	_, srv := newFakeAPI(t)
+	c := newTestClient(t, srv, WithBearerToken("expired"))
+
+	n := 0
+	for _, err := range c.AllTasks(context.Background(), ListOptions{}) {
+		n++
+		if !errors.Is(err, ErrUnauthorized) {
+			t.Fatalf("error = %v, want ErrUnauthorized", err)
+		}
+	}
+	if n != 1 {
+		t.Fatalf("yielded %d times, want 1", n)
+	}
+}
+
+func TestQuickAdd(t *testing.T) {
+	_, srv := newFakeAPI(t)
+	c := newTestClient(t, srv)
+
+	task, err := c.QuickAdd(context.Background(), "Pay rent tomorrow !high")
+	if err != nil || task.Title != "Pay rent tomorrow !high" {
+		t.Fatalf("QuickAdd = %+v, %v", task, err)
+	}
+
+	_, err = c.QuickAdd(context.Background(), "")
+	var apiErr *APIError
+	if !errors.Is(err, ErrInvalidInput) || !errors.As(err, &apiErr) || apiErr.Message != "Title must not be empty" {
+		t.Fatalf("error = %v, want ErrInvalidInput with the service's message", err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	_, srv := newFakeAPI(t)
+	c := newTestClient(t, srv)
+	ctx := context.Background()
+
+	user, err := c.Me(ctx)
+	if err != nil || user.Email != "ana@example.com" {
+		t.Fatalf("Me = %+v, %v", user, err)
+	}
+
+	user, err = c.UpdateMe(ctx, ProfileUpdate{Timezone: Ptr("Europe/Lisbon"), DigestHour: Ptr(7)})
+	if err != nil || user.Timezone != "Europe/Lisbon" || user.DigestHour != 7 {
+		t.Fatalf("UpdateMe = %+v, %v", user, err)
+	}
+
+	if _, err := c.UpdateMe(ctx, ProfileUpdate{DigestHour: Ptr(25)}); !errors.Is(err, ErrInvalidInput) {
+		t.Fatalf("error = %v, want ErrInvalidInput", err)
+	}
+}
+
+func TestErrorMapping(t *testing.T) {
+	for status, want := range map[int]error{
+		http.StatusBadRequest:            ErrInvalidInput,
+		http.StatusUnauthorized:          ErrUnauthorized,
+		http.StatusNotFound:              ErrNotFound,
+		http.StatusConflict:              ErrConflict,
+		http.StatusRequestEntityTooLarge: ErrTooLarge,
+		http.StatusUnsupportedMediaType:  ErrUnsupportedMedia,
+		http.StatusInternalServerError:   ErrServer,
+		http.StatusBadGateway:            ErrServer,
+		http.StatusTeapot:                ErrUnexpectedStatus,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "nope", status)
+		}))
+		c := newTestClient(t, srv)
+		err := c.DeleteTask(context.Background(), "x")
+		srv.Close()
+
+		if !errors.Is(err, want) {
+			t.Errorf("status %d: error = %v, want %v", status, err, want)
+		}
+	}
+}
+
+func TestNewRejectsBadBaseURL(t *testing.T) {
+	for _, base := range []string{"", "tasknest.example.com", "ftp://example.com"} {
+		if _, err := New(base); err == nil {
+			t.Errorf("New(%q) succeeded", base)
+		}
+	}
+}
+
+func TestDeviceLogin(t *testing.T) {
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/users/device/code":
+			writeJSON(w, http.StatusOK, DeviceCode{DeviceCode: "secret", UserCode: "BCDF-GHJK", ExpiresIn: 600, Interval: 1})
+		case "/api/users/device/token":
+			var req struct {
+				DeviceCode string `json:"device_code"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			if req.DeviceCode == "denied" {
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "access_denied"})
+				return
+			}
+			polls++
+			switch {
+			case polls == 1:
+				writeJSON(w, http.StatusBadRequest, map[string]string{"error": "authorization_pending"})
+			default:
+				writeJSON(w, http.StatusOK, map[string]any{"id_token": "id", "refresh_token": "refresh", "expires_in": 3600})
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv)
+	ctx := context.Background()
+
+	code, err := c.StartDeviceLogin(ctx)
+	if err != nil || code.UserCode != "BCDF-GHJK" {
+		t.Fatalf("StartDeviceLogin = %+v, %v", code, err)
+	}
+
+	token, err := c.PollDeviceLogin(ctx, code)
+	if err != nil {
+		t.Fatalf("PollDeviceLogin: %v", err)
+	}
+	if token.IDToken != "id" || token.RefreshToken != "refresh" || time.Until(token.ExpiresAt) < 59*time.Minute {
+		t.Errorf("unexpected token %+v", token)
+	}
+	if polls != 2 {
+		t.Errorf("polled %d times, want 2", polls)
+	}
+
+	_, err = c.PollDeviceLogin(ctx, &DeviceCode{DeviceCode: "denied", ExpiresIn: 600, Interval: 1})
+	if !errors.Is(err, ErrAccessDenied) {
+		t.Errorf("error = %v, want ErrAccessDenied", err)
+	}
+}
+
+func TestRefreshIDToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("refresh_token"); err != nil || cookie.Value != "refresh" {
+			http.Error(w, "Refresh token missing", http.StatusUnauthorized)
+			return
+		}
+		http.SetCookie(w, &http.Cookie{Name: "id_token", Value: "fresh", HttpOnly: true, Secure: true})
+		fmt.Fprint(w, "Token refreshed successfully!")
+	}))
+	defer srv.Close()
+	c := newTestClient(t, srv)
+
+	token, err := c.RefreshIDToken(context.Background(), "refresh")
+	if err != nil || token != "fresh" {
+		t.Fatalf("RefreshIDToken = %q, %v", token, err)
+	}
+	if _, err := c.RefreshIDToken(context.Background(), "stale"); !errors.Is(err, ErrUnauthorized) {
+		t.Fatalf("error = %v, want ErrUnauthorized", err)
+	}
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Completion scripts are generated from the command table, so new commands
+// and flags complete without touching this file.
+
+var shells = []string{"bash", "zsh", "fish"}
+
+// flagValues lists what a flag's value can complete to, if it's a fixed set.
+func flagValues(cmd, name string) []string {
+	switch name {
+	case "output", "o":
+		return []string{"table", "json"}
+	case "status":
+		return statusValues
+	case "priority":
+		return priorityValues
+	case "archive":
+		return archiveValues
+	case "sort":
+		return sortValues
+	case "format":
+		return formatValues
+	case "due":
+		if cmd == "ls" {
+			return dueValues
+		}
+		return []string{"today", "tomorrow"}
+	}
+	return nil
+}
+
+type completionFlag struct {
+	Name   string
+	Usage  string
+	IsBool bool
+}
+
+// commandFlags registers cmd's flags on a scratch FlagSet to list them.
+func commandFlags(cmd *command) []completionFlag {
+	c := &cli{stderr: io.Discard}
+	fs := c.newFlagSet(cmd)
+	cmd.Flags(fs)
+
+	var flags []completionFlag
+	fs.VisitAll(func(f *flag.Flag) {
+		b, ok := f.Value.(interface{ IsBoolFlag() bool })
+		flags = append(flags, completionFlag{Name: f.Name, Usage: f.Usage, IsBool: ok && b.IsBoolFlag()})
+	})
+	return flags
+}
+
+func commandNames() []string {
+	names := make([]string, 0, len(commands)+1)
+	for _, cmd := range commands {
+		names = append(names, cmd.Name)
+	}
+	return append(names, "help")
+}
+
+func writeBashCompletion(w io.Writer) {
+	fmt.Fprintf(w, `# bash completion for tasknest
+# Load with: source <(tasknest completion bash)
+
+_tasknest() {
+    local cur prev cmd
+    cur="${COMP_WORDS[COMP_CWORD]}"
+    prev="${COMP_WORDS[COMP_CWORD-1]}"
+
+    if [[ $COMP_CWORD -eq 1 ]]; then
+        COMPREPLY=($(compgen -W "%s" -- "$cur"))
+        return
+    fi
+    cmd="${COMP_WORDS[1]}"
+
+    case "$cmd:$prev" in
+`, strings.Join(commandNames(), " "))
+
+	for _, cmd := range commands {
+		for _, f := range commandFlags(&cmd) {
+			values := flagValues(cmd.Name, f.Name)
+			if values == nil {
+				continue
+			}
+			pattern := fmt.Sprintf("%s:-%s|%s:--%s", cmd.Name, f.Name, cmd.Name, f.Name)
+			if len(f.Name) == 1 {
+				pattern = fmt.Sprintf("%s:-%s", cmd.Name, f.Name)
+			}
+			fmt.Fprintf(w, "        %s)\n            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n            return ;;\n",
+				pattern, strings.Join(values, " "))
+		}
+	}
+	fmt.Fprintf(w, "    esac\n\n    case \"$cmd\" in\n")
+
+	for _, cmd := range commands {
+		if cmd.Name == "completion" {
+			fmt.Fprintf(w, "        completion)\n            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\"))\n            return ;;\n", strings.Join(shells, " "))
+			continue
+		}
+		var names []string
+		for _, f := range commandFlags(&cmd) {
+			if len(f.Name) > 1 {
+				names = append(names, "--"+f.Name)
+			}
+		}
+		fmt.Fprintf(w, "        %s)\n            COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", cmd.Name, strings.Join(names, " "))
+	}
+	fmt.Fprintf(w, "    esac\n}\n\ncomplete -F _tasknest tasknest\n")
+}
+
+func writeZshCompletion(w io.Writer) {
+	fmt.Fprintf(w, "#compdef tasknest\n# zsh completion for tasknest, via zsh's bash completion support\n# Load with: source <(tasknest completion zsh)\n\nautoload -U +X bashcompinit && bashcompinit\n\n")
+	writeBashCompletion(w)
+}
+
+// fishQuote quotes s for a fish script.
+func fishQuote(s string) string {
+	return "'" + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), "'", `\'`) + "'"
+}
+
+func writeFishCompletion(w io.Writer) {
+	fmt.Fprintf(w, "# fish completion for tasknest\n# Load with: tasknest completion fish | source\n\ncomplete -c tasknest -f\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "complete -c tasknest -n __fish_use_subcommand -a %s -d %s\n", cmd.Name, fishQuote(cmd.Summary))
+	}
+	fmt.Fprintf(w, "complete -c tasknest -n __fish_use_subcommand -a help -d 'Show usage'\n")
+	fmt.Fprintf(w, "complete -c tasknest -n '__fish_seen_subcommand_from completion' -a %s\n", fishQuote(strings.Join(shells, " ")))
+
+	for _, cmd := range commands {
+		for _, f := range commandFlags(&cmd) {
+			if len(f.Name) == 1 {
+				continue
+			}
+			line := fmt.Sprintf("complete -c tasknest -n '__fish_seen_subcommand_from %s' -l %s -d %s", cmd.Name, f.Name, fishQuote(f.Usage))
+			if !f.IsBool {
+				line += " -r"
+			}
+			if values := flagValues(cmd.Name, f.Name); values != nil {
+				line += " -a " + fishQuote(strings.Join(values, " "))
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+func completionCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("completion needs a shell: %s", strings.Join(shells, ", "))
+		}
+
+		switch args[0] {
+		case "bash":
+			writeBashCompletion(c.stdout)
+		case "zsh":
+			writeZshCompletion(c.stdout)
+		case "fish":
+			writeFishCompletion(c.stdout)
+		default:
+			return fmt.Errorf("unsupported shell %q, use %s", args[0], strings.Join(shells, ", "))
+		}
+		return nil
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/GoncaloMark/tasknest/client"
+)
+
+// config is what login leaves behind in the user's config directory.
+type config struct {
+	APIURL       string    `json:"api_url"`
+	IDToken      string    `json:"id_token,omitempty"`
+	RefreshToken string    `json:"refresh_token,omitempty"`
+	ExpiresAt    time.Time `json:"expires_at,omitempty"`
+}
+
+// refreshMargin renews tokens a little before they run out, so a request
+// doesn't race the expiry.
+const refreshMargin = time.Minute
+
+func configPath() (string, error) {
+	if path := os.Getenv("TASKNEST_CONFIG"); path != "" {
+		return path, nil
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "tasknest", "config.json"), nil
+}
+
+func loadConfig(path string) (*config, error) {
+	var cfg config
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return &cfg, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return &cfg, nil
+}
+
+// save writes the config readable by the user only, it holds their tokens.
+func (cfg *config) save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return err
+	}
+	data, err := json.MarshalIndent(cfg, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0o600)
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it; the
+// services do that. A zero time means the token carries no expiry.
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return time.Time{}, fmt.Errorf("not a JWT: %w", err)
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("not a JWT: %w", err)
+	}
+	if claims.Exp == 0 {
+		return time.Time{}, nil
+	}
+	return time.Unix(claims.Exp, 0), nil
+}
+
+// apiURL picks the API from --api, then TASKNEST_API_URL, then the config.
+func (c *cli) apiURL() (string, error) {
+	switch {
+	case c.api != "":
+		return c.api, nil
+	case os.Getenv("TASKNEST_API_URL") != "":
+		return os.Getenv("TASKNEST_API_URL"), nil
+	case c.config.APIURL != "":
+		return c.config.APIURL, nil
+	}
+	return "", errors.New("no API URL, pass --api or set TASKNEST_API_URL")
+}
+
+// anonymousClient is for the login endpoints, which take no credentials.
+func (c *cli) anonymousClient() (*client.Client, error) {
+	api, err := c.apiURL()
+	if err != nil {
+		return nil, err
+	}
+	return client.New(api, client.WithUserAgent(userAgent))
+}
+
+// authedClient returns a client carrying the stored ID token, refreshing it
+// first when it is about to expire. TASKNEST_TOKEN overrides the stored token.
+func (c *cli) authedClient(ctx context.Context) (*client.Client, error) {
+	api, err := c.apiURL()
+	if err != nil {
+		return nil, err
+	}
+
+	if token := os.Getenv("TASKNEST_TOKEN"); token != "" {
+		return client.New(api, client.WithUserAgent(userAgent), client.WithBearerToken(token))
+	}
+
+	if c.config.IDToken == "" {
+		return nil, errors.New("not logged in, run tasknest login")
+	}
+
+	if !c.config.ExpiresAt.IsZero() && time.Now().Add(refreshMargin).After(c.config.ExpiresAt) {
+		if c.config.RefreshToken == "" {
+			return nil, errors.New("session expired, run tasknest login")
+		}
+
+		anon, err := client.New(api, client.WithUserAgent(userAgent))
+		if err != nil {
+			return nil, err
+		}
+		token, err := anon.RefreshIDToken(ctx, c.config.RefreshToken)
+		if err != nil {
+			return nil, fmt.Errorf("session expired, run tasknest login: %w", err)
+		}
+		expires, _ := tokenExpiry(token)
+
+		c.config.IDToken, c.config.ExpiresAt = token, expires
+		if err := c.config.save(c.configPath); err != nil {
+			return nil, fmt.Errorf("unable to save refreshed token: %w", err)
+		}
+	}
+
+	return client.New(api, client.WithUserAgent(userAgent), client.WithBearerToken(c.config.IDToken))
+}
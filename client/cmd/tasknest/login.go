@@ -0,0 +1,125 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GoncaloMark/tasknest/client"
+)
+
+func loginCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	token := fs.String("token", "", "use this ID token instead of a device login")
+	paste := fs.Bool("paste", false, "read an ID token from stdin, e.g. one copied from the web app")
+
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("login takes no arguments")
+		}
+
+		anon, err := c.anonymousClient()
+		if err != nil {
+			return err
+		}
+
+		var tok *client.Token
+		switch {
+		case *token != "" || *paste:
+			idToken := *token
+			if *paste {
+				fmt.Fprint(c.stderr, "Paste your ID token: ")
+				line, err := bufio.NewReader(c.stdin).ReadString('\n')
+				if err != nil && line == "" {
+					return fmt.Errorf("unable to read token: %w", err)
+				}
+				idToken = strings.TrimSpace(line)
+			}
+			expires, err := tokenExpiry(idToken)
+			if err != nil {
+				return fmt.Errorf("invalid ID token: %w", err)
+			}
+			tok = &client.Token{IDToken: idToken, ExpiresAt: expires}
+
+		default:
+			code, err := anon.StartDeviceLogin(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stderr, "Open %s in a browser where you are signed in to tasknest\nand enter the code %s\n\nOr go straight to %s\n\nWaiting for approval...\n",
+				code.VerificationURI, code.UserCode, code.VerificationURIComplete)
+
+			if tok, err = anon.PollDeviceLogin(ctx, code); err != nil {
+				switch {
+				case errors.Is(err, client.ErrAccessDenied):
+					return errors.New("login was denied in the browser")
+				case errors.Is(err, client.ErrDeviceCodeExpired):
+					return errors.New("the code expired before it was approved, run tasknest login again")
+				}
+				return err
+			}
+		}
+
+		api, _ := c.apiURL()
+		authed, err := client.New(api, client.WithUserAgent(userAgent), client.WithBearerToken(tok.IDToken))
+		if err != nil {
+			return err
+		}
+		user, err := authed.Me(ctx)
+		if err != nil {
+			return fmt.Errorf("token was rejected: %w", err)
+		}
+
+		c.config.APIURL = api
+		c.config.IDToken = tok.IDToken
+		c.config.RefreshToken = tok.RefreshToken
+		c.config.ExpiresAt = tok.ExpiresAt
+		if err := c.config.save(c.configPath); err != nil {
+			return fmt.Errorf("unable to save login: %w", err)
+		}
+
+		fmt.Fprintf(c.stderr, "Logged in as %s\n", user.Email)
+		if tok.RefreshToken == "" && !tok.ExpiresAt.IsZero() {
+			fmt.Fprintf(c.stderr, "The token can't be refreshed and expires at %s\n", tok.ExpiresAt.Local().Format(time.DateTime))
+		}
+		return nil
+	}
+}
+
+func logoutCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		c.config.IDToken, c.config.RefreshToken, c.config.ExpiresAt = "", "", time.Time{}
+		if err := c.config.save(c.configPath); err != nil {
+			return err
+		}
+		fmt.Fprintln(c.stderr, "Logged out")
+		return nil
+	}
+}
+
+func whoamiCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+		user, err := api.Me(ctx)
+		if err != nil {
+			return err
+		}
+		if c.output == "json" {
+			return writeJSON(c.stdout, user)
+		}
+
+		tw := newTable(c.stdout)
+		fmt.Fprintf(tw, "Email\t%s\n", user.Email)
+		fmt.Fprintf(tw, "User ID\t%s\n", user.UserID)
+		fmt.Fprintf(tw, "Timezone\t%s\n", user.Timezone)
+		fmt.Fprintf(tw, "Locale\t%s\n", user.Locale)
+		fmt.Fprintf(tw, "Digest\t%s\n", strings.ToLower(user.DigestFrequency))
+		return tw.Flush()
+	}
+}
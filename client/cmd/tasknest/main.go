@@ -0,0 +1,185 @@
+// Command tasknest manages tasknest tasks from the terminal.
+//
+//	tasknest login --api https://tasknest.example.com/api
+//	tasknest add Pay rent tomorrow !high #finance
+//	tasknest ls --status TODO --sort deadline
+//	tasknest done 3f2a
+//
+// Run "tasknest help" for every command.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"slices"
+	"syscall"
+	"text/tabwriter"
+)
+
+const userAgent = "tasknest-cli"
+
+// cli carries what every command needs: the streams, the global flags and
+// the stored config.
+type cli struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+
+	api    string
+	output string
+
+	config     *config
+	configPath string
+}
+
+// command registers its flags on fs and returns the function running it with
+// the remaining positional arguments. Splitting the two lets completion
+// list a command's flags without running it.
+type command struct {
+	Name    string
+	Args    string
+	Summary string
+	Flags   func(fs *flag.FlagSet) func(ctx context.Context, c *cli, args []string) error
+}
+
+var commands []command
+
+func init() {
+	commands = []command{
+		{"login", "", "Sign in with a device code, or with a pasted ID token", loginCommand},
+		{"logout", "", "Forget the stored tokens", logoutCommand},
+		{"whoami", "", "Show the signed-in user", whoamiCommand},
+		{"add", "<text>", "Add a task; without flags the text is parsed like quick add", addCommand},
+		{"ls", "", "List tasks", lsCommand},
+		{"show", "<id>", "Show a task", showCommand},
+		{"done", "<id>...", "Mark tasks as done", doneCommand},
+		{"edit", "<id>", "Change a task's fields", editCommand},
+		{"rm", "<id>...", "Delete tasks", rmCommand},
+		{"export", "", "Export every task as JSON or CSV", exportCommand},
+		{"completion", "bash|zsh|fish", "Print a shell completion script", completionCommand},
+	}
+}
+
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].Name == name {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// errUsage has already been explained to the user by the flag package.
+var errUsage = errors.New("usage")
+
+func (c *cli) usage() {
+	fmt.Fprintf(c.stderr, "Usage: tasknest <command> [flags] [args]\n\nCommands:\n")
+	tw := tabwriter.NewWriter(c.stderr, 0, 4, 2, ' ', 0)
+	for _, cmd := range commands {
+		fmt.Fprintf(tw, "  %s %s\t%s\n", cmd.Name, cmd.Args, cmd.Summary)
+	}
+	tw.Flush()
+	fmt.Fprintf(c.stderr, "\nRun \"tasknest <command> -h\" for a command's flags.\n")
+	fmt.Fprintf(c.stderr, "\nEnvironment:\n  TASKNEST_API_URL  API base URL, e.g. https://tasknest.example.com/api\n  TASKNEST_TOKEN    ID token to use instead of the stored login\n  TASKNEST_CONFIG   config file path\n")
+}
+
+// newFlagSet adds the flags every command shares.
+func (c *cli) newFlagSet(cmd *command) *flag.FlagSet {
+	fs := flag.NewFlagSet(cmd.Name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	fs.StringVar(&c.api, "api", "", "API base URL")
+	fs.StringVar(&c.output, "output", "table", "output format: table or json")
+	fs.StringVar(&c.output, "o", "table", "shorthand for --output")
+	fs.Usage = func() {
+		fmt.Fprintf(c.stderr, "Usage: tasknest %s [flags] %s\n\n%s\n\nFlags:\n", cmd.Name, cmd.Args, cmd.Summary)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parseArgs parses flags wherever they appear among the arguments, so
+// "tasknest add Buy milk --priority high" works. Everything after "--" is
+// positional.
+func parseArgs(fs *flag.FlagSet, args []string) ([]string, error) {
+	var rest []string
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, rest = args[:i], args[i+1:]
+	}
+
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return nil, err
+		}
+		args = fs.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+	return append(positional, rest...), nil
+}
+
+func (c *cli) run(ctx context.Context, args []string) error {
+	if len(args) == 0 || args[0] == "help" || args[0] == "-h" || args[0] == "--help" {
+		c.usage()
+		if len(args) == 0 {
+			return errUsage
+		}
+		return nil
+	}
+
+	cmd := findCommand(args[0])
+	if cmd == nil {
+		fmt.Fprintf(c.stderr, "tasknest: unknown command %q\n\n", args[0])
+		c.usage()
+		return errUsage
+	}
+
+	fs := c.newFlagSet(cmd)
+	runCmd := cmd.Flags(fs)
+	positional, err := parseArgs(fs, args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return nil
+	}
+	if err != nil {
+		return errUsage
+	}
+	if c.output != "table" && c.output != "json" {
+		return fmt.Errorf("invalid output format %q, use table or json", c.output)
+	}
+
+	return runCmd(ctx, c, positional)
+}
+
+func main() {
+	c := &cli{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}
+
+	path, err := configPath()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tasknest: %v\n", err)
+		os.Exit(1)
+	}
+	c.configPath = path
+	if c.config, err = loadConfig(path); err != nil {
+		fmt.Fprintf(os.Stderr, "tasknest: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err := c.run(ctx, os.Args[1:]); err != nil {
+		if errors.Is(err, errUsage) {
+			os.Exit(2)
+		}
+		fmt.Fprintf(os.Stderr, "tasknest: %v\n", err)
+		os.Exit(1)
+	}
+}
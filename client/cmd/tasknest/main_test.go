@@ -0,0 +1,408 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GoncaloMark/tasknest/client"
+)
+
+// fakeJWT builds an unsigned token with the given expiry; the CLI only reads exp.
+func fakeJWT(exp time.Time) string {
+	enc := base64.RawURLEncoding.EncodeToString
+	return enc([]byte(`{"alg":"none"}`)) + "." + enc([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix()))) + ".sig"
+}
+
+type fakeServer struct {
+	mu       sync.Mutex
+	tasks    []client.Task
+	token    string
+	requests []string
+	bodies   map[string]json.RawMessage
+}
+
+func newFakeServer(t *testing.T, token string) (*fakeServer, *httptest.Server) {
+	deadline := time.Date(2026, time.October, 20, 0, 0, 0, 0, time.UTC)
+	fs := &fakeServer{
+		token:  token,
+		bodies: map[string]json.RawMessage{},
+		tasks: []client.Task{
+			{TaskID: "3f2a1b4c-0000-4000-8000-000000000001", Title: "Pay rent", Status: client.StatusTodo, Priority: client.PriorityHigh, Deadline: &deadline, AllDay: true, Labels: []string{"finance"}},
+			{TaskID: "3f2a9d8e-0000-4000-8000-000000000002", Title: "Call mum", Status: client.StatusInProgress, Priority: client.PriorityMedium},
+			{TaskID: "77aa0000-0000-4000-8000-000000000003", Title: "Water plants", Status: client.StatusDone, Priority: client.PriorityLow},
+		},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks/read", func(w http.ResponseWriter, r *http.Request) {
+		var tasks []client.Task
+		for _, task := range fs.tasks {
+			if status := r.URL.Query().Get("status"); status == "" || string(task.Status) == status {
+				tasks = append(tasks, task)
+			}
+		}
+		writeJSON(w, map[string]any{"tasks": tasks, "total": len(tasks)})
+	})
+	mux.HandleFunc("GET /api/tasks/read/{id}", func(w http.ResponseWriter, r *http.Request) {
+		for _, task := range fs.tasks {
+			if task.TaskID == r.PathValue("id") {
+				writeJSON(w, task)
+				return
+			}
+		}
+		http.Error(w, "Task not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("PUT /api/tasks/update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Title    string          `json:"title"`
+			Status   client.Status   `json:"status"`
+			Priority client.Priority `json:"priority"`
+			Deadline *string         `json:"deadline"`
+			AllDay   bool            `json:"all_day"`
+			Labels   []string        `json:"labels"`
+			Project  *string         `json:"project"`
+		}
+		json.Unmarshal(fs.bodies[r.Method+" "+r.URL.Path], &in)
+		for i, task := range fs.tasks {
+			if task.TaskID == r.PathValue("id") {
+				task.Title, task.Status, task.Priority, task.AllDay = in.Title, in.Status, in.Priority, in.AllDay
+				if in.Labels != nil {
+					task.Labels = in.Labels
+				}
+				if in.Project != nil {
+					task.Project = *in.Project
+				}
+				fs.tasks[i] = task
+				writeJSON(w, task)
+				return
+			}
+		}
+		http.Error(w, "Task not found", http.StatusNotFound)
+	})
+	mux.HandleFunc("POST /api/tasks/create", func(w http.ResponseWriter, r *http.Request) {
+		var task client.Task
+		json.Unmarshal(fs.bodies[r.Method+" "+r.URL.Path], &task)
+		task.TaskID = "new00000-0000-4000-8000-000000000000"
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, task)
+	})
+	mux.HandleFunc("POST /api/tasks/quick", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		writeJSON(w, client.Task{TaskID: "quick000-0000-4000-8000-000000000000", Title: "Pay rent", Status: client.StatusTodo, Priority: client.PriorityHigh})
+	})
+	mux.HandleFunc("GET /api/users/me", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, client.User{UserID: "user-1", Email: "ana@example.com", Timezone: "UTC", DigestFrequency: "NONE"})
+	})
+	mux.HandleFunc("POST /api/users/refresh", func(w http.ResponseWriter, r *http.Request) {
+		if cookie, err := r.Cookie("refresh_token"); err != nil || cookie.Value != "refresh" {
+			http.Error(w, "Refresh token missing", http.StatusUnauthorized)
+			return
+		}
+		fs.token = fakeJWT(time.Now().Add(time.Hour))
+		http.SetCookie(w, &http.Cookie{Name: "id_token", Value: fs.token})
+	})
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fs.mu.Lock()
+		defer fs.mu.Unlock()
+
+		body, _ := io.ReadAll(r.Body)
+		fs.bodies[r.Method+" "+r.URL.Path] = body
+		fs.requests = append(fs.requests, r.Method+" "+r.URL.RequestURI())
+
+		if r.URL.Path != "/api/users/refresh" && r.Header.Get("Authorization") != "Bearer "+fs.token {
+			http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return fs, srv
+}
+
+func newTestCLI(t *testing.T, api string, cfg config) (*cli, *bytes.Buffer, *bytes.Buffer) {
+	t.Setenv("TASKNEST_API_URL", "")
+	t.Setenv("TASKNEST_TOKEN", "")
+
+	var stdout, stderr bytes.Buffer
+	cfg.APIURL = api
+	return &cli{
+		stdin:      strings.NewReader(""),
+		stdout:     &stdout,
+		stderr:     &stderr,
+		config:     &cfg,
+		configPath: filepath.Join(t.TempDir(), "config.json"),
+	}, &stdout, &stderr
+}
+
+func TestParseArgs(t *testing.T) {
+	for _, tt := range []struct {
+		args       []string
+		positional []string
+		priority   string
+	}{
+		{[]string{"Buy", "milk"}, []string{"Buy", "milk"}, ""},
+		{[]string{"Buy", "milk", "--priority", "high"}, []string{"Buy", "milk"}, "high"},
+		{[]string{"--priority=low", "Buy", "milk"}, []string{"Buy", "milk"}, "low"},
+		{[]string{"Buy", "--", "--priority", "high"}, []string{"Buy", "--priority", "high"}, ""},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		priority := fs.String("priority", "", "")
+		got, err := parseArgs(fs, tt.args)
+		if err != nil {
+			t.Fatalf("parseArgs(%q): %v", tt.args, err)
+		}
+		if !slices.Equal(got, tt.positional) || *priority != tt.priority {
+			t.Errorf("parseArgs(%q) = %q, priority %q; want %q, priority %q", tt.args, got, *priority, tt.positional, tt.priority)
+		}
+	}
+}
+
+func TestParseDue(t *testing.T) {
+	loc := time.FixedZone("UTC+1", 60*60)
+	now := time.Date(2026, time.October, 14, 22, 30, 0, 0, loc)
+
+	for _, tt := range []struct {
+		in     string
+		want   string
+		allDay bool
+	}{
+		{"today", "2026-10-14T00:00:00+01:00", true},
+		{"Tomorrow", "2026-10-15T00:00:00+01:00", true},
+		{"2026-11-03", "2026-11-03T00:00:00+01:00", true},
+		{"2026-11-03 17:00", "2026-11-03T17:00:00+01:00", false},
+		{"2026-11-03T17:00:00Z", "2026-11-03T17:00:00Z", false},
+	} {
+		got, allDay, err := parseDue(tt.in, now)
+		if err != nil {
+			t.Fatalf("parseDue(%q): %v", tt.in, err)
+		}
+		if got.Format(time.RFC3339) != tt.want || allDay != tt.allDay {
+			t.Errorf("parseDue(%q) = %s, %v; want %s, %v", tt.in, got.Format(time.RFC3339), allDay, tt.want, tt.allDay)
+		}
+	}
+
+	if _, _, err := parseDue("next week", now); err == nil {
+		t.Error("parseDue accepted \"next week\"")
+	}
+}
+
+func TestList(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour))
+	fs, srv := newFakeServer(t, token)
+	c, stdout, _ := newTestCLI(t, srv.URL+"/api", config{IDToken: token})
+
+	if err := c.run(context.Background(), []string{"ls", "--status", "todo", "--sort", "deadline"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := fs.requests[0]; got != "GET /api/tasks/read?limit=100&page=1&sort=deadline&status=TODO" {
+		t.Errorf("request = %s", got)
+	}
+	out := stdout.String()
+	if !strings.Contains(out, "3f2a1b4c") || !strings.Contains(out, "2026-10-20") || !strings.Contains(out, "#finance") || strings.Contains(out, "Call mum") {
+		t.Errorf("unexpected table:\n%s", out)
+	}
+
+	stdout.Reset()
+	if err := c.run(context.Background(), []string{"ls", "-o", "json"}); err != nil {
+		t.Fatal(err)
+	}
+	var tasks []client.Task
+	if err := json.Unmarshal(stdout.Bytes(), &tasks); err != nil || len(tasks) != 3 {
+		t.Fatalf("json output = %s (%v)", stdout, err)
+	}
+
+	if err := c.run(context.Background(), []string{"ls", "--status", "later"}); err == nil || !strings.Contains(err.Error(), "invalid status") {
+		t.Errorf("error = %v, want invalid status", err)
+	}
+}
+
+func TestDoneResolvesPrefixes(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour))
+	fs, srv := newFakeServer(t, token)
+	c, stdout, _ := newTestCLI(t, srv.URL+"/api", config{IDToken: token})
+
+	if err := c.run(context.Background(), []string{"done", "3f2a"}); err == nil || !strings.Contains(err.Error(), "matches 2 tasks") {
+		t.Fatalf("error = %v, want an ambiguous prefix", err)
+	}
+
+	if err := c.run(context.Background(), []string{"done", "3f2a1b"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "Done: 3f2a1b4c Pay rent") {
+		t.Errorf("output = %q", stdout)
+	}
+
+	var body map[string]any
+	json.Unmarshal(fs.bodies["PUT /api/tasks/update/3f2a1b4c-0000-4000-8000-000000000001"], &body)
+	if body["status"] != "DONE" || body["priority"] != "HIGH" || body["deadline"] != "2026-10-20" || body["all_day"] != true {
+		t.Errorf("update body = %v, want the other fields kept", body)
+	}
+}
+
+func TestAdd(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour))
+	fs, srv := newFakeServer(t, token)
+	c, _, _ := newTestCLI(t, srv.URL+"/api", config{IDToken: token})
+
+	if err := c.run(context.Background(), []string{"add", "Pay", "rent", "tomorrow", "!high"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(fs.bodies["POST /api/tasks/quick"]); got != `{"text":"Pay rent tomorrow !high"}` {
+		t.Errorf("quick add body = %s", got)
+	}
+
+	if err := c.run(context.Background(), []string{"add", "Buy", "milk", "--priority", "low", "--due", "2026-11-03", "--label", "home,#errands", "--project", "House"}); err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	json.Unmarshal(fs.bodies["POST /api/tasks/create"], &body)
+	if body["title"] != "Buy milk" || body["priority"] != "LOW" || body["status"] != "TODO" || body["deadline"] != "2026-11-03" || fmt.Sprint(body["labels"]) != "[home errands]" || body["project"] != "House" {
+		t.Errorf("create body = %v", body)
+	}
+}
+
+func TestEditProject(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour))
+	fs, srv := newFakeServer(t, token)
+	c, stdout, _ := newTestCLI(t, srv.URL+"/api", config{IDToken: token})
+	path := "PUT /api/tasks/update/3f2a1b4c-0000-4000-8000-000000000001"
+
+	if err := c.run(context.Background(), []string{"edit", "3f2a1b", "--project", "Home"}); err != nil {
+		t.Fatal(err)
+	}
+	var body map[string]any
+	json.Unmarshal(fs.bodies[path], &body)
+	if body["project"] != "Home" || fmt.Sprint(body["labels"]) != "[finance]" {
+		t.Errorf("update body = %v", body)
+	}
+	if !strings.Contains(stdout.String(), "Project") {
+		t.Errorf("output = %q", stdout)
+	}
+
+	if err := c.run(context.Background(), []string{"edit", "3f2a1b", "--no-project"}); err != nil {
+		t.Fatal(err)
+	}
+	body = nil
+	json.Unmarshal(fs.bodies[path], &body)
+	if v, ok := body["project"]; !ok || v != "" {
+		t.Errorf("project = %v, want it cleared", v)
+	}
+
+	if err := c.run(context.Background(), []string{"edit", "3f2a1b", "--project", "Home", "--no-project"}); err == nil {
+		t.Error("--project and --no-project were combined")
+	}
+}
+
+func TestLoginWithPastedToken(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour).Truncate(time.Second))
+	_, srv := newFakeServer(t, token)
+	c, _, stderr := newTestCLI(t, "", config{})
+	c.stdin = strings.NewReader(token + "\n")
+
+	if err := c.run(context.Background(), []string{"login", "--paste", "--api", srv.URL + "/api"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stderr.String(), "Logged in as ana@example.com") {
+		t.Errorf("output = %q", stderr)
+	}
+
+	info, err := os.Stat(c.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("config mode = %v, want 0600", info.Mode().Perm())
+	}
+	saved, err := loadConfig(c.configPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if saved.IDToken != token || saved.APIURL != srv.URL+"/api" || saved.ExpiresAt.IsZero() {
+		t.Errorf("saved config = %+v", saved)
+	}
+
+	c.stdin = strings.NewReader("not a token\n")
+	if err := c.run(context.Background(), []string{"login", "--paste"}); err == nil || !strings.Contains(err.Error(), "invalid ID token") {
+		t.Errorf("error = %v, want invalid ID token", err)
+	}
+}
+
+func TestExpiredTokenIsRefreshed(t *testing.T) {
+	fs, srv := newFakeServer(t, "")
+	c, stdout, _ := newTestCLI(t, srv.URL+"/api", config{
+		IDToken:      fakeJWT(time.Now().Add(-time.Minute)),
+		RefreshToken: "refresh",
+		ExpiresAt:    time.Now().Add(-time.Minute),
+	})
+
+	if err := c.run(context.Background(), []string{"whoami"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(stdout.String(), "ana@example.com") {
+		t.Errorf("output = %q", stdout)
+	}
+	if c.config.IDToken != fs.token || !c.config.ExpiresAt.After(time.Now()) {
+		t.Errorf("config wasn't updated with the refreshed token: %+v", c.config)
+	}
+
+	c.config.RefreshToken, c.config.ExpiresAt = "", time.Now().Add(-time.Minute)
+	if err := c.run(context.Background(), []string{"whoami"}); err == nil || !strings.Contains(err.Error(), "run tasknest login") {
+		t.Errorf("error = %v, want a hint to log in again", err)
+	}
+}
+
+func TestExportCSV(t *testing.T) {
+	token := fakeJWT(time.Now().Add(time.Hour))
+	fs, srv := newFakeServer(t, token)
+	c, stdout, _ := newTestCLI(t, srv.URL+"/api", config{IDToken: token})
+
+	if err := c.run(context.Background(), []string{"export", "--format", "csv"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(fs.requests[0], "archive=true") {
+		t.Errorf("request = %s, want archived tasks included", fs.requests[0])
+	}
+
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], "task_id,title,") {
+		t.Fatalf("unexpected CSV:\n%s", stdout)
+	}
+	if !strings.Contains(lines[1], "Pay rent,,TODO,HIGH,2026-10-20,true,finance") {
+		t.Errorf("row = %s", lines[1])
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	c, stdout, _ := newTestCLI(t, "", config{})
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		stdout.Reset()
+		if err := c.run(context.Background(), []string{"completion", shell}); err != nil {
+			t.Fatalf("%s: %v", shell, err)
+		}
+		for _, want := range []string{"ls", "done", "export", "IN_PROGRESS", "this_week"} {
+			if !strings.Contains(stdout.String(), want) {
+				t.Errorf("%s completion doesn't mention %s", shell, want)
+			}
+		}
+	}
+
+	if err := c.run(context.Background(), []string{"completion", "powershell"}); err == nil {
+		t.Error("completion accepted powershell")
+	}
+}
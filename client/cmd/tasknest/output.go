@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/GoncaloMark/tasknest/client"
+)
+
+// shortIDLength is how much of a task ID the table shows; any unique prefix
+// of at least minIDPrefix characters is accepted back.
+const shortIDLength = 8
+
+func writeJSON(w io.Writer, v any) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func newTable(w io.Writer) *tabwriter.Writer {
+	return tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+}
+
+func shortID(id string) string {
+	if len(id) > shortIDLength {
+		return id[:shortIDLength]
+	}
+	return id
+}
+
+// formatDeadline shows all-day deadlines as their date and the rest in the
+// local timezone.
+func formatDeadline(task client.Task) string {
+	switch {
+	case task.Deadline == nil:
+		return "-"
+	case task.AllDay:
+		return task.DeadlineDate()
+	default:
+		return task.Deadline.Local().Format("2006-01-02 15:04")
+	}
+}
+
+func formatLabels(labels []string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "#" + strings.Join(labels, " #")
+}
+
+func writeTaskTable(w io.Writer, tasks []client.Task) error {
+	if len(tasks) == 0 {
+		_, err := fmt.Fprintln(w, "No tasks")
+		return err
+	}
+
+	tw := newTable(w)
+	fmt.Fprintln(tw, "ID\tSTATUS\tPRIORITY\tDUE\tTITLE\tLABELS")
+	for _, task := range tasks {
+		due := formatDeadline(task)
+		if task.IsOverdue {
+			due += " !"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			shortID(task.TaskID), task.Status, task.Priority, due, task.Title, formatLabels(task.Labels))
+	}
+	return tw.Flush()
+}
+
+func writeTaskDetails(w io.Writer, task client.Task) error {
+	tw := newTable(w)
+	fmt.Fprintf(tw, "ID\t%s\n", task.TaskID)
+	fmt.Fprintf(tw, "Title\t%s\n", task.Title)
+	if task.Description != "" {
+		fmt.Fprintf(tw, "Description\t%s\n", task.Description)
+	}
+	fmt.Fprintf(tw, "Status\t%s\n", task.Status)
+	fmt.Fprintf(tw, "Priority\t%s\n", task.Priority)
+	due := formatDeadline(task)
+	if task.IsOverdue {
+		due += " (overdue)"
+	}
+	fmt.Fprintf(tw, "Due\t%s\n", due)
+	if len(task.Labels) > 0 {
+		fmt.Fprintf(tw, "Labels\t%s\n", formatLabels(task.Labels))
+	}
+	if task.Project != "" {
+		fmt.Fprintf(tw, "Project\t%s\n", task.Project)
+	}
+	if task.EstimateMinutes != nil {
+		fmt.Fprintf(tw, "Estimate\t%s\n", time.Duration(*task.EstimateMinutes)*time.Minute)
+	}
+	if task.TrackedSeconds > 0 {
+		fmt.Fprintf(tw, "Tracked\t%s\n", time.Duration(task.TrackedSeconds)*time.Second)
+	}
+	fmt.Fprintf(tw, "Created\t%s\n", task.CreationDate.Local().Format(time.DateOnly))
+	if task.CompletedAt != nil {
+		fmt.Fprintf(tw, "Completed\t%s\n", task.CompletedAt.Local().Format("2006-01-02 15:04"))
+	}
+	if task.ArchivedAt != nil {
+		fmt.Fprintf(tw, "Archived\t%s\n", task.ArchivedAt.Local().Format("2006-01-02 15:04"))
+	}
+	return tw.Flush()
+}
+
+// writeTask prints a single task in the chosen output format.
+func (c *cli) writeTask(task *client.Task) error {
+	if c.output == "json" {
+		return writeJSON(c.stdout, task)
+	}
+	return writeTaskDetails(c.stdout, *task)
+}
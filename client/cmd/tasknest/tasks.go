@@ -0,0 +1,528 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GoncaloMark/tasknest/client"
+)
+
+// minIDPrefix is the shortest task ID prefix accepted in place of a full ID.
+const minIDPrefix = 4
+
+var (
+	statusValues   = []string{"TODO", "IN_PROGRESS", "DONE"}
+	priorityValues = []string{"LOW", "MEDIUM", "HIGH"}
+	dueValues      = []string{client.DueOverdue, client.DueToday, client.DueThisWeek, client.DueNone}
+	archiveValues  = []string{client.ArchiveExclude, client.ArchiveInclude, client.ArchiveOnly}
+	sortValues     = []string{"creation_date", "deadline", "priority", "status"}
+	formatValues   = []string{"json", "csv"}
+)
+
+// labelList collects a repeatable --label flag.
+type labelList []string
+
+func (l *labelList) String() string { return strings.Join(*l, ",") }
+
+func (l *labelList) Set(value string) error {
+	for _, label := range strings.Split(value, ",") {
+		if label = strings.TrimPrefix(strings.TrimSpace(label), "#"); label != "" {
+			*l = append(*l, label)
+		}
+	}
+	return nil
+}
+
+// oneOf upper- or lower-cases value to match the API and checks it's allowed.
+func oneOf(name, value string, allowed []string) (string, error) {
+	if value == "" {
+		return "", nil
+	}
+	for _, v := range allowed {
+		if strings.EqualFold(v, value) {
+			return v, nil
+		}
+	}
+	return "", fmt.Errorf("invalid %s %q, use one of %s", name, value, strings.Join(allowed, ", "))
+}
+
+// parseDue reads a deadline given on the command line. Dates, today and
+// tomorrow are all-day deadlines; times are in the local timezone unless
+// they carry an offset.
+func parseDue(value string, now time.Time) (*time.Time, bool, error) {
+	switch strings.ToLower(value) {
+	case "today":
+		d := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		return &d, true, nil
+	case "tomorrow":
+		d := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, now.Location())
+		return &d, true, nil
+	}
+
+	if d, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return &d, true, nil
+	}
+	for _, layout := range []string{"2006-01-02 15:04", "2006-01-02T15:04"} {
+		if d, err := time.ParseInLocation(layout, value, now.Location()); err == nil {
+			return &d, false, nil
+		}
+	}
+	if d, err := time.Parse(time.RFC3339, value); err == nil {
+		return &d, false, nil
+	}
+	return nil, false, fmt.Errorf("invalid due date %q, use YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", today or tomorrow", value)
+}
+
+// resolveTaskID expands a task ID prefix, as shown by ls, to the full ID.
+func resolveTaskID(ctx context.Context, api *client.Client, id string) (string, error) {
+	if len(id) == 36 {
+		return id, nil
+	}
+	if len(id) < minIDPrefix {
+		return "", fmt.Errorf("task ID %q is too short, give at least %d characters", id, minIDPrefix)
+	}
+
+	var matches []string
+	for task, err := range api.AllTasks(ctx, client.ListOptions{Archive: client.ArchiveInclude, PageSize: 100}) {
+		if err != nil {
+			return "", err
+		}
+		if strings.HasPrefix(task.TaskID, strings.ToLower(id)) {
+			matches = append(matches, task.TaskID)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("no task matches %q", id)
+	case 1:
+		return matches[0], nil
+	}
+	return "", fmt.Errorf("%q matches %d tasks, give more of the ID", id, len(matches))
+}
+
+func addCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	description := fs.String("description", "", "task description")
+	status := fs.String("status", "", "TODO, IN_PROGRESS or DONE (default TODO)")
+	priority := fs.String("priority", "", "LOW, MEDIUM or HIGH (default MEDIUM)")
+	due := fs.String("due", "", "deadline: YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", today or tomorrow")
+	var labels labelList
+	fs.Var(&labels, "label", "label, repeatable or comma-separated")
+	project := fs.String("project", "", "project the task belongs to")
+
+	return func(ctx context.Context, c *cli, args []string) error {
+		text := strings.TrimSpace(strings.Join(args, " "))
+		if text == "" {
+			return errors.New("add needs the task's title")
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		// Without flags, let the service read dates, priorities and labels from the text
+		structured := false
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name != "api" && f.Name != "output" && f.Name != "o" {
+				structured = true
+			}
+		})
+		if !structured {
+			task, err := api.QuickAdd(ctx, text)
+			if err != nil {
+				return err
+			}
+			return c.writeTask(task)
+		}
+
+		in := client.TaskInput{
+			Title:       text,
+			Description: *description,
+			Status:      client.StatusTodo,
+			Priority:    client.PriorityMedium,
+			Labels:      labels,
+		}
+		if *project != "" {
+			in.Project = project
+		}
+		if *status != "" {
+			s, err := oneOf("status", *status, statusValues)
+			if err != nil {
+				return err
+			}
+			in.Status = client.Status(s)
+		}
+		if *priority != "" {
+			p, err := oneOf("priority", *priority, priorityValues)
+			if err != nil {
+				return err
+			}
+			in.Priority = client.Priority(p)
+		}
+		if *due != "" {
+			if in.Deadline, in.AllDay, err = parseDue(*due, time.Now()); err != nil {
+				return err
+			}
+		}
+
+		task, err := api.CreateTask(ctx, in)
+		if err != nil {
+			return err
+		}
+		return c.writeTask(task)
+	}
+}
+
+func lsCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	status := fs.String("status", "", "only tasks with this status: TODO, IN_PROGRESS or DONE")
+	priority := fs.String("priority", "", "only tasks with this priority: LOW, MEDIUM or HIGH")
+	label := fs.String("label", "", "only tasks carrying this label")
+	due := fs.String("due", "", "only tasks due: overdue, today, this_week or none")
+	archive := fs.String("archive", "", "archived tasks: false (hide), true (include) or only")
+	sort := fs.String("sort", "", "sort by creation_date, deadline, priority or status")
+	desc := fs.Bool("desc", false, "sort in descending order")
+	limit := fs.Int("limit", 0, "show at most this many tasks (default all)")
+
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("ls takes no arguments, got %q", args[0])
+		}
+
+		opts := client.ListOptions{Label: strings.TrimPrefix(*label, "#"), Descending: *desc, PageSize: 100}
+		s, err := oneOf("status", *status, statusValues)
+		if err != nil {
+			return err
+		}
+		p, err := oneOf("priority", *priority, priorityValues)
+		if err != nil {
+			return err
+		}
+		opts.Status, opts.Priority = client.Status(s), client.Priority(p)
+		if opts.Due, err = oneOf("due filter", *due, dueValues); err != nil {
+			return err
+		}
+		if opts.Archive, err = oneOf("archive filter", *archive, archiveValues); err != nil {
+			return err
+		}
+		if opts.Sort, err = oneOf("sort field", *sort, sortValues); err != nil {
+			return err
+		}
+		if *limit > 0 {
+			opts.PageSize = min(*limit, 100)
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		tasks := []client.Task{}
+		for task, err := range api.AllTasks(ctx, opts) {
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+			if *limit > 0 && len(tasks) == *limit {
+				break
+			}
+		}
+
+		if c.output == "json" {
+			return writeJSON(c.stdout, tasks)
+		}
+		return writeTaskTable(c.stdout, tasks)
+	}
+}
+
+func showCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) != 1 {
+			return errors.New("show needs exactly one task ID")
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := resolveTaskID(ctx, api, args[0])
+		if err != nil {
+			return err
+		}
+		task, err := api.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+		return c.writeTask(task)
+	}
+}
+
+func doneCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) == 0 {
+			return errors.New("done needs at least one task ID")
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		var done []*client.Task
+		for _, arg := range args {
+			id, err := resolveTaskID(ctx, api, arg)
+			if err != nil {
+				return err
+			}
+			task, err := api.GetTask(ctx, id)
+			if err != nil {
+				return err
+			}
+			if task.Status != client.StatusDone {
+				in := task.Input()
+				in.Status = client.StatusDone
+				if task, err = api.UpdateTask(ctx, id, in); err != nil {
+					return err
+				}
+			}
+			done = append(done, task)
+		}
+
+		if c.output == "json" {
+			return writeJSON(c.stdout, done)
+		}
+		for _, task := range done {
+			fmt.Fprintf(c.stdout, "Done: %s %s\n", shortID(task.TaskID), task.Title)
+		}
+		return nil
+	}
+}
+
+func editCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	title := fs.String("title", "", "new title")
+	description := fs.String("description", "", "new description")
+	status := fs.String("status", "", "TODO, IN_PROGRESS or DONE")
+	priority := fs.String("priority", "", "LOW, MEDIUM or HIGH")
+	due := fs.String("due", "", "deadline: YYYY-MM-DD, \"YYYY-MM-DD HH:MM\", today or tomorrow")
+	noDue := fs.Bool("no-due", false, "remove the deadline")
+	var labels labelList
+	fs.Var(&labels, "label", "replace the labels, repeatable or comma-separated")
+	noLabels := fs.Bool("no-labels", false, "remove every label")
+	project := fs.String("project", "", "move the task to this project")
+	noProject := fs.Bool("no-project", false, "take the task out of its project")
+
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) != 1 {
+			return errors.New("edit needs exactly one task ID")
+		}
+		if *due != "" && *noDue {
+			return errors.New("--due and --no-due can't be combined")
+		}
+		if len(labels) > 0 && *noLabels {
+			return errors.New("--label and --no-labels can't be combined")
+		}
+		if *project != "" && *noProject {
+			return errors.New("--project and --no-project can't be combined")
+		}
+
+		set := map[string]bool{}
+		fs.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+		id, err := resolveTaskID(ctx, api, args[0])
+		if err != nil {
+			return err
+		}
+		task, err := api.GetTask(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		in := task.Input()
+		if set["title"] {
+			in.Title = *title
+		}
+		if set["description"] {
+			in.Description = *description
+		}
+		if set["status"] {
+			s, err := oneOf("status", *status, statusValues)
+			if err != nil {
+				return err
+			}
+			in.Status = client.Status(s)
+		}
+		if set["priority"] {
+			p, err := oneOf("priority", *priority, priorityValues)
+			if err != nil {
+				return err
+			}
+			in.Priority = client.Priority(p)
+		}
+		switch {
+		case *noDue:
+			in.Deadline, in.AllDay = nil, false
+		case *due != "":
+			if in.Deadline, in.AllDay, err = parseDue(*due, time.Now()); err != nil {
+				return err
+			}
+		}
+		switch {
+		case *noLabels:
+			in.Labels = []string{}
+		case len(labels) > 0:
+			in.Labels = labels
+		}
+		switch {
+		case *noProject:
+			in.Project = new(string)
+		case *project != "":
+			in.Project = project
+		}
+
+		if task, err = api.UpdateTask(ctx, id, in); err != nil {
+			return err
+		}
+		return c.writeTask(task)
+	}
+}
+
+func rmCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) == 0 {
+			return errors.New("rm needs at least one task ID")
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+		for _, arg := range args {
+			id, err := resolveTaskID(ctx, api, arg)
+			if err != nil {
+				return err
+			}
+			if err := api.DeleteTask(ctx, id); err != nil {
+				return err
+			}
+			fmt.Fprintf(c.stderr, "Deleted %s\n", shortID(id))
+		}
+		return nil
+	}
+}
+
+var csvHeader = []string{
+	"task_id", "title", "description", "status", "priority", "deadline", "all_day",
+	"labels", "estimate_minutes", "estimate_points", "creation_date", "started_at",
+	"completed_at", "archived_at", "tracked_seconds",
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
+
+func formatOptionalInt(n *int) string {
+	if n == nil {
+		return ""
+	}
+	return strconv.Itoa(*n)
+}
+
+func writeTasksCSV(w io.Writer, tasks []client.Task) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, task := range tasks {
+		deadline := formatOptionalTime(task.Deadline)
+		if task.AllDay {
+			deadline = task.DeadlineDate()
+		}
+		cw.Write([]string{
+			task.TaskID,
+			task.Title,
+			task.Description,
+			string(task.Status),
+			string(task.Priority),
+			deadline,
+			strconv.FormatBool(task.AllDay),
+			strings.Join(task.Labels, ","),
+			formatOptionalInt(task.EstimateMinutes),
+			formatOptionalInt(task.EstimatePoints),
+			task.CreationDate.Format(time.RFC3339),
+			formatOptionalTime(task.StartedAt),
+			formatOptionalTime(task.CompletedAt),
+			formatOptionalTime(task.ArchivedAt),
+			strconv.FormatInt(task.TrackedSeconds, 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func exportCommand(fs *flag.FlagSet) func(context.Context, *cli, []string) error {
+	format := fs.String("format", "json", "json or csv")
+	file := fs.String("file", "", "write to this file instead of stdout")
+	archived := fs.Bool("archived", true, "include archived tasks")
+
+	return func(ctx context.Context, c *cli, args []string) error {
+		if len(args) > 0 {
+			return fmt.Errorf("export takes no arguments, got %q", args[0])
+		}
+		if !slices.Contains(formatValues, *format) {
+			return fmt.Errorf("invalid format %q, use json or csv", *format)
+		}
+
+		api, err := c.authedClient(ctx)
+		if err != nil {
+			return err
+		}
+
+		opts := client.ListOptions{Sort: "creation_date", PageSize: 100}
+		if *archived {
+			opts.Archive = client.ArchiveInclude
+		}
+		tasks := []client.Task{}
+		for task, err := range api.AllTasks(ctx, opts) {
+			if err != nil {
+				return err
+			}
+			tasks = append(tasks, task)
+		}
+
+		write := writeJSON
+		if *format == "csv" {
+			write = func(w io.Writer, v any) error { return writeTasksCSV(w, tasks) }
+		}
+		if *file == "" {
+			return write(c.stdout, tasks)
+		}
+
+		f, err := os.Create(*file)
+		if err != nil {
+			return err
+		}
+		if err := write(f, tasks); err != nil {
+			f.Close()
+			return err
+		}
+		if err := f.Close(); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stderr, "Exported %d tasks to %s\n", len(tasks), *file)
+		return nil
+	}
+}
@@ -0,0 +1,81 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Errors for the status codes the APIs document. Every *APIError wraps one
+// of them, so callers can test for e.g. errors.Is(err, client.ErrNotFound).
+var (
+	ErrInvalidInput     = errors.New("invalid input")
+	ErrUnauthorized     = errors.New("unauthorized")
+	ErrForbidden        = errors.New("forbidden")
+	ErrNotFound         = errors.New("not found")
+	ErrConflict         = errors.New("conflict")
+	ErrTooLarge         = errors.New("request too large")
+	ErrUnsupportedMedia = errors.New("unsupported media type")
+	ErrServer           = errors.New("server error")
+	ErrUnexpectedStatus = errors.New("unexpected status")
+)
+
+var statusErrors = map[int]error{
+	http.StatusBadRequest:            ErrInvalidInput,
+	http.StatusUnauthorized:          ErrUnauthorized,
+	http.StatusForbidden:             ErrForbidden,
+	http.StatusNotFound:              ErrNotFound,
+	http.StatusConflict:              ErrConflict,
+	http.StatusRequestEntityTooLarge: ErrTooLarge,
+	http.StatusUnsupportedMediaType:  ErrUnsupportedMedia,
+}
+
+// maxErrorBody bounds how much of an error response is kept as its message.
+const maxErrorBody = 4 << 10
+
+// APIError is a non-2xx response. Message is the body the service sent,
+// which for these APIs is a short plain-text explanation.
+type APIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+	kind       error
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("%s %s: %d %s", e.Method, e.URL, e.StatusCode, e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	return e.kind
+}
+
+func newAPIError(req *http.Request, resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+
+	kind, ok := statusErrors[resp.StatusCode]
+	if !ok {
+		kind = ErrUnexpectedStatus
+		if resp.StatusCode >= 500 {
+			kind = ErrServer
+		}
+	}
+
+	// Keep error messages short, the query string only repeats the filters
+	u := *req.URL
+	u.RawQuery = ""
+
+	return &APIError{
+		Method:     req.Method,
+		URL:        u.String(),
+		StatusCode: resp.StatusCode,
+		Message:    strings.TrimSpace(string(body)),
+		kind:       kind,
+	}
+}
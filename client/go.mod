@@ -0,0 +1,3 @@
+module github.com/GoncaloMark/tasknest/client
+
+go 1.23.2
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+type Status string
+
+const (
+	StatusTodo       Status = "TODO"
+	StatusInProgress Status = "IN_PROGRESS"
+	StatusDone       Status = "DONE"
+)
+
+type Priority string
+
+const (
+	PriorityLow    Priority = "LOW"
+	PriorityMedium Priority = "MEDIUM"
+	PriorityHigh   Priority = "HIGH"
+)
+
+// Task is a task as the tasks service returns it. All-day deadlines are
+// midnight UTC of their date; use DeadlineDate to read them.
+type Task struct {
+	TaskID          string     `json:"task_id"`
+	UserID          string     `json:"user_id"`
+	Title           string     `json:"title"`
+	Description     string     `json:"description"`
+	CreationDate    time.Time  `json:"creation_date"`
+	Deadline        *time.Time `json:"deadline"`
+	AllDay          bool       `json:"all_day"`
+	Status          Status     `json:"status"`
+	Priority        Priority   `json:"priority"`
+	EstimateMinutes *int       `json:"estimate_minutes"`
+	EstimatePoints  *int       `json:"estimate_points"`
+	Labels          []string   `json:"labels"`
+	Project         string     `json:"project"`
+	StartedAt       *time.Time `json:"started_at"`
+	CompletedAt     *time.Time `json:"completed_at"`
+	ArchivedAt      *time.Time `json:"archived_at"`
+	IsOverdue       bool       `json:"is_overdue"`
+	TrackedSeconds  int64      `json:"tracked_seconds"`
+}
+
+// DeadlineDate returns the calendar date of an all-day deadline as YYYY-MM-DD,
+// or "" for tasks without one.
+func (t Task) DeadlineDate() string {
+	if t.Deadline == nil || !t.AllDay {
+		return ""
+	}
+	return t.Deadline.UTC().Format(time.DateOnly)
+}
+
+// TaskInput is the body of a create or update. Updates replace every field
+// except Labels and Project, which are kept when nil.
+type TaskInput struct {
+	Title       string
+	Description string
+	Status      Status
+	Priority    Priority
+
+	// Deadline is sent as an instant, or as its calendar date in its own
+	// location when AllDay is set.
+	Deadline *time.Time
+	AllDay   bool
+
+	EstimateMinutes *int
+	EstimatePoints  *int
+	Labels          []string
+	// Project is trimmed by the service; an empty one takes the task out of
+	// its project.
+	Project *string
+}
+
+func (in TaskInput) MarshalJSON() ([]byte, error) {
+	var deadline *string
+	if in.Deadline != nil {
+		s := in.Deadline.Format(time.RFC3339Nano)
+		if in.AllDay {
+			s = in.Deadline.Format(time.DateOnly)
+		}
+		deadline = &s
+	}
+
+	return json.Marshal(struct {
+		Title           string   `json:"title"`
+		Description     string   `json:"description"`
+		Deadline        *string  `json:"deadline"`
+		AllDay          bool     `json:"all_day"`
+		Status          Status   `json:"status"`
+		Priority        Priority `json:"priority"`
+		EstimateMinutes *int     `json:"estimate_minutes"`
+		EstimatePoints  *int     `json:"estimate_points"`
+		Labels          []string `json:"labels"`
+		Project         *string  `json:"project"`
+	}{in.Title, in.Description, deadline, in.AllDay, in.Status, in.Priority, in.EstimateMinutes, in.EstimatePoints, in.Labels, in.Project})
+}
+
+// Input returns the task's fields as a TaskInput, for updates that change
+// only some of them.
+func (t Task) Input() TaskInput {
+	return TaskInput{
+		Title:           t.Title,
+		Description:     t.Description,
+		Status:          t.Status,
+		Priority:        t.Priority,
+		Deadline:        t.Deadline,
+		AllDay:          t.AllDay,
+		EstimateMinutes: t.EstimateMinutes,
+		EstimatePoints:  t.EstimatePoints,
+		Labels:          t.Labels,
+		Project:         &t.Project,
+	}
+}
+
+// Due filters for ListOptions.
+const (
+	DueOverdue  = "overdue"
+	DueToday    = "today"
+	DueThisWeek = "this_week"
+	DueNone     = "none"
+)
+
+// Archive filters for ListOptions.
+const (
+	ArchiveExclude = "false"
+	ArchiveInclude = "true"
+	ArchiveOnly    = "only"
+)
+
+// ListOptions mirrors the query parameters of the task listing. Zero values
+// leave a filter out.
+type ListOptions struct {
+	Status   Status
+	Priority Priority
+	Label    string
+	Due      string
+	Archive  string
+
+	// Sort is one of creation_date, deadline, priority or status.
+	Sort       string
+	Descending bool
+
+	// Page is 1-based. PageSize defaults to the service's 10.
+	Page     int
+	PageSize int
+}
+
+func (o ListOptions) query() url.Values {
+	q := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			q.Set(key, value)
+		}
+	}
+	set("status", string(o.Status))
+	set("priority", string(o.Priority))
+	set("label", o.Label)
+	set("due", o.Due)
+	set("archive", o.Archive)
+	set("sort", o.Sort)
+	if o.Descending {
+		q.Set("order", "desc")
+	}
+	if o.Page > 0 {
+		q.Set("page", strconv.Itoa(o.Page))
+	}
+	if o.PageSize > 0 {
+		q.Set("limit", strconv.Itoa(o.PageSize))
+	}
+	return q
+}
+
+// TaskPage is one page of a task listing with the total across all pages.
+type TaskPage struct {
+	Tasks []Task `json:"tasks"`
+	Total int64  `json:"total"`
+}
+
+// ListTasks fetches a single page of the user's tasks.
+func (c *Client) ListTasks(ctx context.Context, opts ListOptions) (*TaskPage, error) {
+	var page TaskPage
+	if err := c.do(ctx, http.MethodGet, "tasks/read", opts.query(), nil, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// GetTask fetches a single task.
+func (c *Client) GetTask(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodGet, "tasks/read/"+url.PathEscape(taskID), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// AllTasks iterates over every task matching opts, fetching pages as it goes
+// starting from opts.Page. Iteration stops after the first error.
+func (c *Client) AllTasks(ctx context.Context, opts ListOptions) iter.Seq2[Task, error] {
+	return func(yield func(Task, error) bool) {
+		if opts.Page < 1 {
+			opts.Page = 1
+		}
+		seen := int64(0)
+		for {
+			page, err := c.ListTasks(ctx, opts)
+			if err != nil {
+				yield(Task{}, err)
+				return
+			}
+			for _, task := range page.Tasks {
+				if !yield(task, nil) {
+					return
+				}
+			}
+			seen += int64(len(page.Tasks))
+			if len(page.Tasks) == 0 || seen >= page.Total {
+				return
+			}
+			opts.Page++
+		}
+	}
+}
+
+// CreateTask creates a task.
+func (c *Client) CreateTask(ctx context.Context, in TaskInput) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPost, "tasks/create", nil, in, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// QuickAdd creates a task from a line such as "Pay rent tomorrow !high #finance".
+func (c *Client) QuickAdd(ctx context.Context, text string) (*Task, error) {
+	var task Task
+	body := struct {
+		Text string `json:"text"`
+	}{text}
+	if err := c.do(ctx, http.MethodPost, "tasks/quick", nil, body, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateTask replaces a task's fields.
+func (c *Client) UpdateTask(ctx context.Context, taskID string, in TaskInput) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPut, "tasks/update/"+url.PathEscape(taskID), nil, in, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteTask deletes a task.
+func (c *Client) DeleteTask(ctx context.Context, taskID string) error {
+	return c.do(ctx, http.MethodDelete, "tasks/delete/"+url.PathEscape(taskID), nil, nil, nil)
+}
+
+// ArchiveTask hides a task from the default listing.
+func (c *Client) ArchiveTask(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPut, "tasks/archive/"+url.PathEscape(taskID), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UnarchiveTask brings an archived task back into the default listing.
+func (c *Client) UnarchiveTask(ctx context.Context, taskID string) (*Task, error) {
+	var task Task
+	if err := c.do(ctx, http.MethodPut, "tasks/unarchive/"+url.PathEscape(taskID), nil, nil, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
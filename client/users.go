@@ -0,0 +1,65 @@
+package client
+
+import (
+	"context"
+	"net/http"
+)
+
+// User is the authenticated user's profile and preferences.
+type User struct {
+	UserID     string `json:"user_id"`
+	Email      string `json:"email"`
+	Timezone   string `json:"timezone"`
+	Locale     string `json:"locale"`
+	WeekStart  int    `json:"week_start"`
+	DateFormat string `json:"date_format"`
+
+	DailyCapacityMinutes int `json:"daily_capacity_minutes"`
+	DailyCapacityPoints  int `json:"daily_capacity_points"`
+
+	AutoArchiveDays *int `json:"auto_archive_days"`
+
+	DigestFrequency string `json:"digest_frequency"`
+	DigestHour      int    `json:"digest_hour"`
+}
+
+// ProfileUpdate changes the fields that are set and leaves the rest alone.
+type ProfileUpdate struct {
+	Timezone   *string `json:"timezone,omitempty"`
+	Locale     *string `json:"locale,omitempty"`
+	WeekStart  *int    `json:"week_start,omitempty"`
+	DateFormat *string `json:"date_format,omitempty"`
+
+	DailyCapacityMinutes *int `json:"daily_capacity_minutes,omitempty"`
+	DailyCapacityPoints  *int `json:"daily_capacity_points,omitempty"`
+
+	// 0 turns auto-archiving off.
+	AutoArchiveDays *int `json:"auto_archive_days,omitempty"`
+
+	DigestFrequency *string `json:"digest_frequency,omitempty"`
+	DigestHour      *int    `json:"digest_hour,omitempty"`
+}
+
+// Me returns the authenticated user's profile. The users service only
+// accepts ID tokens, not WithUserID.
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodGet, "users/me", nil, nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// UpdateMe changes the authenticated user's preferences.
+func (c *Client) UpdateMe(ctx context.Context, update ProfileUpdate) (*User, error) {
+	var user User
+	if err := c.do(ctx, http.MethodPatch, "users/me", nil, update, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// Ptr returns a pointer to v, for filling in ProfileUpdate and TaskInput.
+func Ptr[T any](v T) *T {
+	return &v
+}
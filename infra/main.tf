@@ -251,6 +251,23 @@ data "aws_iam_policy_document" "ecs_task_assume_role_policy" {
     }
 }
 
+# Backend services that call the tasks gRPC API join this group
+resource "aws_security_group" "grpc_consumers_sg" {
+    name   = "${var.project_name}-grpc-consumers-sg"
+    vpc_id = module.vpc.vpc_id
+
+    egress {
+        from_port   = 9090
+        to_port     = 9090
+        protocol    = "tcp"
+        cidr_blocks = [var.vpc_cidr]
+    }
+
+    tags = {
+        Name = "${var.project_name}-grpc-consumers-sg"
+    }
+}
+
 resource "aws_security_group" "ecs_service_sg" {
     name   = "${var.project_name}-ecs-sg"
     vpc_id = module.vpc.vpc_id
@@ -269,6 +286,14 @@ resource "aws_security_group" "ecs_service_sg" {
         cidr_blocks = ["0.0.0.0/0"]  # Change this to restrict access as needed
     }
 
+    # gRPC task API, only for backend consumers in the grpc consumers group
+    ingress {
+        from_port       = 9090
+        to_port         = 9090
+        protocol        = "tcp"
+        security_groups = [aws_security_group.grpc_consumers_sg.id]
+    }
+
     ingress {
         from_port   = 443
         to_port     = 443
@@ -15,7 +15,9 @@ resource "aws_apigatewayv2_authorizer" "lambda_authorizer" {
     authorizer_type = "REQUEST"
     authorizer_uri = "arn:aws:apigateway:${var.aws_region}:lambda:path/2015-03-31/functions:${var.api_authorizer}/invocations"
 
-    identity_sources = ["$request.header.Cookie"]
+    # No identity sources: requests may carry either a Cookie or an Authorization
+    # header, which only works while result caching stays disabled
+    identity_sources = []
     authorizer_payload_format_version = "2.0"
 
     authorizer_result_ttl_in_seconds = 0
@@ -37,6 +37,10 @@ variable "container_definitions" {
             containerPort = number
             hostPort      = optional(number)
         }))
+        environment = optional(list(object({
+            name  = string
+            value = string
+        })), [])
     }))
 
     default = [
@@ -60,6 +64,18 @@ variable "container_definitions" {
             {
             containerPort = 8080
             hostPort      = 8080
+            },
+            {
+            containerPort = 9090
+            hostPort      = 9090
+            }
+        ]
+        # The gRPC server on 9090 only runs when the service checks callers'
+        # Cognito tokens itself, as they don't come through the API Gateway
+        environment = [
+            {
+            name  = "VERIFY_TOKENS"
+            value = "true"
             }
         ]
         },
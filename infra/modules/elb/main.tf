@@ -47,7 +47,7 @@ resource "aws_lb_target_group" "users" {
     target_type = "ip"
 
     health_check {
-        path                = "/api/users/"  # Adjust based on health check endpoint
+        path                = "/api/users/ready"
         interval            = 20
         timeout             = 5
         healthy_threshold  = 3
@@ -63,7 +63,7 @@ resource "aws_lb_target_group" "tasks" {
     target_type = "ip"
 
     health_check {
-        path                = "/api/tasks/"  # Adjust based on health check endpoint
+        path                = "/api/tasks/ready"
         interval            = 20
         timeout             = 5
         healthy_threshold  = 3
@@ -152,15 +152,20 @@ def handler(event, context):
         cookies = event.get('cookies', [])
         cookie_str = '; '.join(cookies) if isinstance(cookies, list) else cookies
         
-        # Token extraction logic
+        # Token extraction logic: API clients send a bearer token, the browser a cookie
         token = None
-        for cookie in cookie_str.split('; '):
-            if cookie.startswith('id_token='):
-                token = cookie.split('=')[1]
-                break
+        headers = {k.lower(): v for k, v in (event.get('headers') or {}).items()}
+        auth_header = headers.get('authorization', '')
+        if auth_header.startswith('Bearer '):
+            token = auth_header[len('Bearer '):]
+        else:
+            for cookie in cookie_str.split('; '):
+                if cookie.startswith('id_token='):
+                    token = cookie.split('=')[1]
+                    break
 
         if not token:
-            logger.warning("No token found in cookies")
+            logger.warning("No token found in Authorization header or cookies")
             return generate_policy('anonymous', 'Deny', event['routeArn'])
 
         # Perform verification
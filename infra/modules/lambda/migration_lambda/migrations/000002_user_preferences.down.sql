@@ -0,0 +1,5 @@
+ALTER TABLE Users
+    DROP COLUMN IF EXISTS date_format,
+    DROP COLUMN IF EXISTS week_start,
+    DROP COLUMN IF EXISTS locale,
+    DROP COLUMN IF EXISTS timezone;
@@ -0,0 +1,6 @@
+-- Per-user preferences used for deadline semantics and rendering
+ALTER TABLE Users
+    ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC',
+    ADD COLUMN locale VARCHAR(35) NOT NULL DEFAULT 'en-US',
+    ADD COLUMN week_start SMALLINT NOT NULL DEFAULT 1 CHECK (week_start BETWEEN 0 AND 6),  -- 0 = Sunday
+    ADD COLUMN date_format VARCHAR(16) NOT NULL DEFAULT 'YYYY-MM-DD';
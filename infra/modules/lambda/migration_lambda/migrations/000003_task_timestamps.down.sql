@@ -0,0 +1,8 @@
+-- Time of day is dropped; dates are taken in UTC, matching how they were migrated up
+ALTER TABLE Tasks
+    ALTER COLUMN creation_date DROP DEFAULT,
+    ALTER COLUMN creation_date TYPE DATE USING (creation_date AT TIME ZONE 'UTC')::date,
+    ALTER COLUMN creation_date SET DEFAULT CURRENT_DATE,
+    ALTER COLUMN deadline TYPE DATE USING (deadline AT TIME ZONE 'UTC')::date;
+
+ALTER TABLE Tasks DROP COLUMN IF EXISTS all_day;
@@ -0,0 +1,11 @@
+-- Deadlines and creation dates carry a time of day from now on.
+-- Existing deadlines were bare dates, so they become all-day deadlines at midnight UTC.
+ALTER TABLE Tasks ADD COLUMN all_day BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE Tasks SET all_day = TRUE WHERE deadline IS NOT NULL;
+
+ALTER TABLE Tasks
+    ALTER COLUMN creation_date DROP DEFAULT,
+    ALTER COLUMN creation_date TYPE TIMESTAMPTZ USING creation_date::timestamp AT TIME ZONE 'UTC',
+    ALTER COLUMN creation_date SET DEFAULT now(),
+    ALTER COLUMN deadline TYPE TIMESTAMPTZ USING deadline::timestamp AT TIME ZONE 'UTC';
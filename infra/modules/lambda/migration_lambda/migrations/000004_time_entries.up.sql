@@ -0,0 +1,16 @@
+-- Tracked time on tasks; a NULL ended_at marks a running timer
+CREATE TABLE Time_Entries (
+    entry_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id UUID NOT NULL REFERENCES Tasks(task_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES Users(user_id) ON DELETE CASCADE,
+    started_at TIMESTAMPTZ NOT NULL,
+    ended_at TIMESTAMPTZ,
+    note TEXT,
+    CHECK (ended_at IS NULL OR ended_at >= started_at)
+);
+
+CREATE INDEX time_entries_task_id_idx ON Time_Entries (task_id);
+CREATE INDEX time_entries_user_started_idx ON Time_Entries (user_id, started_at);
+
+-- At most one running timer per user
+CREATE UNIQUE INDEX time_entries_running_idx ON Time_Entries (user_id) WHERE ended_at IS NULL;
@@ -0,0 +1,7 @@
+ALTER TABLE Users
+    DROP COLUMN IF EXISTS daily_capacity_points,
+    DROP COLUMN IF EXISTS daily_capacity_minutes;
+
+ALTER TABLE Tasks
+    DROP COLUMN IF EXISTS estimate_points,
+    DROP COLUMN IF EXISTS estimate_minutes;
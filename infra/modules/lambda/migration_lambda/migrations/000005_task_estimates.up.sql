@@ -0,0 +1,9 @@
+-- Optional effort estimates on tasks, in minutes and/or story points
+ALTER TABLE Tasks
+    ADD COLUMN estimate_minutes INTEGER CHECK (estimate_minutes >= 0),
+    ADD COLUMN estimate_points INTEGER CHECK (estimate_points >= 0);
+
+-- Planned work per day before a day is flagged as overloaded; 0 disables the check
+ALTER TABLE Users
+    ADD COLUMN daily_capacity_minutes INTEGER NOT NULL DEFAULT 480 CHECK (daily_capacity_minutes >= 0),
+    ADD COLUMN daily_capacity_points INTEGER NOT NULL DEFAULT 0 CHECK (daily_capacity_points >= 0);
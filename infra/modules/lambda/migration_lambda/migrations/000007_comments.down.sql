@@ -0,0 +1,2 @@
+DROP TABLE IF EXISTS Notifications;
+DROP TABLE IF EXISTS Comments;
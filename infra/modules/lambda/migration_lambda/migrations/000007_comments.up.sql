@@ -0,0 +1,29 @@
+-- Threaded markdown comments on tasks; replies point at their parent comment
+CREATE TABLE Comments (
+    comment_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    task_id UUID NOT NULL REFERENCES Tasks(task_id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES Users(user_id) ON DELETE CASCADE,
+    parent_id UUID REFERENCES Comments(comment_id) ON DELETE CASCADE,
+    body TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    deleted_at TIMESTAMPTZ  -- set on comments deleted while they still have replies
+);
+
+CREATE INDEX comments_task_id_idx ON Comments (task_id, created_at);
+
+CREATE TABLE Notifications (
+    notification_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES Users(user_id) ON DELETE CASCADE,
+    actor_id UUID REFERENCES Users(user_id) ON DELETE SET NULL,
+    kind VARCHAR(32) NOT NULL,
+    task_id UUID REFERENCES Tasks(task_id) ON DELETE CASCADE,
+    comment_id UUID REFERENCES Comments(comment_id) ON DELETE CASCADE,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    read_at TIMESTAMPTZ
+);
+
+CREATE INDEX notifications_user_id_idx ON Notifications (user_id, created_at);
+
+-- Editing a comment must not notify the same person twice
+CREATE UNIQUE INDEX notifications_mention_idx ON Notifications (comment_id, user_id) WHERE kind = 'MENTION';
@@ -0,0 +1,14 @@
+-- Reusable task blueprints; deadline_offset is relative to instantiation, e.g. '+3d'
+CREATE TABLE Task_Templates (
+    template_id UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    user_id UUID NOT NULL REFERENCES Users(user_id) ON DELETE CASCADE,
+    name VARCHAR(100) NOT NULL,
+    title VARCHAR(255) NOT NULL,  -- may hold placeholders, the rendered title must still fit Tasks.title
+    description TEXT,
+    priority task_priority NOT NULL,
+    deadline_offset VARCHAR(16),
+    estimate_minutes INTEGER CHECK (estimate_minutes >= 0),
+    estimate_points INTEGER CHECK (estimate_points >= 0),
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    UNIQUE (user_id, name)
+);
@@ -0,0 +1,7 @@
+ALTER TABLE Users DROP COLUMN IF EXISTS auto_archive_days;
+
+DROP INDEX IF EXISTS tasks_user_archived_idx;
+
+ALTER TABLE Tasks
+    DROP COLUMN IF EXISTS completed_at,
+    DROP COLUMN IF EXISTS archived_at;
@@ -0,0 +1,13 @@
+-- Archived tasks stay in the database but drop out of the default listing
+ALTER TABLE Tasks
+    ADD COLUMN archived_at TIMESTAMPTZ,
+    ADD COLUMN completed_at TIMESTAMPTZ;
+
+-- Completion wasn't recorded before, so existing DONE tasks count as completed now
+-- rather than being swept up by the first auto-archive run
+UPDATE Tasks SET completed_at = now() WHERE status = 'DONE';
+
+CREATE INDEX tasks_user_archived_idx ON Tasks (user_id, archived_at);
+
+-- Archive DONE tasks this many days after completion; NULL disables auto-archiving
+ALTER TABLE Users ADD COLUMN auto_archive_days INTEGER CHECK (auto_archive_days > 0);
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS tasks_user_completed_idx;
+
+ALTER TABLE Tasks DROP COLUMN IF EXISTS started_at;
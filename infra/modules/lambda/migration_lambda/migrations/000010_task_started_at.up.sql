@@ -0,0 +1,4 @@
+-- When work on a task first started; unknown (NULL) for tasks started before this was tracked
+ALTER TABLE Tasks ADD COLUMN started_at TIMESTAMPTZ;
+
+CREATE INDEX tasks_user_completed_idx ON Tasks (user_id, completed_at);
@@ -0,0 +1,4 @@
+ALTER TABLE Users
+    DROP COLUMN IF EXISTS digest_sent_at,
+    DROP COLUMN IF EXISTS digest_hour,
+    DROP COLUMN IF EXISTS digest_frequency;
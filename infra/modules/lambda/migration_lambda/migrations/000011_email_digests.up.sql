@@ -0,0 +1,6 @@
+-- Opt-in summary emails, sent at digest_hour in the user's timezone.
+-- Weekly digests go out on the user's first day of the week.
+ALTER TABLE Users
+    ADD COLUMN digest_frequency VARCHAR(8) NOT NULL DEFAULT 'NONE' CHECK (digest_frequency IN ('NONE', 'DAILY', 'WEEKLY')),
+    ADD COLUMN digest_hour SMALLINT NOT NULL DEFAULT 8 CHECK (digest_hour BETWEEN 0 AND 23),
+    ADD COLUMN digest_sent_at TIMESTAMPTZ;
@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS tasks_labels_idx;
+
+ALTER TABLE Tasks DROP COLUMN IF EXISTS labels;
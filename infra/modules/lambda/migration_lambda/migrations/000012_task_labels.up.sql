@@ -0,0 +1,4 @@
+-- Free-form lowercase labels, e.g. from "#finance" in a quick-add line
+ALTER TABLE Tasks ADD COLUMN labels TEXT[] NOT NULL DEFAULT '{}';
+
+CREATE INDEX tasks_labels_idx ON Tasks USING GIN (labels);
@@ -0,0 +1,16 @@
+-- Pending device-code logins for the CLI (RFC 8628). device_code holds the
+-- SHA-256 of the code handed to the device; the tokens are filled in when the
+-- user approves in the browser and the row is removed once the device collects them.
+CREATE TABLE Device_Authorizations (
+    device_code CHAR(64) PRIMARY KEY,
+    user_code CHAR(8) NOT NULL UNIQUE,
+    user_id UUID REFERENCES Users(user_id) ON DELETE CASCADE,
+    id_token TEXT,
+    refresh_token TEXT,
+    denied BOOLEAN NOT NULL DEFAULT false,
+    polled_at TIMESTAMPTZ,
+    expires_at TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX device_authorizations_expires_idx ON Device_Authorizations (expires_at);
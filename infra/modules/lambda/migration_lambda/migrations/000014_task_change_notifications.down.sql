@@ -0,0 +1,3 @@
+DROP TRIGGER IF EXISTS tasks_notify_change ON Tasks;
+
+DROP FUNCTION IF EXISTS notify_task_change();
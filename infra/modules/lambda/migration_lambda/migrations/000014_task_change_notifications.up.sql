@@ -0,0 +1,24 @@
+-- Announce every change to a task on the task_changes channel, so the gRPC
+-- WatchTasks stream sees writes from any replica, transport or background job.
+CREATE FUNCTION notify_task_change() RETURNS trigger AS $$
+DECLARE
+    changed Tasks;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+
+    PERFORM pg_notify('task_changes', json_build_object(
+        'op', TG_OP,
+        'user_id', changed.user_id,
+        'task_id', changed.task_id
+    )::text);
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER tasks_notify_change
+    AFTER INSERT OR UPDATE OR DELETE ON Tasks
+    FOR EACH ROW EXECUTE FUNCTION notify_task_change();
@@ -0,0 +1,4 @@
+-- The project a task belongs to, for grouping tracked time; '' means none
+ALTER TABLE Tasks ADD COLUMN project VARCHAR(50) NOT NULL DEFAULT '';
+
+CREATE INDEX tasks_user_project_idx ON Tasks (user_id, project);
@@ -67,3 +67,7 @@ output "cloudfront_domain" {
   description = "Use this value to update allowed_origins in subsequent apply"
 }
 
+output "grpc_consumers_sg_id" {
+    description = "Security group for services allowed to call the tasks gRPC API"
+    value       = aws_security_group.grpc_consumers_sg.id
+}
@@ -0,0 +1,253 @@
+// Package auth verifies the ID and access tokens the TaskNest Cognito user
+// pool issues, for services that check them themselves rather than relying
+// on the API Gateway authorizer alone.
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// keyTTL is how long a fetched key set is trusted before it is fetched again.
+const keyTTL = 24 * time.Hour
+
+// refetchInterval is how long a lookup of an unknown key ID waits after the
+// last fetch before fetching the key set again. Cognito rotates keys rarely,
+// so a burst of tokens with made-up key IDs costs one fetch, not one each.
+const refetchInterval = time.Minute
+
+var tracer = otel.Tracer("auth")
+
+var (
+	jwksCacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_jwks_cache_requests_total",
+		Help: "Signing key lookups by whether the JWKS cache had the key (hit), the key set had to be fetched (miss) or it was fetched too recently to try again (throttled).",
+	}, []string{"result"})
+
+	jwksFetchErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tasknest_jwks_fetch_errors_total",
+		Help: "Failed attempts to fetch the JWKS from Cognito.",
+	})
+)
+
+type jwks struct {
+	Keys []jwk `json:"keys"`
+}
+
+type jwk struct {
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Kty string `json:"kty"`
+	E   string `json:"e"`
+	N   string `json:"n"`
+	Use string `json:"use"`
+}
+
+// KeySet caches the RSA keys published at a JWKS URL by key ID. A key ID the
+// cache doesn't know triggers a fetch, so rotated keys are picked up, but at
+// most once every refetchInterval.
+type KeySet struct {
+	url    string
+	client *http.Client
+
+	mu   sync.RWMutex
+	keys map[string]*rsa.PublicKey
+	exp  time.Time
+
+	// fetched is when Key last tried to fetch the set and fetchErr how that
+	// went, so lookups in the meantime fail the same way without a fetch.
+	fetched  time.Time
+	fetchErr error
+}
+
+// NewKeySet returns an empty key set fetched from jwksURL with client.
+func NewKeySet(jwksURL string, client *http.Client) *KeySet {
+	return &KeySet{url: jwksURL, client: client}
+}
+
+// Key returns the public key with the given key ID.
+func (s *KeySet) Key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	// Check cache first
+	s.mu.RLock()
+	if s.exp.After(time.Now()) {
+		if key, exists := s.keys[kid]; exists {
+			s.mu.RUnlock()
+			jwksCacheRequests.WithLabelValues("hit").Inc()
+			return key, nil
+		}
+	}
+	s.mu.RUnlock()
+
+	// Cache miss or expired, fetch new JWKS
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Double-check after acquiring write lock
+	if s.exp.After(time.Now()) {
+		if key, exists := s.keys[kid]; exists {
+			jwksCacheRequests.WithLabelValues("hit").Inc()
+			return key, nil
+		}
+	}
+	if time.Since(s.fetched) < refetchInterval {
+		jwksCacheRequests.WithLabelValues("throttled").Inc()
+		if s.fetchErr != nil {
+			return nil, s.fetchErr
+		}
+		return nil, fmt.Errorf("key ID %s not found in JWKS", kid)
+	}
+	jwksCacheRequests.WithLabelValues("miss").Inc()
+
+	s.fetched = time.Now()
+	keys, err := s.fetch(ctx)
+	s.fetchErr = err
+	if err != nil {
+		jwksFetchErrors.Inc()
+		return nil, err
+	}
+	s.keys = keys
+	s.exp = time.Now().Add(keyTTL)
+
+	// Return requested key
+	if key, exists := s.keys[kid]; exists {
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("key ID %s not found in JWKS", kid)
+}
+
+// Refresh fetches the key set unless the cache still holds a current one,
+// so readiness checks don't call Cognito on every probe.
+func (s *KeySet) Refresh(ctx context.Context) error {
+	s.mu.RLock()
+	fresh := s.exp.After(time.Now())
+	s.mu.RUnlock()
+	if fresh {
+		return nil
+	}
+
+	keys, err := s.fetch(ctx)
+	if err != nil {
+		jwksFetchErrors.Inc()
+		return err
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("no RSA keys in JWKS")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.exp = time.Now().Add(keyTTL)
+	return nil
+}
+
+// Set replaces the cached keys, which tests use to trust their own signing
+// keys without serving a JWKS.
+func (s *KeySet) Set(keys map[string]*rsa.PublicKey) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+	s.exp = time.Now().Add(keyTTL)
+}
+
+// Expire makes the next lookup fetch the key set again.
+func (s *KeySet) Expire() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.exp = time.Time{}
+	s.fetched = time.Time{}
+}
+
+// fetch downloads the key set and parses its RSA keys by key ID.
+func (s *KeySet) fetch(ctx context.Context) (keys map[string]*rsa.PublicKey, err error) {
+	ctx, span := tracer.Start(ctx, "fetch JWKS")
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+		}
+		span.SetAttributes(attribute.Int("jwks.keys", len(keys)))
+		span.End()
+	}()
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status code %d", resp.StatusCode)
+	}
+
+	var set jwks
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %v", err)
+	}
+
+	keys = make(map[string]*rsa.PublicKey)
+
+	// Parse all keys in the JWKS
+	for _, key := range set.Keys {
+		if key.Kty != "RSA" {
+			continue // Skip non-RSA keys
+		}
+
+		// Decode the modulus (n) and exponent (e)
+		nBytes, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			continue
+		}
+
+		eBytes, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			continue
+		}
+
+		// Convert exponent bytes to int
+		var eInt uint64
+		switch len(eBytes) {
+		case 4:
+			eInt = uint64(binary.BigEndian.Uint32(eBytes))
+		case 8:
+			eInt = binary.BigEndian.Uint64(eBytes)
+		default:
+			// Handle non-standard exponent size
+			var e big.Int
+			e.SetBytes(eBytes)
+			if !e.IsUint64() {
+				continue
+			}
+			eInt = e.Uint64()
+		}
+
+		// Create RSA public key
+		pubKey := &rsa.PublicKey{
+			N: new(big.Int).SetBytes(nBytes),
+			E: int(eInt),
+		}
+
+		keys[key.Kid] = pubKey
+	}
+
+	return keys, nil
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/golang-jwt/jwt"
+)
+
+// Issuer is the iss claim of the tokens a user pool issues.
+func Issuer(region, userPoolID string) string {
+	return fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", region, userPoolID)
+}
+
+// JWKSURL is where a user pool publishes the keys its tokens are signed with.
+func JWKSURL(region, userPoolID string) string {
+	return Issuer(region, userPoolID) + "/.well-known/jwks.json"
+}
+
+// Verifier checks tokens issued by a user pool to one app client.
+type Verifier struct {
+	Keys     *KeySet
+	issuer   string
+	clientID string
+}
+
+// NewVerifier returns a verifier for tokens the user pool issues to clientID,
+// fetching the pool's keys with client.
+func NewVerifier(region, userPoolID, clientID string, client *http.Client) *Verifier {
+	return &Verifier{
+		Keys:     NewKeySet(JWKSURL(region, userPoolID), client),
+		issuer:   Issuer(region, userPoolID),
+		clientID: clientID,
+	}
+}
+
+// Verify checks the signature, expiry, issuer and app client of an ID or
+// access token and returns its claims.
+func (v *Verifier) Verify(ctx context.Context, token string) (jwt.MapClaims, error) {
+	return v.verify(ctx, token, true)
+}
+
+// VerifyID is Verify for ID tokens only, the ones carrying the user's profile.
+func (v *Verifier) VerifyID(ctx context.Context, token string) (jwt.MapClaims, error) {
+	return v.verify(ctx, token, false)
+}
+
+func (v *Verifier) verify(ctx context.Context, token string, allowAccess bool) (jwt.MapClaims, error) {
+	// Parse checks the signature and the expiry
+	parsed, err := jwt.Parse(token, func(token *jwt.Token) (any, error) {
+		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, fmt.Errorf("kid not found in token header")
+		}
+		key, err := v.Keys.Key(ctx, kid)
+		if err != nil {
+			return nil, fmt.Errorf("error getting public key: %v", err)
+		}
+		return key, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error verifying token: %v", err)
+	}
+
+	claims, ok := parsed.Claims.(jwt.MapClaims)
+	if !ok || !parsed.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	if !claims.VerifyIssuer(v.issuer, true) {
+		return nil, fmt.Errorf("invalid issuer")
+	}
+
+	// ID tokens name the app client in aud, access tokens in client_id
+	switch use := claims["token_use"]; use {
+	case "id", nil:
+		if !claims.VerifyAudience(v.clientID, true) {
+			return nil, fmt.Errorf("invalid audience")
+		}
+	case "access":
+		if !allowAccess {
+			return nil, fmt.Errorf("access token where an ID token is required")
+		}
+		if clientID, _ := claims["client_id"].(string); clientID != v.clientID {
+			return nil, fmt.Errorf("invalid client")
+		}
+	default:
+		return nil, fmt.Errorf("unexpected token use %v", use)
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,197 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+)
+
+const (
+	testRegion   = "us-east-1"
+	testPool     = "us-east-1_TEST"
+	testClientID = "tasknest-web"
+	testKeyID    = "test-key"
+)
+
+// newTestVerifier returns a verifier whose keys come from a JWKS served by the
+// test, along with the key that signs trusted tokens and a count of fetches.
+func newTestVerifier(t *testing.T) (*Verifier, *rsa.PrivateKey, *int) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		json.NewEncoder(w).Encode(jwks{Keys: []jwk{
+			{Kid: "ec-key", Kty: "EC"},
+			{
+				Kid: testKeyID,
+				Kty: "RSA",
+				Alg: "RS256",
+				N:   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			},
+		}})
+	}))
+	t.Cleanup(server.Close)
+
+	v := NewVerifier(testRegion, testPool, testClientID, server.Client())
+	v.Keys.url = server.URL
+	return v, key, &fetches
+}
+
+// sign issues a token with claims overriding those of a valid ID token.
+func sign(t *testing.T, key *rsa.PrivateKey, claims jwt.MapClaims) string {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"sub":       "6f1c2a9e-6d3b-4c55-9a43-2f4f0e0b8a11",
+		"token_use": "id",
+		"aud":       testClientID,
+		"iss":       Issuer(testRegion, testPool),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		if v == nil {
+			delete(all, k)
+			continue
+		}
+		all[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestVerify(t *testing.T) {
+	v, key, _ := newTestVerifier(t)
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access := jwt.MapClaims{"token_use": "access", "aud": nil, "client_id": testClientID}
+
+	tests := []struct {
+		name  string
+		token string
+		err   string
+	}{
+		{"id token", sign(t, key, nil), ""},
+		{"access token", sign(t, key, access), ""},
+		{"expired", sign(t, key, jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), "expired"},
+		{"other pool", sign(t, key, jwt.MapClaims{"iss": Issuer(testRegion, "us-east-1_OTHER")}), "invalid issuer"},
+		{"other client", sign(t, key, jwt.MapClaims{"aud": "someone-else"}), "invalid audience"},
+		{"access token for another client", sign(t, key, jwt.MapClaims{"token_use": "access", "client_id": "someone-else"}), "invalid client"},
+		{"forged", sign(t, otherKey, nil), "verification error"},
+		{"garbage", "not-a-token", "error verifying token"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims, err := v.Verify(context.Background(), tt.token)
+			if tt.err == "" {
+				if err != nil || claims["sub"] == nil {
+					t.Fatalf("claims = %v, err = %v", claims, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("err = %v, want it to mention %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestVerifyID(t *testing.T) {
+	v, key, _ := newTestVerifier(t)
+
+	if _, err := v.VerifyID(context.Background(), sign(t, key, nil)); err != nil {
+		t.Errorf("ID token rejected: %v", err)
+	}
+	access := sign(t, key, jwt.MapClaims{"token_use": "access", "aud": nil, "client_id": testClientID})
+	if _, err := v.VerifyID(context.Background(), access); err == nil {
+		t.Error("access token accepted in place of an ID token")
+	}
+}
+
+func TestKeySetCache(t *testing.T) {
+	v, key, fetches := newTestVerifier(t)
+	ctx := context.Background()
+
+	if err := v.Keys.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	for range 3 {
+		if _, err := v.Verify(ctx, sign(t, key, nil)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := v.Keys.Refresh(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if *fetches != 1 {
+		t.Errorf("%d fetches with a fresh cache, want 1", *fetches)
+	}
+
+	// A key ID the cache doesn't know may have been rotated in, but only the
+	// first lookup of a burst fetches the key set again
+	for range 3 {
+		if _, err := v.Keys.Key(ctx, "rotated"); err == nil {
+			t.Error("unknown key ID found")
+		}
+	}
+	if *fetches != 2 {
+		t.Errorf("%d fetches after looking up unknown key IDs, want 2", *fetches)
+	}
+	v.Keys.fetched = time.Now().Add(-refetchInterval)
+	if _, err := v.Keys.Key(ctx, "rotated"); err == nil {
+		t.Error("unknown key ID found")
+	}
+	v.Keys.Expire()
+	if _, err := v.Keys.Key(ctx, testKeyID); err != nil {
+		t.Fatal(err)
+	}
+	if *fetches != 4 {
+		t.Errorf("%d fetches, want 4", *fetches)
+	}
+
+	if _, err := v.Keys.Key(ctx, "ec-key"); err == nil {
+		t.Error("non-RSA key returned")
+	}
+}
+
+func TestKeySetBacksOffFailedFetches(t *testing.T) {
+	fetches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches++
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	t.Cleanup(server.Close)
+	keys := NewKeySet(server.URL, server.Client())
+
+	for range 3 {
+		if _, err := keys.Key(context.Background(), testKeyID); err == nil || !strings.Contains(err.Error(), "status code 503") {
+			t.Errorf("err = %v, want the fetch error", err)
+		}
+	}
+	if fetches != 1 {
+		t.Errorf("%d fetches while the JWKS is unavailable, want 1", fetches)
+	}
+}
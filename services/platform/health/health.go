@@ -0,0 +1,150 @@
+// Package health runs the readiness checks the services report on their
+// /ready endpoints, which the load balancer health checks point at.
+package health
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// RequiredSchemaVersion is the latest migration the services rely on. They
+// share one database and one set of migrations, so there's a single version
+// to bump together with the migration when the code starts using a new table
+// or column.
+const RequiredSchemaVersion = 15
+
+// Timeout bounds each check, so a hung dependency fails the check well within
+// the load balancer's health check timeout.
+const Timeout = 2 * time.Second
+
+// Check is one dependency of a service. A failing check takes the service out
+// of rotation unless it's Optional, in which case the failure is only reported.
+type Check struct {
+	Name     string
+	Run      func(ctx context.Context) error
+	Optional bool
+}
+
+// Result is the outcome of a check.
+type Result struct {
+	Name      string  `json:"name"`
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+	Optional  bool    `json:"optional,omitempty"`
+}
+
+// Report is "ok" when every check passed, "degraded" when only optional ones
+// failed and "failed" otherwise.
+type Report struct {
+	Status string   `json:"status"`
+	Checks []Result `json:"checks,omitempty"`
+}
+
+// Database pings the connection pool.
+func Database(db *gorm.DB) Check {
+	return Check{Name: "database", Run: func(ctx context.Context) error {
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}}
+}
+
+// Migrations fails until the migration Lambda has brought the schema up to
+// RequiredSchemaVersion, or when a migration was left half applied.
+func Migrations(db *gorm.DB) Check {
+	return Check{Name: "migrations", Run: func(ctx context.Context) error {
+		var version int64
+		var dirty bool
+		err := db.WithContext(ctx).Raw("SELECT version, dirty FROM schema_migrations").Row().Scan(&version, &dirty)
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			return errors.New("no migrations applied")
+		case err != nil:
+			return err
+		case dirty:
+			return fmt.Errorf("migration %d is dirty", version)
+		case version < RequiredSchemaVersion:
+			return fmt.Errorf("schema version %d, need %d", version, RequiredSchemaVersion)
+		}
+		return nil
+	}}
+}
+
+// Run runs the checks concurrently, each with its own timeout.
+func Run(ctx context.Context, checks []Check) Report {
+	report := Report{Status: "ok", Checks: make([]Result, len(checks))}
+
+	done := make(chan struct{})
+	for i, c := range checks {
+		go func() {
+			defer func() { done <- struct{}{} }()
+
+			ctx, cancel := context.WithTimeout(ctx, Timeout)
+			defer cancel()
+
+			start := time.Now()
+			err := c.Run(ctx)
+			result := Result{
+				Name:      c.Name,
+				Status:    "ok",
+				LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+				Optional:  c.Optional,
+			}
+			if err != nil {
+				result.Status = "failed"
+				result.Error = err.Error()
+			}
+			report.Checks[i] = result
+		}()
+	}
+	for range checks {
+		<-done
+	}
+
+	for _, c := range report.Checks {
+		switch {
+		case c.Status == "ok":
+		case c.Optional:
+			if report.Status == "ok" {
+				report.Status = "degraded"
+			}
+		default:
+			report.Status = "failed"
+		}
+	}
+	return report
+}
+
+// HandleLiveness answers 200 as long as the process is serving requests.
+func HandleLiveness(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(Report{Status: "ok"})
+}
+
+// NewReadinessHandler reports the checks, with a 503 when one that isn't
+// optional failed.
+func NewReadinessHandler(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		report := Run(r.Context(), checks)
+
+		status := http.StatusOK
+		if report.Status == "failed" {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(report)
+	}
+}
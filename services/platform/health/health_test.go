@@ -0,0 +1,114 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"platform/testdb"
+)
+
+func TestLiveness(t *testing.T) {
+	rec := httptest.NewRecorder()
+	HandleLiveness(rec, httptest.NewRequest("GET", "/api/tasks/live", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	var report Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil || report.Status != "ok" || len(report.Checks) != 0 {
+		t.Errorf("report = %+v, %v", report, err)
+	}
+}
+
+func TestReadiness(t *testing.T) {
+	pass := Check{Name: "pass", Run: func(ctx context.Context) error {
+		if _, ok := ctx.Deadline(); !ok {
+			return errors.New("no deadline")
+		}
+		time.Sleep(5 * time.Millisecond)
+		return nil
+	}}
+	fail := Check{Name: "fail", Run: func(ctx context.Context) error {
+		return errors.New("connection refused")
+	}}
+	optional := Check{Name: "jwks", Optional: true, Run: func(ctx context.Context) error {
+		return errors.New("failed to fetch JWKS")
+	}}
+
+	tests := []struct {
+		name   string
+		checks []Check
+		status int
+		report string
+		want   []Result
+	}{
+		{"all pass", []Check{pass}, http.StatusOK, "ok", []Result{{Name: "pass", Status: "ok"}}},
+		{"one fails", []Check{pass, fail}, http.StatusServiceUnavailable, "failed", []Result{
+			{Name: "pass", Status: "ok"},
+			{Name: "fail", Status: "failed", Error: "connection refused"},
+		}},
+		{"an optional one fails", []Check{pass, optional}, http.StatusOK, "degraded", []Result{
+			{Name: "pass", Status: "ok"},
+			{Name: "jwks", Status: "failed", Error: "failed to fetch JWKS", Optional: true},
+		}},
+		{"both fail", []Check{optional, fail}, http.StatusServiceUnavailable, "failed", []Result{
+			{Name: "jwks", Status: "failed", Error: "failed to fetch JWKS", Optional: true},
+			{Name: "fail", Status: "failed", Error: "connection refused"},
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewReadinessHandler(tt.checks...)(rec, httptest.NewRequest("GET", "/api/tasks/ready", nil))
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+
+			var report Report
+			if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+				t.Fatal(err)
+			}
+			if report.Status != tt.report || len(report.Checks) != len(tt.want) {
+				t.Fatalf("report = %+v", report)
+			}
+			for i, check := range report.Checks {
+				if check.Name == "pass" && check.LatencyMs < 5 {
+					t.Errorf("latency = %vms, want at least 5ms", check.LatencyMs)
+				}
+				check.LatencyMs = 0
+				if check != tt.want[i] {
+					t.Errorf("check %d = %+v, want %+v", i, check, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// TestRequiredSchemaVersion keeps RequiredSchemaVersion from running ahead of
+// the migrations, which would keep every service out of rotation.
+func TestRequiredSchemaVersion(t *testing.T) {
+	up := filepath.Join(testdb.MigrationsDir, "*.up.sql")
+	files, err := filepath.Glob(up)
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations in %s: %v", testdb.MigrationsDir, err)
+	}
+	var latest int
+	for _, file := range files {
+		var version int
+		if _, err := fmt.Sscanf(filepath.Base(file), "%d_", &version); err != nil {
+			t.Fatalf("%s: %v", file, err)
+		}
+		latest = max(latest, version)
+	}
+	if RequiredSchemaVersion > latest {
+		t.Errorf("RequiredSchemaVersion is %d, but the latest migration is %d", RequiredSchemaVersion, latest)
+	}
+}
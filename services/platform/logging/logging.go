@@ -0,0 +1,236 @@
+// Package logging sets up the services' structured logs: JSON on stdout with
+// credentials and email addresses redacted, and request loggers carrying the
+// request, trace and user IDs.
+package logging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Level is the minimum level written. It starts at LOG_LEVEL and can be
+// changed while a service runs through /loglevel on the metrics port.
+var Level = new(slog.LevelVar)
+
+// Init makes slog write JSON to stdout from level on, which the log package
+// follows too, so libraries logging through it end up in the same stream.
+func Init(level string) error {
+	if err := Level.UnmarshalText([]byte(level)); err != nil {
+		return fmt.Errorf("invalid LOG_LEVEL: %w", err)
+	}
+	slog.SetDefault(slog.New(NewHandler(os.Stdout)))
+	return nil
+}
+
+// NewHandler returns the JSON handler Init installs, writing to w.
+func NewHandler(w io.Writer) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{Level: Level, ReplaceAttr: redactAttr})
+}
+
+// Fatal logs msg and exits, for startup failures.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+var (
+	emailPattern = regexp.MustCompile(`[A-Za-z0-9._%+\-]+@([A-Za-z0-9\-]+\.)+[A-Za-z]{2,}`)
+	// JWTs, such as Cognito ID and access tokens, and bearer credentials
+	tokenPattern = regexp.MustCompile(`eyJ[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*\.[A-Za-z0-9_\-]*|(?i:bearer)\s+\S+`)
+)
+
+// redactAttr keeps credentials and email addresses out of the logs, both in
+// attributes named after them and inside messages and error strings.
+func redactAttr(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case strings.Contains(key, "token"), strings.Contains(key, "secret"), strings.Contains(key, "password"),
+		key == "authorization", key == "cookie":
+		return slog.String(a.Key, "[REDACTED]")
+	}
+
+	switch a.Value.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, Redact(a.Value.String()))
+	case slog.KindAny:
+		switch v := a.Value.Any().(type) {
+		case error:
+			return slog.String(a.Key, Redact(v.Error()))
+		case fmt.Stringer:
+			return slog.String(a.Key, Redact(v.String()))
+		}
+	}
+	return a
+}
+
+// Redact masks email addresses down to their domain and drops tokens.
+func Redact(s string) string {
+	s = tokenPattern.ReplaceAllString(s, "[REDACTED]")
+	return emailPattern.ReplaceAllStringFunc(s, func(email string) string {
+		return "***@" + email[strings.LastIndex(email, "@")+1:]
+	})
+}
+
+// requestInfo is what the loggers know about the request being served. It's
+// shared by every request derived from the one WithRequestID saw, so the
+// access log line picks up the user authentication resolved further down.
+type requestInfo struct {
+	id     string
+	userID string
+}
+
+type requestInfoKey struct{}
+
+// RequestIDHeader carries the request ID in both directions.
+const RequestIDHeader = "X-Request-ID"
+
+// WithRequestID tags every request with an ID, reusing the one the API
+// Gateway or the caller sent if it looks sane, and echoes it back so a user
+// reporting a problem can quote it.
+func WithRequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = uuid.NewString()
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestInfoKey{}, &requestInfo{id: id})))
+	})
+}
+
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for _, c := range id {
+		if c <= ' ' || c > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// SetUser records the user a request is served for, once authentication has
+// resolved it. Only IDs that were verified belong here, never a header the
+// caller could have made up.
+func SetUser(ctx context.Context, userID uuid.UUID) {
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		info.userID = userID.String()
+	}
+}
+
+// ContextLogger returns a logger carrying the request, trace and user IDs
+// found in ctx.
+func ContextLogger(ctx context.Context) *slog.Logger {
+	logger := slog.Default()
+	if info, ok := ctx.Value(requestInfoKey{}).(*requestInfo); ok {
+		logger = logger.With("request_id", info.id)
+		if info.userID != "" {
+			logger = logger.With("user_id", info.userID)
+		}
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		logger = logger.With("trace_id", span.TraceID().String())
+	}
+	return logger
+}
+
+// RequestLogger returns a logger for a request, carrying its route on top of
+// the IDs ContextLogger adds.
+func RequestLogger(r *http.Request) *slog.Logger {
+	logger := ContextLogger(r.Context())
+	if r.Pattern != "" {
+		logger = logger.With("route", r.Pattern)
+	}
+	return logger
+}
+
+// StatusRecorder remembers the status code a handler answered with.
+type StatusRecorder struct {
+	http.ResponseWriter
+	Status int
+}
+
+func (r *StatusRecorder) WriteHeader(status int) {
+	if r.Status == 0 {
+		r.Status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *StatusRecorder) Write(b []byte) (int, error) {
+	if r.Status == 0 {
+		r.Status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(b)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *StatusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// LogRequests writes an access log line for every request once it is served.
+func LogRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &StatusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		level := slog.LevelInfo
+		if rec.Status >= http.StatusInternalServerError {
+			level = slog.LevelError
+		}
+		RequestLogger(r).Log(r.Context(), level, "Request served",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.Status,
+			"duration_ms", time.Since(start).Milliseconds(),
+		)
+	})
+}
+
+// LevelBody is the body of the /loglevel endpoints.
+type LevelBody struct {
+	Level string `json:"level" example:"DEBUG"`
+}
+
+// HandleGetLevel reports the current log level.
+func HandleGetLevel(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(LevelBody{Level: Level.Level().String()})
+}
+
+// HandleSetLevel changes the log level until the service restarts.
+func HandleSetLevel(w http.ResponseWriter, r *http.Request) {
+	var req LevelBody
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(req.Level)); err != nil {
+		http.Error(w, "Invalid log level", http.StatusBadRequest)
+		return
+	}
+	if previous := Level.Level(); previous != level {
+		Level.Set(level)
+		slog.Warn("Log level changed", "from", previous.String(), "to", level.String())
+	}
+	HandleGetLevel(w, r)
+}
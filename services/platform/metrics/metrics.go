@@ -0,0 +1,115 @@
+// Package metrics exports the Prometheus metrics the services share: HTTP
+// request counts and latencies per route and GORM statement latencies.
+//
+// Metrics are served on their own port (METRICS_PORT), along with the log
+// level switch, so they never go through the load balancer.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"platform/logging"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_http_requests_total",
+		Help: "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tasknest_http_request_duration_seconds",
+		Help:    "HTTP request latency by route pattern.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "tasknest_db_query_duration_seconds",
+		Help:    "GORM statement latency by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+)
+
+// Handler records request counts and latencies per route pattern, such as
+// "PUT /api/tasks/update/{id}", rather than per path, so task IDs and
+// scanners probing random paths don't blow up the number of series. The mux
+// fills in the pattern while routing; requests that matched no route are
+// counted as "unmatched".
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &logging.StatusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		route := r.Pattern
+		if route == "" {
+			route = "unmatched"
+		}
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		httpRequests.WithLabelValues(route, r.Method, strconv.Itoa(rec.Status)).Inc()
+		httpDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+	})
+}
+
+// GORM is a GORM plugin timing every statement GORM runs.
+type GORM struct{}
+
+func (GORM) Name() string {
+	return "metrics"
+}
+
+const gormStartKey = "metrics:start"
+
+func (GORM) Initialize(db *gorm.DB) error {
+	before := func(tx *gorm.DB) {
+		tx.InstanceSet(gormStartKey, time.Now())
+	}
+	after := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			start, ok := tx.InstanceGet(gormStartKey)
+			if !ok {
+				return
+			}
+			dbQueryDuration.WithLabelValues(operation, tx.Statement.Table).Observe(time.Since(start.(time.Time)).Seconds())
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("metrics:before_create", before),
+		cb.Create().After("gorm:create").Register("metrics:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("metrics:before_query", before),
+		cb.Query().After("gorm:query").Register("metrics:after_query", after("query")),
+		cb.Update().Before("gorm:update").Register("metrics:before_update", before),
+		cb.Update().After("gorm:update").Register("metrics:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("metrics:before_delete", before),
+		cb.Delete().After("gorm:delete").Register("metrics:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("metrics:before_row", before),
+		cb.Row().After("gorm:row").Register("metrics:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("metrics:before_raw", before),
+		cb.Raw().After("gorm:raw").Register("metrics:after_raw", after("raw")),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewMux serves the internal endpoints: the metrics and the log level.
+func NewMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", promhttp.Handler())
+	mux.HandleFunc("GET /loglevel", logging.HandleGetLevel)
+	mux.HandleFunc("PUT /loglevel", logging.HandleSetLevel)
+	return mux
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/logging"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestHandler(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/tasks/update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if r.PathValue("id") == "missing" {
+			http.Error(w, "Task not found", http.StatusNotFound)
+			return
+		}
+		w.Write([]byte("{}"))
+	})
+	handler := Handler(mux)
+
+	requests := func(route, method, code string) float64 {
+		return testutil.ToFloat64(httpRequests.WithLabelValues(route, method, code))
+	}
+	route := "PUT /api/tasks/update/{id}"
+	ok, notFound, unmatched := requests(route, "PUT", "200"), requests(route, "PUT", "404"), requests("unmatched", "GET", "404")
+
+	for _, path := range []string{"/api/tasks/update/1", "/api/tasks/update/2", "/api/tasks/update/missing"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("PUT", path, nil))
+	}
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/tasks/nowhere", nil))
+
+	if got := requests(route, "PUT", "200") - ok; got != 2 {
+		t.Errorf("%v successful updates counted, want 2", got)
+	}
+	if got := requests(route, "PUT", "404") - notFound; got != 1 {
+		t.Errorf("%v failed updates counted, want 1", got)
+	}
+	if got := requests("unmatched", "GET", "404") - unmatched; got != 1 {
+		t.Errorf("%v unmatched requests counted, want 1", got)
+	}
+
+	// Paths never become labels
+	if got := requests("PUT /api/tasks/update/1", "PUT", "200"); got != 0 {
+		t.Errorf("request path used as a route label")
+	}
+}
+
+func TestNewMux(t *testing.T) {
+	t.Cleanup(func() { logging.Level.Set(slog.LevelInfo) })
+	mux := NewMux()
+
+	serve := func(method, path, body string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+		return rec
+	}
+	level := func(rec *httptest.ResponseRecorder) string {
+		var body logging.LevelBody
+		if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+			t.Fatal(err)
+		}
+		return body.Level
+	}
+
+	if rec := serve("GET", "/metrics", ""); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "go_goroutines") {
+		t.Errorf("GET /metrics = %d", rec.Code)
+	}
+
+	rec := serve("PUT", "/loglevel", `{"level": "debug"}`)
+	if got := level(rec); rec.Code != http.StatusOK || got != "DEBUG" || logging.Level.Level() != slog.LevelDebug {
+		t.Errorf("PUT /loglevel = %d, level %q, %v", rec.Code, got, logging.Level.Level())
+	}
+	if rec := serve("PUT", "/loglevel", `{"level": "loud"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("unknown level answered %d", rec.Code)
+	}
+	if got := level(serve("GET", "/loglevel", "")); got != "DEBUG" {
+		t.Errorf("level = %q after a rejected change", got)
+	}
+}
@@ -0,0 +1,91 @@
+// Package middleware holds the HTTP middleware every service wraps its
+// routes in.
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+
+	"platform/logging"
+)
+
+// Middleware wraps a handler with behaviour shared between routes.
+type Middleware func(http.Handler) http.Handler
+
+// Chain wraps h in mws, the first of which sees the request first.
+func Chain(h http.Handler, mws ...Middleware) http.Handler {
+	for i := len(mws) - 1; i >= 0; i-- {
+		h = mws[i](h)
+	}
+	return h
+}
+
+// LimitBody caps request bodies at n bytes. Handlers reading past the limit
+// get an error, and the connection is closed once they answer.
+func LimitBody(n int64) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, n)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RecoverPanics turns a panicking handler into a 500 and a logged stack
+// trace instead of a dropped connection.
+func RecoverPanics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		rec := &logging.StatusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if p == http.ErrAbortHandler {
+				panic(p)
+			}
+			logging.RequestLogger(r).Error("Handler panicked", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+			if rec.Status == 0 {
+				http.Error(rec, "Internal Server Error", http.StatusInternalServerError)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
+
+// CORS lets the frontend call the API from the browser with its cookies.
+// Requests from any other origin get no CORS headers, so browsers block
+// them.
+func CORS(frontendURL string) Middleware {
+	origin := ""
+	if u, err := url.Parse(frontendURL); err == nil && u.Scheme != "" && u.Host != "" {
+		origin = u.Scheme + "://" + u.Host
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("Vary", "Origin")
+			if origin == "" || r.Header.Get("Origin") != origin {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("Access-Control-Allow-Origin", origin)
+			h.Set("Access-Control-Allow-Credentials", "true")
+			h.Set("Access-Control-Expose-Headers", logging.RequestIDHeader)
+
+			// Answer preflights here, the muxes have no OPTIONS routes
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Set("Access-Control-Allow-Methods", "GET, POST, PUT, PATCH, DELETE")
+				h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type, "+logging.RequestIDHeader)
+				h.Set("Access-Control-Max-Age", "600")
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
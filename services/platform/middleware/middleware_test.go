@@ -0,0 +1,143 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/logging"
+)
+
+// expectStatus fails the test unless rec answered with status.
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Errorf("status = %d, want %d: %s", rec.Code, status, rec.Body.String())
+	}
+}
+
+func TestChain(t *testing.T) {
+	var order []string
+	tag := func(name string) Middleware {
+		return func(next http.Handler) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				order = append(order, name)
+				next.ServeHTTP(w, r)
+			})
+		}
+	}
+
+	h := Chain(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		order = append(order, "handler")
+	}), tag("outer"), tag("inner"))
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+
+	if got := strings.Join(order, ","); got != "outer,inner,handler" {
+		t.Errorf("order = %s", got)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(&buf)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	h := RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("wrote") {
+			w.WriteHeader(http.StatusAccepted)
+		}
+		var deadline *string
+		_ = *deadline
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/tasks/capacity", nil))
+	expectStatus(t, rec, http.StatusInternalServerError)
+	if out := buf.String(); !strings.Contains(out, "Handler panicked") || !strings.Contains(out, "middleware_test.go") {
+		t.Errorf("panic log = %s", out)
+	}
+
+	// Once the status is out the handler's answer stands
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/tasks/capacity?wrote", nil))
+	expectStatus(t, rec, http.StatusAccepted)
+
+	// http.ErrAbortHandler is the server's to handle
+	defer func() {
+		if p := recover(); p != http.ErrAbortHandler {
+			t.Errorf("recovered %v, want http.ErrAbortHandler", p)
+		}
+	}()
+	RecoverPanics(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})).ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+}
+
+func TestCORS(t *testing.T) {
+	called := false
+	h := CORS("https://tasknest.example.com/")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+
+	serve := func(method, origin string, preflight bool) *httptest.ResponseRecorder {
+		called = false
+		req := httptest.NewRequest(method, "/api/tasks/read", nil)
+		req.Header.Set("Origin", origin)
+		if preflight {
+			req.Header.Set("Access-Control-Request-Method", "PUT")
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve("GET", "https://tasknest.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://tasknest.example.com" || rec.Header().Get("Access-Control-Allow-Credentials") != "true" || !called {
+		t.Errorf("frontend request: headers %v, handler called %v", rec.Header(), called)
+	}
+
+	rec = serve("OPTIONS", "https://tasknest.example.com", true)
+	expectStatus(t, rec, http.StatusNoContent)
+	if !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "PUT") || called {
+		t.Errorf("preflight: headers %v, handler called %v", rec.Header(), called)
+	}
+
+	rec = serve("GET", "https://evil.example.com", false)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" || rec.Header().Get("Vary") != "Origin" {
+		t.Errorf("other origin got headers %v", rec.Header())
+	}
+
+	// Without a frontend URL no origin is allowed
+	h = CORS("")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/api/tasks/read", nil)
+	req.Header.Set("Origin", "https://tasknest.example.com")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("got headers %v without a frontend URL", rec.Header())
+	}
+}
+
+func TestLimitBody(t *testing.T) {
+	h := LimitBody(8)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if !errors.As(err, &tooLarge) {
+				t.Errorf("err = %v", err)
+			}
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		}
+	}))
+
+	for body, status := range map[string]int{"small": http.StatusOK, "far too large": http.StatusRequestEntityTooLarge} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/tasks/create", strings.NewReader(body)))
+		expectStatus(t, rec, status)
+	}
+}
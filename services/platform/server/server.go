@@ -0,0 +1,92 @@
+// Package server runs the services' HTTP servers with timeouts read from the
+// environment, and drains them when the ECS task is told to stop.
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Config holds the HTTP server's timeouts. ShutdownTimeout bounds how long
+// in-flight requests may take to finish once the task is told to stop; ECS
+// waits 30 seconds by default before it kills the container.
+type Config struct {
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	ShutdownTimeout   time.Duration
+}
+
+// LoadConfig reads the timeouts from HTTP_READ_HEADER_TIMEOUT,
+// HTTP_READ_TIMEOUT, HTTP_WRITE_TIMEOUT, HTTP_IDLE_TIMEOUT and
+// SHUTDOWN_TIMEOUT. The read and write timeouts leave room for the tasks
+// service's attachment uploads and downloads.
+func LoadConfig() (Config, error) {
+	var cfg Config
+	for _, setting := range []struct {
+		key, defaultValue string
+		value             *time.Duration
+	}{
+		{"HTTP_READ_HEADER_TIMEOUT", "10s", &cfg.ReadHeaderTimeout},
+		{"HTTP_READ_TIMEOUT", "60s", &cfg.ReadTimeout},
+		{"HTTP_WRITE_TIMEOUT", "60s", &cfg.WriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", "120s", &cfg.IdleTimeout},
+		{"SHUTDOWN_TIMEOUT", "25s", &cfg.ShutdownTimeout},
+	} {
+		raw, ok := os.LookupEnv(setting.key)
+		if !ok {
+			raw = setting.defaultValue
+		}
+		d, err := time.ParseDuration(raw)
+		if err != nil || d < 0 {
+			return Config{}, fmt.Errorf("invalid %s: %q", setting.key, raw)
+		}
+		*setting.value = d
+	}
+	return cfg, nil
+}
+
+// New returns a server for handler with the timeouts in cfg.
+func New(handler http.Handler, cfg Config) *http.Server {
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		ReadTimeout:       cfg.ReadTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
+		IdleTimeout:       cfg.IdleTimeout,
+	}
+}
+
+// Serve runs srv on l until it fails or ctx is cancelled. On cancellation it
+// stops accepting connections and waits up to drain for in-flight requests,
+// then closes whatever is left.
+func Serve(ctx context.Context, srv *http.Server, l net.Listener, drain time.Duration) error {
+	errs := make(chan error, 1)
+	go func() {
+		errs <- srv.Serve(l)
+	}()
+
+	select {
+	case err := <-errs:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), drain)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		srv.Close()
+		return fmt.Errorf("requests still in flight after %s: %w", drain, err)
+	}
+
+	if err := <-errs; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
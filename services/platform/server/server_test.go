@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig(t *testing.T) {
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.ReadHeaderTimeout != 10*time.Second || cfg.WriteTimeout != time.Minute || cfg.ShutdownTimeout != 25*time.Second {
+		t.Errorf("defaults = %+v", cfg)
+	}
+
+	t.Setenv("HTTP_WRITE_TIMEOUT", "5m")
+	if cfg, err := LoadConfig(); err != nil || cfg.WriteTimeout != 5*time.Minute {
+		t.Errorf("write timeout = %v, %v", cfg.WriteTimeout, err)
+	}
+
+	for _, value := range []string{"soon", "30", "-1s"} {
+		t.Setenv("SHUTDOWN_TIMEOUT", value)
+		if _, err := LoadConfig(); err == nil {
+			t.Errorf("SHUTDOWN_TIMEOUT=%q accepted", value)
+		}
+	}
+}
+
+// startServer serves handler on a free port until the returned context is
+// cancelled, and reports what serve returned on the channel.
+func startServer(t *testing.T, handler http.Handler, drain time.Duration) (string, context.CancelFunc, <-chan error) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- Serve(ctx, New(handler, Config{}), l, drain)
+	}()
+	return "http://" + l.Addr().String(), cancel, done
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	url, cancel, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		w.Write([]byte("done"))
+	}), 5*time.Second)
+
+	type result struct {
+		body string
+		err  error
+	}
+	results := make(chan result, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			results <- result{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- result{string(body), err}
+	}()
+
+	<-started
+	cancel()
+
+	// New connections are refused while the request in flight finishes
+	time.Sleep(50 * time.Millisecond)
+	if _, err := http.Get(url); err == nil {
+		t.Errorf("server accepted a request after shutdown began")
+	}
+
+	close(release)
+	if res := <-results; res.err != nil || res.body != "done" {
+		t.Errorf("in-flight request got %q, %v", res.body, res.err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("serve = %v", err)
+	}
+}
+
+func TestServeGivesUpAfterDrainTimeout(t *testing.T) {
+	started := make(chan struct{})
+	url, cancel, done := startServer(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-r.Context().Done()
+	}), 50*time.Millisecond)
+
+	go http.Get(url)
+	<-started
+	cancel()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Errorf("serve = nil with a request still in flight")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("serve didn't return after the drain timeout")
+	}
+}
@@ -0,0 +1,211 @@
+// Package tracing sets up OpenTelemetry tracing for the services: the tracer
+// provider, server spans for HTTP requests, client spans for outbound calls
+// and spans for the statements GORM runs.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+
+	"platform/logging"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// tracer starts every span the package creates. Tests swap it for one backed
+// by a span recorder.
+var tracer = otel.Tracer("platform/tracing")
+
+// Init installs the tracer provider picked by OTEL_TRACES_EXPORTER:
+// "otlp" sends spans to the collector at OTEL_EXPORTER_OTLP_ENDPOINT,
+// "stdout" prints them, which is handy locally without a collector, and
+// "none" (the default) records nothing. W3C trace context is propagated
+// either way so traces started at the API Gateway carry on downstream. The
+// returned function flushes buffered spans on shutdown. Spans are attributed
+// to serviceName.
+func Init(ctx context.Context, serviceName string) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch name := os.Getenv("OTEL_TRACES_EXPORTER"); name {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown OTEL_TRACES_EXPORTER %q, want otlp, stdout or none", name)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace exporter: %w", err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe the service: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Handler starts a server span for every request, continuing the trace
+// in the incoming traceparent header if there is one. Spans are named after
+// the route pattern the mux matched, like the metrics.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+		ctx, span := tracer.Start(ctx, r.Method,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(semconv.HTTPRequestMethodKey.String(r.Method), semconv.URLPath(r.URL.Path)),
+		)
+		defer span.End()
+
+		// The mux records the matched pattern on the request it was given
+		r = r.WithContext(ctx)
+		rec := &logging.StatusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+
+		if r.Pattern != "" {
+			span.SetName(r.Pattern)
+			span.SetAttributes(semconv.HTTPRoute(r.Pattern))
+		}
+		if rec.Status == 0 {
+			rec.Status = http.StatusOK
+		}
+		span.SetAttributes(semconv.HTTPResponseStatusCode(rec.Status))
+		if rec.Status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(rec.Status))
+		}
+	})
+}
+
+// Client is used for outbound calls such as those to Cognito, so the token
+// exchange and the JWKS fetches show up in the trace of the request that made
+// them.
+var Client = &http.Client{Transport: Transport{http.DefaultTransport}}
+
+// Transport starts a client span for each outbound request and passes
+// the trace context on in its headers.
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func (t Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := tracer.Start(req.Context(), req.Method+" "+req.URL.Path,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(req.Method),
+			semconv.ServerAddress(req.URL.Hostname()),
+			semconv.URLFull(req.URL.Redacted()),
+		),
+	)
+	defer span.End()
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, err
+	}
+	span.SetAttributes(semconv.HTTPResponseStatusCode(resp.StatusCode))
+	if resp.StatusCode >= http.StatusBadRequest {
+		span.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+	}
+	return resp, nil
+}
+
+// GORM is a GORM plugin recording a span for every statement run on behalf of
+// a traced request. Statements run without a span in their context, like the
+// background jobs' sweeps, are skipped rather than each starting a trace of
+// their own.
+type GORM struct{}
+
+func (GORM) Name() string {
+	return "tracing"
+}
+
+const gormSpanKey = "tracing:span"
+
+func (GORM) Initialize(db *gorm.DB) error {
+	before := func(operation string) func(*gorm.DB) {
+		return func(tx *gorm.DB) {
+			ctx := tx.Statement.Context
+			if !trace.SpanContextFromContext(ctx).IsValid() {
+				return
+			}
+			name := operation
+			if table := tx.Statement.Table; table != "" {
+				name += " " + table
+			}
+			_, span := tracer.Start(ctx, name,
+				trace.WithSpanKind(trace.SpanKindClient),
+				trace.WithAttributes(semconv.DBSystemPostgreSQL, semconv.DBOperationName(operation)),
+			)
+			if table := tx.Statement.Table; table != "" {
+				span.SetAttributes(semconv.DBCollectionName(table))
+			}
+			tx.InstanceSet(gormSpanKey, span)
+		}
+	}
+	after := func(tx *gorm.DB) {
+		value, ok := tx.InstanceGet(gormSpanKey)
+		if !ok {
+			return
+		}
+		span := value.(trace.Span)
+		defer span.End()
+
+		// Bound values stay out of the span, only the placeholders are recorded
+		span.SetAttributes(semconv.DBQueryText(tx.Statement.SQL.String()))
+		if tx.Error != nil && !errors.Is(tx.Error, gorm.ErrRecordNotFound) {
+			span.RecordError(tx.Error)
+			span.SetStatus(codes.Error, tx.Error.Error())
+		}
+	}
+
+	cb := db.Callback()
+	for _, err := range []error{
+		cb.Create().Before("gorm:create").Register("tracing:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("tracing:after_create", after),
+		cb.Query().Before("gorm:query").Register("tracing:before_query", before("query")),
+		cb.Query().After("gorm:query").Register("tracing:after_query", after),
+		cb.Update().Before("gorm:update").Register("tracing:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("tracing:after_update", after),
+		cb.Delete().Before("gorm:delete").Register("tracing:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("tracing:after_delete", after),
+		cb.Row().Before("gorm:row").Register("tracing:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("tracing:after_row", after),
+		cb.Raw().Before("gorm:raw").Register("tracing:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("tracing:after_raw", after),
+	} {
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package tracing
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// recordSpans points tracer at a recorder for the rest of the test.
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := tracer
+	tracer = provider.Tracer("platform/tracing")
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() { tracer = previous })
+	return recorder
+}
+
+func spanAttribute(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestInit(t *testing.T) {
+	shutdown, err := Init(context.Background(), "tasks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Errorf("shutdown = %v", err)
+	}
+
+	t.Setenv("OTEL_TRACES_EXPORTER", "jaeger")
+	if _, err := Init(context.Background(), "tasks"); err == nil {
+		t.Error("unknown exporter accepted")
+	}
+}
+
+func TestHandler(t *testing.T) {
+	recorder := recordSpans(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /api/tasks/update/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if !trace.SpanContextFromContext(r.Context()).IsValid() {
+			t.Error("handler context carries no span")
+		}
+		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+	})
+	handler := Handler(mux)
+
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest("PUT", "/api/tasks/update/1", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/tasks/nowhere", nil))
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans recorded, want 2", len(spans))
+	}
+
+	update := spans[0]
+	if update.Name() != "PUT /api/tasks/update/{id}" {
+		t.Errorf("span name = %q", update.Name())
+	}
+	if update.SpanKind() != trace.SpanKindServer {
+		t.Errorf("span kind = %v", update.SpanKind())
+	}
+	if got := update.SpanContext().TraceID().String(); got != traceID || !update.Parent().IsRemote() {
+		t.Errorf("trace %s not continued from the traceparent header, got %s", traceID, got)
+	}
+	if got := spanAttribute(update, "http.response.status_code").AsInt64(); got != http.StatusInternalServerError {
+		t.Errorf("status code = %d", got)
+	}
+	if update.Status().Code != codes.Error {
+		t.Errorf("status = %v, want Error", update.Status())
+	}
+
+	unmatched := spans[1]
+	if unmatched.Name() != "GET" || unmatched.Parent().IsValid() {
+		t.Errorf("unmatched request span = %q with parent %v", unmatched.Name(), unmatched.Parent())
+	}
+}
+
+type task struct {
+	TaskID string
+	UserID string
+}
+
+func TestGORM(t *testing.T) {
+	recorder := recordSpans(t)
+
+	// Dry runs build statements and run the callbacks without a database
+	gdb, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gdb.Use(GORM{}); err != nil {
+		t.Fatal(err)
+	}
+
+	gdb.Where("user_id = ?", "ana").Find(&[]task{})
+	if n := len(recorder.Ended()); n != 0 {
+		t.Fatalf("%d spans recorded outside a trace, want 0", n)
+	}
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	gdb.WithContext(ctx).Where("user_id = ?", "ana").Find(&[]task{})
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans recorded, want 2", len(spans))
+	}
+	query := spans[0]
+	if query.Name() != "query tasks" || query.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q with parent %v", query.Name(), query.Parent().SpanID())
+	}
+	statement := spanAttribute(query, "db.query.text").AsString()
+	if statement != `SELECT * FROM "tasks" WHERE user_id = $1` {
+		t.Errorf("statement = %q", statement)
+	}
+}
+
+func TestTransport(t *testing.T) {
+	recorder := recordSpans(t)
+
+	var traceparent string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+
+	ctx, parent := tracer.Start(context.Background(), "request")
+	req, _ := http.NewRequestWithContext(ctx, "GET", upstream.URL+"/.well-known/jwks.json", nil)
+	resp, err := Client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	parent.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("%d spans recorded, want 2", len(spans))
+	}
+	call := spans[0]
+	if call.Name() != "GET /.well-known/jwks.json" || call.SpanKind() != trace.SpanKindClient || call.Parent().SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("span %q of kind %v with parent %v", call.Name(), call.SpanKind(), call.Parent().SpanID())
+	}
+	if call.Status().Code != codes.Error {
+		t.Errorf("status = %v, want Error", call.Status())
+	}
+	if want := call.SpanContext().TraceID().String(); !strings.Contains(traceparent, want) {
+		t.Errorf("traceparent = %q, want trace %s", traceparent, want)
+	}
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// archiveTasks archives the DONE tasks whose owners' auto-archive period has
+// elapsed since completion, returning how many were archived.
+func archiveTasks(ctx context.Context) (int64, error) {
+	result := db.WithContext(ctx).Exec(`
+		UPDATE tasks SET archived_at = now()
+		FROM users
+		WHERE tasks.user_id = users.user_id
+		  AND users.auto_archive_days IS NOT NULL
+		  AND tasks.status = 'DONE'
+		  AND tasks.archived_at IS NULL
+		  AND tasks.completed_at < now() - make_interval(days => users.auto_archive_days)`)
+	return result.RowsAffected, result.Error
+}
+
+// runAutoArchiver applies the users' auto-archive policies every interval until ctx is done.
+// Running it on several replicas at once is harmless, the update is idempotent.
+func runAutoArchiver(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := archiveTasks(ctx)
+		if err != nil {
+			slog.Error("Auto-archive failed", "err", err)
+		} else if n > 0 {
+			slog.Info("Auto-archived tasks", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// setArchived archives or restores one of the user's tasks.
+func (h *taskHandlers) setArchived(w http.ResponseWriter, r *http.Request, archive bool) {
+	userID := requestUserID(r)
+
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	task, err := h.tasks.SetArchived(r.Context(), userID, taskID, archive)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(task)
+}
+
+// @Summary Archive a task
+// @Description Hide a task from the default listing without deleting it
+// @Tags Tasks
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 200 {object} Task "Task archived"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/archive/{id} [put]
+func (h *taskHandlers) handleArchiveTask(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// @Summary Unarchive a task
+// @Description Bring an archived task back into the default listing
+// @Tags Tasks
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 200 {object} Task "Task unarchived"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/unarchive/{id} [put]
+func (h *taskHandlers) handleUnarchiveTask(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
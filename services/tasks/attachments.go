@@ -0,0 +1,339 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"platform/logging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxAttachmentSize caps a single uploaded file.
+const maxAttachmentSize = 10 << 20
+
+// maxAttachmentNameLength is the length of the file_name column in characters.
+const maxAttachmentNameLength = 255
+
+var errAttachmentTooLarge = errors.New("attachment too large")
+
+var allowedAttachmentTypes = map[string]bool{
+	"image/png":       true,
+	"image/jpeg":      true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+	"text/plain":      true,
+	"text/csv":        true,
+	"application/zip": true,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   true,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         true,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": true,
+}
+
+// refinedTypes lists the declared types trusted over a sniffed type that is
+// too coarse to tell them apart, such as office documents, which are zips.
+var refinedTypes = map[string][]string{
+	"application/zip": {
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+		"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+		"application/vnd.openxmlformats-officedocument.presentationml.presentation",
+	},
+	"text/plain": {"text/csv"},
+}
+
+// attachmentType settles on the MIME type of an upload from its content. The
+// declared type only refines a sniffed type it is compatible with, so content
+// sniffing can't place stays application/octet-stream, which isn't allowed,
+// whatever the client claims.
+func attachmentType(declared string, head []byte) string {
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	declared, _, _ = mime.ParseMediaType(declared)
+
+	if slices.Contains(refinedTypes[sniffed], declared) {
+		return declared
+	}
+	return sniffed
+}
+
+// attachmentName reduces the name a client gave an upload to its last path
+// element, with invalid UTF-8 replaced so it can be stored. Names longer than
+// the column keep their last characters, which hold the extension. It reports
+// false when nothing of the name is left.
+func attachmentName(name string) (string, bool) {
+	name = strings.ToValidUTF8(filepath.Base(name), "\uFFFD")
+	if name == "." || name == "/" {
+		return "", false
+	}
+	if utf8.RuneCountInString(name) > maxAttachmentNameLength {
+		runes := []rune(name)
+		name = string(runes[len(runes)-maxAttachmentNameLength:])
+	}
+	return name, true
+}
+
+// readAttachment reads an uploaded file into memory, failing with
+// errAttachmentTooLarge when it, or the request body around it, is too large.
+func readAttachment(part io.Reader) (*bytes.Buffer, error) {
+	var buf bytes.Buffer
+	if _, err := io.Copy(&buf, io.LimitReader(part, maxAttachmentSize+1)); err != nil {
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			return nil, errAttachmentTooLarge
+		}
+		return nil, err
+	}
+	if buf.Len() > maxAttachmentSize {
+		return nil, errAttachmentTooLarge
+	}
+	return &buf, nil
+}
+
+// @Summary Upload an attachment
+// @Description Attach a file to a task, sent as the "file" field of a multipart form
+// @Tags Attachments
+// @Accept mpfd
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Param file formData file true "File to attach"
+// @Success 201 {object} Attachment "Attachment created"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 413 {string} string "File too large"
+// @Failure 415 {string} string "Unsupported file type"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/attachments/create/{id} [post]
+func handleUploadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	task, err := findUserTask(r.Context(), userID.String(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	// Leave some room for the multipart framing around the file itself
+	r.Body = http.MaxBytesReader(w, r.Body, maxAttachmentSize+64<<10)
+	reader, err := r.MultipartReader()
+	if err != nil {
+		http.Error(w, "Expected a multipart form", http.StatusBadRequest)
+		return
+	}
+
+	var part io.Reader
+	var rawName, declaredType string
+	for {
+		p, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+			return
+		}
+		if p.FormName() == "file" {
+			part, rawName, declaredType = p, p.FileName(), p.Header.Get("Content-Type")
+			break
+		}
+	}
+	fileName, ok := attachmentName(rawName)
+	if part == nil || !ok {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+
+	buf, err := readAttachment(part)
+	if errors.Is(err, errAttachmentTooLarge) {
+		http.Error(w, "File too large", http.StatusRequestEntityTooLarge)
+		return
+	} else if err != nil {
+		http.Error(w, "Invalid multipart form", http.StatusBadRequest)
+		return
+	}
+
+	contentType := attachmentType(declaredType, buf.Bytes())
+	if !allowedAttachmentTypes[contentType] {
+		http.Error(w, "Unsupported file type", http.StatusUnsupportedMediaType)
+		return
+	}
+
+	attachment := Attachment{
+		AttachmentID: uuid.New(),
+		TaskID:       task.TaskID,
+		UserID:       userID,
+		FileName:     fileName,
+		ContentType:  contentType,
+		Size:         int64(buf.Len()),
+	}
+	attachment.StorageKey = fmt.Sprintf("tasks/%s/%s", task.TaskID, attachment.AttachmentID)
+
+	if err := blobs.Put(r.Context(), attachment.StorageKey, buf, attachment.Size, contentType); err != nil {
+		logging.RequestLogger(r).Error("Couldn't store attachment", "err", err)
+		http.Error(w, "Failed to store attachment", http.StatusInternalServerError)
+		return
+	}
+
+	if err := db.WithContext(r.Context()).Create(&attachment).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't create attachment", "err", err)
+		if err := blobs.Delete(r.Context(), attachment.StorageKey); err != nil {
+			logging.RequestLogger(r).Error("Couldn't clean up attachment blob", "err", err)
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	attachmentsUploaded.Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(attachment)
+}
+
+// @Summary List attachments
+// @Description List the files attached to a task
+// @Tags Attachments
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 200 {array} Attachment
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/attachments/{id} [get]
+func handleListAttachments(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
+		return
+	}
+
+	attachments := []Attachment{}
+	if err := db.WithContext(r.Context()).Where("user_id = ? AND task_id = ?", userID, r.PathValue("id")).
+		Order("created_at").
+		Find(&attachments).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(attachments)
+}
+
+// @Summary Download an attachment
+// @Description Redirects to a short-lived URL when the blob store supports it, otherwise streams the file
+// @Tags Attachments
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Attachment ID"
+// @Success 200 {file} file "Attachment contents"
+// @Success 302 "Redirects to a presigned download URL"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Attachment not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/attachments/download/{id} [get]
+func handleDownloadAttachment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	var attachment Attachment
+	if err := db.WithContext(r.Context()).Where("user_id = ? AND attachment_id = ?", userID, r.PathValue("id")).First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if signer, ok := blobs.(URLSigner); ok {
+		url, err := signer.SignedURL(r.Context(), attachment.StorageKey, attachment.FileName, presignTTL)
+		if err != nil {
+			logging.RequestLogger(r).Error("Couldn't sign attachment URL", "err", err)
+			http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+			return
+		}
+		http.Redirect(w, r, url, http.StatusFound)
+		return
+	}
+
+	body, err := blobs.Open(r.Context(), attachment.StorageKey)
+	if err != nil {
+		logging.RequestLogger(r).Error("Couldn't open attachment", "err", err)
+		http.Error(w, "Failed to fetch attachment", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	w.Header().Set("Content-Type", attachment.ContentType)
+	w.Header().Set("Content-Length", fmt.Sprint(attachment.Size))
+	w.Header().Set("Content-Disposition", contentDisposition(attachment.FileName))
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		logging.RequestLogger(r).Error("Couldn't stream attachment", "err", err)
+	}
+}
+
+// @Summary Delete an attachment
+// @Description Delete an attachment and its stored contents
+// @Tags Attachments
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Attachment ID"
+// @Success 200 {string} string "Attachment deleted successfully"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Attachment not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/attachments/delete/{id} [delete]
+func handleDeleteAttachment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Attachment not found", http.StatusNotFound)
+		return
+	}
+
+	var attachment Attachment
+	if err := db.WithContext(r.Context()).Where("user_id = ? AND attachment_id = ?", userID, r.PathValue("id")).First(&attachment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Attachment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if err := db.WithContext(r.Context()).Delete(&attachment).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The row is gone either way, a leftover blob is only wasted space
+	if err := blobs.Delete(r.Context(), attachment.StorageKey); err != nil {
+		logging.RequestLogger(r).Error("Couldn't delete attachment blob", "err", err)
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
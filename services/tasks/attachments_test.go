@@ -0,0 +1,97 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestAttachmentType(t *testing.T) {
+	const docx = "application/vnd.openxmlformats-officedocument.wordprocessingml.document"
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	zip := []byte("PK\x03\x04\x14\x00\x06\x00")
+	binary := []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00")
+
+	tests := []struct {
+		name     string
+		declared string
+		head     []byte
+		want     string
+	}{
+		{"sniffed", "", png, "image/png"},
+		{"content wins over the declared type", "application/pdf", png, "image/png"},
+		{"office document", docx, zip, docx},
+		{"plain zip", "application/zip", zip, "application/zip"},
+		{"zip claiming to be an image", "image/png", zip, "application/zip"},
+		{"csv", "text/csv; charset=utf-8", []byte("a,b\n1,2\n"), "text/csv"},
+		{"text claiming to be a pdf", "application/pdf", []byte("hello"), "text/plain"},
+		{"unknown bytes claiming to be a pdf", "application/pdf", binary, "application/octet-stream"},
+		{"unknown bytes claiming to be an image", "image/png", binary, "application/octet-stream"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := attachmentType(tt.declared, tt.head); got != tt.want {
+				t.Errorf("attachmentType(%q) = %q, want %q", tt.declared, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAttachmentName(t *testing.T) {
+	long := strings.Repeat("a", maxAttachmentNameLength) + ".pdf"
+	multibyte := strings.Repeat("é", maxAttachmentNameLength) + ".pdf"
+
+	tests := []struct {
+		name string
+		want string
+		ok   bool
+	}{
+		{"notes.txt", "notes.txt", true},
+		{"../../etc/passwd", "passwd", true},
+		{"/tmp/report.pdf", "report.pdf", true},
+		{"", "", false},
+		{"/", "", false},
+		{"bad\xffname.txt", "bad\uFFFDname.txt", true},
+		{long, long[len(long)-maxAttachmentNameLength:], true},
+		{multibyte, strings.Repeat("é", maxAttachmentNameLength-4) + ".pdf", true},
+	}
+	for _, tt := range tests {
+		got, ok := attachmentName(tt.name)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("attachmentName(%.20q) = %.20q, %v, want %.20q, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+		if !utf8.ValidString(got) || utf8.RuneCountInString(got) > maxAttachmentNameLength {
+			t.Errorf("attachmentName(%.20q) = %.20q, which doesn't fit the column", tt.name, got)
+		}
+	}
+}
+
+func TestReadAttachment(t *testing.T) {
+	tests := []struct {
+		name string
+		body io.Reader
+		size int
+		err  error
+	}{
+		{"small", strings.NewReader("notes"), 5, nil},
+		{"largest", bytes.NewReader(make([]byte, maxAttachmentSize)), maxAttachmentSize, nil},
+		{"too large", bytes.NewReader(make([]byte, maxAttachmentSize+1)), 0, errAttachmentTooLarge},
+		{"request body too large", http.MaxBytesReader(httptest.NewRecorder(), io.NopCloser(strings.NewReader("notes")), 2), 0, errAttachmentTooLarge},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			buf, err := readAttachment(tt.body)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if err == nil && buf.Len() != tt.size {
+				t.Errorf("read %d bytes, want %d", buf.Len(), tt.size)
+			}
+		})
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// presignTTL is how long a presigned download URL stays valid.
+const presignTTL = 5 * time.Minute
+
+// BlobStore persists attachment contents outside the database.
+type BlobStore interface {
+	Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// URLSigner is implemented by stores that can hand out short-lived download
+// URLs, letting clients fetch the blob without going through the service.
+type URLSigner interface {
+	SignedURL(ctx context.Context, key, fileName string, ttl time.Duration) (string, error)
+}
+
+// newBlobStore picks S3 when ATTACHMENTS_BUCKET is set and falls back to the
+// local filesystem under ATTACHMENTS_DIR for development.
+func newBlobStore(cfg aws.Config) (BlobStore, error) {
+	if bucket := os.Getenv("ATTACHMENTS_BUCKET"); bucket != "" {
+		return NewS3BlobStore(s3.NewFromConfig(cfg), bucket), nil
+	}
+	return NewLocalBlobStore(getEnv("ATTACHMENTS_DIR", "attachments"))
+}
+
+type S3BlobStore struct {
+	client  *s3.Client
+	presign *s3.PresignClient
+	bucket  string
+}
+
+func NewS3BlobStore(client *s3.Client, bucket string) *S3BlobStore {
+	return &S3BlobStore{
+		client:  client,
+		presign: s3.NewPresignClient(client),
+		bucket:  bucket,
+	}
+}
+
+func (s *S3BlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	_, err := s.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(s.bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: aws.Int64(size),
+		ContentType:   aws.String(contentType),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to upload %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3BlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to download %s: %w", key, err)
+	}
+	return out.Body, nil
+}
+
+func (s *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := s.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("unable to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *S3BlobStore) SignedURL(ctx context.Context, key, fileName string, ttl time.Duration) (string, error) {
+	req, err := s.presign.PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket:                     aws.String(s.bucket),
+		Key:                        aws.String(key),
+		ResponseContentDisposition: aws.String(contentDisposition(fileName)),
+	}, s3.WithPresignExpires(ttl))
+	if err != nil {
+		return "", fmt.Errorf("unable to presign %s: %w", key, err)
+	}
+	return req.URL, nil
+}
+
+// LocalBlobStore keeps blobs as files under a root directory.
+type LocalBlobStore struct {
+	root string
+}
+
+func NewLocalBlobStore(root string) (*LocalBlobStore, error) {
+	if err := os.MkdirAll(root, 0o750); err != nil {
+		return nil, fmt.Errorf("unable to create blob directory %s: %w", root, err)
+	}
+	return &LocalBlobStore{root: root}, nil
+}
+
+// path maps a key to a file, refusing keys that would escape the root.
+func (s *LocalBlobStore) path(key string) (string, error) {
+	p := filepath.Join(s.root, filepath.FromSlash(key))
+	if !strings.HasPrefix(p, filepath.Clean(s.root)+string(os.PathSeparator)) {
+		return "", fmt.Errorf("invalid blob key %q", key)
+	}
+	return p, nil
+}
+
+func (s *LocalBlobStore) Put(ctx context.Context, key string, body io.Reader, size int64, contentType string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o750); err != nil {
+		return fmt.Errorf("unable to store %s: %w", key, err)
+	}
+
+	// Write to a temporary file first so readers never see a partial blob
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return fmt.Errorf("unable to store %s: %w", key, err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, body); err != nil {
+		tmp.Close()
+		return fmt.Errorf("unable to store %s: %w", key, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("unable to store %s: %w", key, err)
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (s *LocalBlobStore) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	p, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(p)
+}
+
+func (s *LocalBlobStore) Delete(ctx context.Context, key string) error {
+	p, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("unable to delete %s: %w", key, err)
+	}
+	return nil
+}
+
+func contentDisposition(fileName string) string {
+	return mime.FormatMediaType("attachment", map[string]string{"filename": fileName})
+}
@@ -0,0 +1,105 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"platform/logging"
+)
+
+// maxCapacityDays bounds the range a capacity summary can cover.
+const maxCapacityDays = 92
+
+// validEstimates rejects negative estimates in a task request.
+func validEstimates(req TaskRequest) bool {
+	if req.EstimateMinutes != nil && *req.EstimateMinutes < 0 {
+		return false
+	}
+	if req.EstimatePoints != nil && *req.EstimatePoints < 0 {
+		return false
+	}
+	return true
+}
+
+// @Summary Capacity summary
+// @Description Sum the estimated work of open tasks per deadline day and flag days whose planned load exceeds the user's daily capacity
+// @Tags Tasks
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param from query string false "First day (YYYY-MM-DD); defaults to today"
+// @Param to query string false "Last day (YYYY-MM-DD); defaults to six days after today"
+// @Success 200 {object} CapacitySummary
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/capacity [get]
+func handleGetCapacity(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	prefs, err := loadUserPrefs(userID)
+	if err != nil {
+		logging.RequestLogger(r).Error("Couldn't load user preferences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	today := calendarDay(time.Now(), prefs.Location)
+	from, to, err := parseDayRange(r.URL.Query(), today, today.AddDate(0, 0, 6))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+	if days > maxCapacityDays {
+		http.Error(w, "Date range too long", http.StatusBadRequest)
+		return
+	}
+
+	var tasks []Task
+	query := db.WithContext(r.Context()).Where("user_id = ? AND status <> ? AND archived_at IS NULL", userID, "DONE")
+	if err := deadlineWithin(query, from, days, prefs.Location).Find(&tasks).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't load open tasks", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	summary := CapacitySummary{
+		From:            from.Format(dateLayout),
+		To:              to.Format(dateLayout),
+		CapacityMinutes: prefs.CapacityMinutes,
+		CapacityPoints:  prefs.CapacityPoints,
+		Days:            make([]CapacityDay, days),
+	}
+	for i := range summary.Days {
+		summary.Days[i].Date = from.AddDate(0, 0, i).Format(dateLayout)
+	}
+
+	for _, task := range tasks {
+		i := int(deadlineDay(task, prefs.Location).Sub(from).Hours() / 24)
+		if i < 0 || i >= days {
+			continue
+		}
+		day := &summary.Days[i]
+		day.Tasks++
+		if task.EstimateMinutes == nil && task.EstimatePoints == nil {
+			day.Unestimated++
+		}
+		if task.EstimateMinutes != nil {
+			day.EstimateMinutes += *task.EstimateMinutes
+		}
+		if task.EstimatePoints != nil {
+			day.EstimatePoints += *task.EstimatePoints
+		}
+	}
+
+	for i := range summary.Days {
+		day := &summary.Days[i]
+		day.Overloaded = (prefs.CapacityMinutes > 0 && day.EstimateMinutes > prefs.CapacityMinutes) ||
+			(prefs.CapacityPoints > 0 && day.EstimatePoints > prefs.CapacityPoints)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,422 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"platform/logging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const maxCommentLength = 10000
+
+// mentionPattern matches "@" followed by an email address, e.g. "@ana@example.com".
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// parseMentions returns the distinct, lowercased emails mentioned in a comment body.
+func parseMentions(body string) []string {
+	seen := map[string]bool{}
+	var emails []string
+	for _, m := range mentionPattern.FindAllStringSubmatch(body, -1) {
+		email := strings.ToLower(m[1])
+		if !seen[email] {
+			seen[email] = true
+			emails = append(emails, email)
+		}
+	}
+	return emails
+}
+
+// notifyMentions records a notification for each mentioned user except the
+// author. Anyone with an account can be mentioned, but a notification only
+// names the actor, task and comment: mentioning someone doesn't let them read
+// a task they don't own. Which mentions matched an account is never reported
+// back, so comments can't be used to find out who has one.
+func notifyMentions(tx *gorm.DB, comment Comment) error {
+	emails := parseMentions(comment.Body)
+	if len(emails) == 0 {
+		return nil
+	}
+
+	var users []User
+	if err := tx.Where("LOWER(email) IN ?", emails).Find(&users).Error; err != nil {
+		return err
+	}
+
+	notifications := make([]Notification, 0, len(users))
+	for _, user := range users {
+		if user.UserID == comment.UserID {
+			continue
+		}
+		notifications = append(notifications, Notification{
+			UserID:    user.UserID,
+			ActorID:   &comment.UserID,
+			Kind:      "MENTION",
+			TaskID:    &comment.TaskID,
+			CommentID: &comment.CommentID,
+		})
+	}
+
+	if len(notifications) > 0 {
+		// People already notified about this comment are skipped on edits
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&notifications).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validCommentBody checks the body isn't blank or over maxCommentLength
+// characters.
+func validCommentBody(body string) bool {
+	body = strings.TrimSpace(body)
+	return body != "" && utf8.RuneCountInString(body) <= maxCommentLength
+}
+
+// @Summary List comments
+// @Description Retrieve a task's comments as threads, oldest first
+// @Tags Comments
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 200 {array} Comment
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/comments/{id} [get]
+func handleListComments(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	task, err := findUserTask(r.Context(), userID, r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var comments []*Comment
+	if err := db.WithContext(r.Context()).Where("task_id = ?", task.TaskID).Order("created_at").Find(&comments).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	byID := make(map[uuid.UUID]*Comment, len(comments))
+	for _, c := range comments {
+		byID[c.CommentID] = c
+	}
+	threads := []*Comment{}
+	for _, c := range comments {
+		if c.ParentID != nil {
+			if parent, ok := byID[*c.ParentID]; ok {
+				parent.Replies = append(parent.Replies, c)
+				continue
+			}
+		}
+		threads = append(threads, c)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(threads)
+}
+
+// @Summary Add a comment
+// @Description Comment on a task, optionally as a reply. Mentions like @user@example.com notify that user, without giving them access to the task.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Param comment body CommentRequest true "Markdown body and optional parent comment"
+// @Success 201 {object} Comment "Comment created"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/comments/create/{id} [post]
+func handleCreateComment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if !validCommentBody(req.Body) {
+		http.Error(w, "Comment body must be between 1 and 10000 characters", http.StatusBadRequest)
+		return
+	}
+
+	task, err := findUserTask(r.Context(), userID.String(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	comment := Comment{
+		TaskID: task.TaskID,
+		UserID: userID,
+		Body:   req.Body,
+	}
+
+	if req.ParentID != nil && *req.ParentID != "" {
+		parentID, err := uuid.Parse(*req.ParentID)
+		if err != nil {
+			http.Error(w, "Invalid parent comment", http.StatusBadRequest)
+			return
+		}
+		var parent Comment
+		if err := db.WithContext(r.Context()).Where("comment_id = ? AND task_id = ?", parentID, task.TaskID).First(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				http.Error(w, "Invalid parent comment", http.StatusBadRequest)
+			} else {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		comment.ParentID = &parent.CommentID
+	}
+
+	if err := db.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return notifyMentions(tx, comment)
+	}); err != nil {
+		logging.RequestLogger(r).Error("Couldn't create comment", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	commentsCreated.Inc()
+	comment.Mentions = parseMentions(comment.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// @Summary Edit a comment
+// @Description Replace the body of a comment. Only its author can edit it.
+// @Tags Comments
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Comment ID"
+// @Param comment body CommentRequest true "New markdown body"
+// @Success 200 {object} Comment "Comment updated"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Comment not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/comments/update/{id} [put]
+func handleUpdateComment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	var req CommentRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	if !validCommentBody(req.Body) {
+		http.Error(w, "Comment body must be between 1 and 10000 characters", http.StatusBadRequest)
+		return
+	}
+
+	var comment Comment
+	if err := db.WithContext(r.Context()).Where("comment_id = ? AND user_id = ? AND deleted_at IS NULL", r.PathValue("id"), userID).
+		First(&comment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	comment.Body = req.Body
+	if err := db.WithContext(r.Context()).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&comment).Error; err != nil {
+			return err
+		}
+		return notifyMentions(tx, comment)
+	}); err != nil {
+		logging.RequestLogger(r).Error("Couldn't update comment", "err", err)
+		http.Error(w, "Failed to update comment", http.StatusInternalServerError)
+		return
+	}
+
+	comment.Mentions = parseMentions(comment.Body)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(comment)
+}
+
+// @Summary Delete a comment
+// @Description Delete a comment. Only its author can delete it; a comment with replies is blanked so the thread survives.
+// @Tags Comments
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Comment ID"
+// @Success 200 {string} string "Comment deleted successfully"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Comment not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/comments/delete/{id} [delete]
+func handleDeleteComment(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Comment not found", http.StatusNotFound)
+		return
+	}
+
+	var comment Comment
+	if err := db.WithContext(r.Context()).Where("comment_id = ? AND user_id = ? AND deleted_at IS NULL", r.PathValue("id"), userID).
+		First(&comment).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Comment not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var replies int64
+	if err := db.WithContext(r.Context()).Model(&Comment{}).Where("parent_id = ?", comment.CommentID).Count(&replies).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var err error
+	if replies > 0 {
+		err = db.WithContext(r.Context()).Model(&comment).Updates(map[string]any{"body": "", "deleted_at": time.Now()}).Error
+	} else {
+		err = db.WithContext(r.Context()).Delete(&comment).Error
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary List notifications
+// @Description Retrieve the authenticated user's notifications, newest first
+// @Tags Comments
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param unread query bool false "Only return unread notifications"
+// @Success 200 {array} Notification
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/notifications [get]
+func handleListNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	page, limit := getPaginationParams(r)
+
+	query := db.WithContext(r.Context()).Where("user_id = ?", userID)
+	if r.URL.Query().Get("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+
+	notifications := []Notification{}
+	if err := query.Order("created_at DESC").
+		Offset((page - 1) * limit).
+		Limit(limit).
+		Find(&notifications).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notifications)
+}
+
+// @Summary Mark a notification read
+// @Description Mark one of the authenticated user's notifications as read. Marking it again keeps the original time.
+// @Tags Comments
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Notification ID"
+// @Success 200 {object} Notification "Notification read"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Notification not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/notifications/read/{id} [put]
+func handleReadNotification(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	notificationID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	var notification Notification
+	if err := db.WithContext(r.Context()).Model(&notification).
+		Clauses(clause.Returning{}).
+		Where("notification_id = ? AND user_id = ?", notificationID, userID).
+		Update("read_at", gorm.Expr("COALESCE(read_at, now())")).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't mark notification read", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+	if notification.NotificationID == uuid.Nil {
+		http.Error(w, "Notification not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(notification)
+}
+
+// @Summary Mark all notifications read
+// @Description Mark every unread notification of the authenticated user as read
+// @Tags Comments
+// @Param X-User-ID header string true "User ID"
+// @Success 200 {string} string "Notifications read"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/notifications/read [put]
+func handleReadAllNotifications(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	if err := db.WithContext(r.Context()).Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL", userID).
+		Update("read_at", gorm.Expr("now()")).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't mark notifications read", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
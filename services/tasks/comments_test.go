@@ -0,0 +1,35 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	got := parseMentions("Thoughts, @Ana@Example.com? cc @bob@example.com and @ana@example.com, not me@example.com")
+	if want := []string{"ana@example.com", "bob@example.com"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parseMentions() = %v, want %v", got, want)
+	}
+}
+
+func TestValidCommentBody(t *testing.T) {
+	tests := []struct {
+		body string
+		want bool
+	}{
+		{"", false},
+		{" \n\t", false},
+		{"Looks good", true},
+		{strings.Repeat("a", maxCommentLength), true},
+		{strings.Repeat("a", maxCommentLength+1), false},
+		// The limit is in characters, not bytes
+		{strings.Repeat("é", maxCommentLength), true},
+		{strings.Repeat("é", maxCommentLength+1), false},
+	}
+	for _, tt := range tests {
+		if got := validCommentBody(tt.body); got != tt.want {
+			t.Errorf("validCommentBody(%.20q...) = %v, want %v", tt.body, got, tt.want)
+		}
+	}
+}
@@ -2,25 +2,49 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
+
+	"platform/metrics"
+	"platform/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-func InitDB(rdsEndpoint, dbUser, dbPassword, dbName string) (*gorm.DB, error) {
+// postgresDSN builds the connection string for the database at rdsEndpoint.
+func postgresDSN(rdsEndpoint, dbUser, dbPassword, dbName string) string {
 	parts := strings.Split(rdsEndpoint, ":")
 
 	host := parts[0]
-	dsn := fmt.Sprintf(
+	return fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=5432 sslmode=require",
 		host, dbUser, dbPassword, dbName,
 	)
+}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+func InitDB(dsn string) (*gorm.DB, error) {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		TranslateError: true,
+		// Slow statements and errors go to the JSON log, record not found is
+		// an answer rather than a failure
+		Logger: logger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
+	if err := db.Use(metrics.GORM{}); err != nil {
+		return nil, fmt.Errorf("failed to instrument the database: %w", err)
+	}
+	if err := db.Use(tracing.GORM{}); err != nil {
+		return nil, fmt.Errorf("failed to instrument the database: %w", err)
+	}
 
 	return db, nil
 }
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"errors"
+	"log/slog"
+	"net/url"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const dateLayout = "2006-01-02"
+
+var validDue = map[string]bool{
+	"overdue":   true,
+	"today":     true,
+	"this_week": true,
+	"none":      true,
+}
+
+// UserPrefs holds the profile settings deadline semantics depend on.
+type UserPrefs struct {
+	Location  *time.Location
+	WeekStart time.Weekday
+
+	// Daily planned load before a day counts as overloaded; 0 disables the check.
+	CapacityMinutes int
+	CapacityPoints  int
+}
+
+var defaultPrefs = UserPrefs{Location: time.UTC, WeekStart: time.Monday, CapacityMinutes: 480}
+
+// loadUserPrefs reads the user's timezone, week start and capacity from their profile,
+// falling back to the defaults when the profile is missing.
+func loadUserPrefs(userID string) (UserPrefs, error) {
+	return queryUserPrefs(db, userID)
+}
+
+// queryUserPrefs is loadUserPrefs against the given connection.
+func queryUserPrefs(tx *gorm.DB, userID string) (UserPrefs, error) {
+	var user User
+	if err := tx.Select("user_id", "timezone", "week_start", "daily_capacity_minutes", "daily_capacity_points").
+		First(&user, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return defaultPrefs, nil
+		}
+		return defaultPrefs, err
+	}
+
+	return prefsFor(user), nil
+}
+
+// prefsFor builds the preferences from an already loaded user row.
+func prefsFor(user User) UserPrefs {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		slog.Warn("Unknown timezone", "timezone", user.Timezone, "user_id", user.UserID, "err", err)
+		loc = time.UTC
+	}
+
+	return UserPrefs{
+		Location:        loc,
+		WeekStart:       time.Weekday(user.WeekStart),
+		CapacityMinutes: user.DailyCapacityMinutes,
+		CapacityPoints:  user.DailyCapacityPoints,
+	}
+}
+
+// parseDayRange reads the from and to query parameters as calendar days,
+// keeping the given defaults for whichever is absent.
+func parseDayRange(qs url.Values, from, to time.Time) (time.Time, time.Time, error) {
+	var err error
+	if v := qs.Get("from"); v != "" {
+		if from, err = time.Parse(dateLayout, v); err != nil {
+			return from, to, errors.New("invalid from date")
+		}
+	}
+	if v := qs.Get("to"); v != "" {
+		if to, err = time.Parse(dateLayout, v); err != nil {
+			return from, to, errors.New("invalid to date")
+		}
+	}
+	if to.Before(from) {
+		return from, to, errors.New("from must not be after to")
+	}
+	return from, to, nil
+}
+
+// calendarDay returns the date t falls on in loc as midnight UTC,
+// which is how all-day deadlines are stored.
+func calendarDay(t time.Time, loc *time.Location) time.Time {
+	y, m, d := t.In(loc).Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+}
+
+// startOfDay returns the instant the calendar day begins in loc.
+func startOfDay(day time.Time, loc *time.Location) time.Time {
+	y, m, d := day.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, loc)
+}
+
+// weekStart returns the first day of the week day belongs to.
+func weekStart(day time.Time, first time.Weekday) time.Time {
+	offset := (int(day.Weekday()) - int(first) + 7) % 7
+	return day.AddDate(0, 0, -offset)
+}
+
+// deadlineWithin matches deadlines falling on the calendar days [from, from+days).
+// All-day deadlines are compared as dates, timed ones as instants in the user's timezone.
+func deadlineWithin(query *gorm.DB, from time.Time, days int, loc *time.Location) *gorm.DB {
+	start := startOfDay(from, loc)
+	return query.Where(
+		"((all_day AND deadline >= ? AND deadline < ?) OR (NOT all_day AND deadline >= ? AND deadline < ?))",
+		from, from.AddDate(0, 0, days), start, start.AddDate(0, 0, days),
+	)
+}
+
+func applyDueFilter(query *gorm.DB, due string, now time.Time, prefs UserPrefs) *gorm.DB {
+	today := calendarDay(now, prefs.Location)
+
+	switch due {
+	case "overdue":
+		return query.Where(
+			"status <> ? AND ((all_day AND deadline < ?) OR (NOT all_day AND deadline < ?))",
+			"DONE", today, now,
+		)
+	case "today":
+		return deadlineWithin(query, today, 1, prefs.Location)
+	case "this_week":
+		return deadlineWithin(query, weekStart(today, prefs.WeekStart), 7, prefs.Location)
+	case "none":
+		return query.Where("deadline IS NULL")
+	}
+	return query
+}
+
+// matchesDue is applyDueFilter for a single task, for filtering outside the database.
+func matchesDue(task Task, due string, now time.Time, prefs UserPrefs) bool {
+	today := calendarDay(now, prefs.Location)
+
+	switch due {
+	case "overdue":
+		return isOverdue(task, now, prefs)
+	case "today":
+		return task.Deadline != nil && dueWithin(task, today, 1, prefs.Location)
+	case "this_week":
+		return task.Deadline != nil && dueWithin(task, weekStart(today, prefs.WeekStart), 7, prefs.Location)
+	case "none":
+		return task.Deadline == nil
+	}
+	return true
+}
+
+// dueWithin is deadlineWithin for a single task with a deadline.
+func dueWithin(task Task, from time.Time, days int, loc *time.Location) bool {
+	start, end := from, from.AddDate(0, 0, days)
+	if !task.AllDay {
+		start = startOfDay(from, loc)
+		end = start.AddDate(0, 0, days)
+	}
+	return !task.Deadline.Before(start) && task.Deadline.Before(end)
+}
+
+// deadlineDay returns the calendar day a task is due on for the user.
+func deadlineDay(task Task, loc *time.Location) time.Time {
+	if task.AllDay {
+		return calendarDay(*task.Deadline, time.UTC)
+	}
+	return calendarDay(*task.Deadline, loc)
+}
+
+// isOverdue reports whether an unfinished task's deadline has passed.
+// An all-day deadline only passes once its whole day is over for the user.
+func isOverdue(task Task, now time.Time, prefs UserPrefs) bool {
+	if task.Deadline == nil || task.Status == "DONE" {
+		return false
+	}
+	if task.AllDay {
+		return deadlineDay(task, prefs.Location).Before(calendarDay(now, prefs.Location))
+	}
+	return task.Deadline.Before(now)
+}
@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log/slog"
+	texttemplate "text/template"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// digestGrace is how late a digest may still go out after its slot, e.g. after
+// downtime. Older slots are skipped rather than sending a stale summary.
+const digestGrace = 6 * time.Hour
+
+// maxDigestTasks caps each section of a digest.
+const maxDigestTasks = 50
+
+// dateFormatLayouts maps the profile's date formats to Go layouts.
+var dateFormatLayouts = map[string]string{
+	"YYYY-MM-DD": "2006-01-02",
+	"DD/MM/YYYY": "02/01/2006",
+	"MM/DD/YYYY": "01/02/2006",
+	"DD.MM.YYYY": "02.01.2006",
+}
+
+//go:embed mail_templates/*.tmpl
+var mailTemplates embed.FS
+
+var (
+	digestText = texttemplate.Must(texttemplate.ParseFS(mailTemplates, "mail_templates/digest.txt.tmpl"))
+	digestHTML = htmltemplate.Must(htmltemplate.ParseFS(mailTemplates, "mail_templates/digest.html.tmpl"))
+)
+
+type DigestTask struct {
+	Title    string
+	Priority string
+	When     string
+}
+
+type DigestSection struct {
+	Heading string
+	Tasks   []DigestTask
+}
+
+type Digest struct {
+	Period    string
+	Date      string
+	Overdue   DigestSection
+	Due       DigestSection
+	Completed DigestSection
+}
+
+func (d Digest) empty() bool {
+	return len(d.Overdue.Tasks) == 0 && len(d.Due.Tasks) == 0 && len(d.Completed.Tasks) == 0
+}
+
+// lastDigestSlot returns the most recent time at or before now that the user's
+// digest was scheduled for: digest_hour local time every day, or on the first
+// day of the week for weekly digests.
+func lastDigestSlot(user User, prefs UserPrefs, now time.Time) time.Time {
+	local := now.In(prefs.Location)
+	y, m, d := local.Date()
+
+	step := 1
+	if user.DigestFrequency == "WEEKLY" {
+		step = 7
+		d -= (int(local.Weekday()) - int(prefs.WeekStart) + 7) % 7
+	}
+
+	slot := time.Date(y, m, d, user.DigestHour, 0, 0, 0, prefs.Location)
+	if slot.After(now) {
+		slot = time.Date(y, m, d-step, user.DigestHour, 0, 0, 0, prefs.Location)
+	}
+	return slot
+}
+
+// buildDigest collects the user's overdue tasks, the tasks due today (or over
+// the coming week for weekly digests) and those completed since the previous digest.
+func buildDigest(ctx context.Context, user User, prefs UserPrefs, now time.Time) (Digest, error) {
+	layout, ok := dateFormatLayouts[user.DateFormat]
+	if !ok {
+		layout = dateLayout
+	}
+	today := calendarDay(now, prefs.Location)
+
+	digest := Digest{
+		Period:    "daily",
+		Date:      today.Format(layout),
+		Overdue:   DigestSection{Heading: "Overdue"},
+		Due:       DigestSection{Heading: "Due today"},
+		Completed: DigestSection{Heading: "Completed since yesterday"},
+	}
+	days := 1
+	if user.DigestFrequency == "WEEKLY" {
+		days = 7
+		digest.Period = "weekly"
+		digest.Due.Heading = "Due this week"
+		digest.Completed.Heading = "Completed last week"
+	}
+
+	// describe renders when a task was due or completed from the user's point of view
+	describe := func(at *time.Time, allDay bool) string {
+		switch {
+		case at == nil:
+			return ""
+		case allDay:
+			return at.UTC().Format(layout)
+		case calendarDay(*at, prefs.Location).Equal(today):
+			return at.In(prefs.Location).Format("15:04")
+		default:
+			return at.In(prefs.Location).Format(layout + " 15:04")
+		}
+	}
+
+	open := func() *gorm.DB {
+		return db.WithContext(ctx).
+			Where("user_id = ? AND status <> ? AND archived_at IS NULL", user.UserID, "DONE").
+			Order("deadline").
+			Limit(maxDigestTasks)
+	}
+
+	var overdue, due, completed []Task
+	if err := applyDueFilter(open(), "overdue", now, prefs).Find(&overdue).Error; err != nil {
+		return digest, err
+	}
+	if err := deadlineWithin(open(), today, days, prefs.Location).Find(&due).Error; err != nil {
+		return digest, err
+	}
+	if err := db.WithContext(ctx).
+		Where("user_id = ? AND completed_at >= ? AND completed_at < ?", user.UserID, now.AddDate(0, 0, -days), now).
+		Order("completed_at").
+		Limit(maxDigestTasks).
+		Find(&completed).Error; err != nil {
+		return digest, err
+	}
+
+	for _, task := range overdue {
+		digest.Overdue.Tasks = append(digest.Overdue.Tasks, DigestTask{task.Title, task.Priority, describe(task.Deadline, task.AllDay)})
+	}
+	for _, task := range due {
+		// Tasks that fell overdue earlier today are already listed above
+		if isOverdue(task, now, prefs) {
+			continue
+		}
+		digest.Due.Tasks = append(digest.Due.Tasks, DigestTask{task.Title, task.Priority, describe(task.Deadline, task.AllDay)})
+	}
+	for _, task := range completed {
+		digest.Completed.Tasks = append(digest.Completed.Tasks, DigestTask{task.Title, task.Priority, describe(task.CompletedAt, false)})
+	}
+	return digest, nil
+}
+
+// renderDigest turns a digest into an email for the user.
+func renderDigest(user User, digest Digest) (Message, error) {
+	var text, html bytes.Buffer
+	if err := digestText.Execute(&text, digest); err != nil {
+		return Message{}, err
+	}
+	if err := digestHTML.Execute(&html, digest); err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		To:      user.Email,
+		Subject: fmt.Sprintf("Your %s TaskNest digest for %s", digest.Period, digest.Date),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// sendDigest builds and delivers one user's digest, reporting whether there
+// was anything to send. Empty digests are skipped.
+func sendDigest(ctx context.Context, user User, prefs UserPrefs, now time.Time) (bool, error) {
+	digest, err := buildDigest(ctx, user, prefs, now)
+	if err != nil {
+		return false, fmt.Errorf("unable to build digest: %w", err)
+	}
+	if digest.empty() {
+		return false, nil
+	}
+
+	msg, err := renderDigest(user, digest)
+	if err != nil {
+		return false, fmt.Errorf("unable to render digest: %w", err)
+	}
+	return true, mailer.Send(ctx, msg)
+}
+
+// sendDueDigests sends the digests whose slot has come up since they were last sent.
+// Each digest is claimed by stamping digest_sent_at first, so several replicas
+// running this at once won't send duplicates.
+func sendDueDigests(ctx context.Context, now time.Time) (int, error) {
+	var users []User
+	if err := db.WithContext(ctx).Where("digest_frequency <> ?", "NONE").Find(&users).Error; err != nil {
+		return 0, err
+	}
+
+	sent := 0
+	for _, user := range users {
+		prefs := prefsFor(user)
+		slot := lastDigestSlot(user, prefs, now)
+		if now.Sub(slot) > digestGrace || (user.DigestSentAt != nil && !user.DigestSentAt.Before(slot)) {
+			continue
+		}
+
+		claim := db.WithContext(ctx).Model(&User{}).
+			Where("user_id = ? AND (digest_sent_at IS NULL OR digest_sent_at < ?)", user.UserID, slot).
+			Update("digest_sent_at", now)
+		if claim.Error != nil {
+			return sent, claim.Error
+		}
+		if claim.RowsAffected == 0 {
+			continue
+		}
+
+		ok, err := sendDigest(ctx, user, prefs, now)
+		if err != nil {
+			slog.Error("Couldn't send digest", "user_id", user.UserID, "err", err)
+			// Release the claim so the next run retries
+			if err := db.WithContext(ctx).Model(&User{}).
+				Where("user_id = ?", user.UserID).
+				Update("digest_sent_at", user.DigestSentAt).Error; err != nil {
+				slog.Error("Couldn't release digest claim", "user_id", user.UserID, "err", err)
+			}
+			continue
+		}
+		if ok {
+			sent++
+		}
+	}
+	return sent, nil
+}
+
+// runDigestScheduler checks for due digests every interval until ctx is done.
+func runDigestScheduler(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		n, err := sendDueDigests(ctx, time.Now())
+		if err != nil {
+			slog.Error("Sending digests failed", "err", err)
+		} else if n > 0 {
+			slog.Info("Sent digests", "count", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
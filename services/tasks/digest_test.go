@@ -0,0 +1,155 @@
+package main
+
+import (
+	"context"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLastDigestSlot(t *testing.T) {
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Thursday 2026-10-15, 07:30 UTC and 08:30 in Lisbon
+	now := time.Date(2026, 10, 15, 7, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		name  string
+		user  User
+		prefs UserPrefs
+		want  time.Time
+	}{
+		{"daily, slot passed today", User{DigestFrequency: "DAILY", DigestHour: 7}, UserPrefs{Location: time.UTC}, time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)},
+		{"daily, on the hour", User{DigestFrequency: "DAILY", DigestHour: 7}, UserPrefs{Location: time.UTC, WeekStart: time.Monday}, time.Date(2026, 10, 15, 7, 0, 0, 0, time.UTC)},
+		{"daily, slot still to come", User{DigestFrequency: "DAILY", DigestHour: 8}, UserPrefs{Location: time.UTC}, time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)},
+		{"daily, in the user's timezone", User{DigestFrequency: "DAILY", DigestHour: 8}, UserPrefs{Location: lisbon}, time.Date(2026, 10, 15, 8, 0, 0, 0, lisbon)},
+		{"weekly, week starting monday", User{DigestFrequency: "WEEKLY", DigestHour: 8}, UserPrefs{Location: time.UTC, WeekStart: time.Monday}, time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)},
+		{"weekly, starting today but later", User{DigestFrequency: "WEEKLY", DigestHour: 9}, UserPrefs{Location: time.UTC, WeekStart: time.Thursday}, time.Date(2026, 10, 8, 9, 0, 0, 0, time.UTC)},
+		{"weekly, starting today and passed", User{DigestFrequency: "WEEKLY", DigestHour: 6}, UserPrefs{Location: time.UTC, WeekStart: time.Thursday}, time.Date(2026, 10, 15, 6, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := lastDigestSlot(tt.user, tt.prefs, now); !got.Equal(tt.want) {
+				t.Errorf("lastDigestSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// At the end of October the clocks go back, the slot stays at 8:00 local time
+	afterDST := time.Date(2026, 10, 26, 9, 0, 0, 0, time.UTC)
+	if got, want := lastDigestSlot(User{DigestFrequency: "DAILY", DigestHour: 8}, UserPrefs{Location: lisbon}, afterDST), time.Date(2026, 10, 26, 8, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("after the clocks changed: %v, want %v", got, want)
+	}
+}
+
+func TestRenderDigest(t *testing.T) {
+	digest := Digest{
+		Period:    "daily",
+		Date:      "15/10/2026",
+		Overdue:   DigestSection{Heading: "Overdue", Tasks: []DigestTask{{"File <taxes>", "HIGH", "14/10/2026"}}},
+		Due:       DigestSection{Heading: "Due today", Tasks: []DigestTask{{"Call Ana", "LOW", "17:00"}, {"Water plants", "MEDIUM", ""}}},
+		Completed: DigestSection{Heading: "Completed since yesterday"},
+	}
+
+	msg, err := renderDigest(User{Email: "ana@example.com"}, digest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if msg.To != "ana@example.com" || msg.Subject != "Your daily TaskNest digest for 15/10/2026" {
+		t.Errorf("message = %q to %q", msg.Subject, msg.To)
+	}
+
+	for _, want := range []string{"Overdue\n  - File <taxes> [HIGH] (14/10/2026)\n", "  - Call Ana [LOW] (17:00)\n", "  - Water plants [MEDIUM]\n"} {
+		if !strings.Contains(msg.Text, want) {
+			t.Errorf("text body is missing %q:\n%s", want, msg.Text)
+		}
+	}
+	if strings.Contains(msg.Text, "Completed since yesterday") {
+		t.Errorf("text body lists the empty section:\n%s", msg.Text)
+	}
+
+	if !strings.Contains(msg.HTML, "File &lt;taxes&gt;") || strings.Contains(msg.HTML, "<taxes>") {
+		t.Errorf("task titles aren't escaped in the HTML body:\n%s", msg.HTML)
+	}
+	if strings.Contains(msg.HTML, "Completed since yesterday") {
+		t.Errorf("HTML body lists the empty section:\n%s", msg.HTML)
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "mail")
+	m, err := NewFileMailer(dir, "TaskNest <no-reply@tasknest.local>")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Send(context.Background(), Message{
+		To:      "ana/../@example.com",
+		Subject: "Your daily TaskNest digest — Ações",
+		Text:    "Overdue\n  - Café",
+		HTML:    "<p>Café</p>",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || !strings.HasSuffix(files[0].Name(), "-ana_.._@example.com.eml") {
+		t.Fatalf("files = %v", files)
+	}
+	f, err := os.Open(filepath.Join(dir, files[0].Name()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	parsed, err := mail.ReadMessage(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "Your daily TaskNest digest — Ações" {
+		t.Errorf("subject = %q, %v", subject, err)
+	}
+	if parsed.Header.Get("From") != "TaskNest <no-reply@tasknest.local>" || parsed.Header.Get("To") != "ana/../@example.com" {
+		t.Errorf("header = %v", parsed.Header)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("content type = %q, %v", mediaType, err)
+	}
+	// Line breaks in the text part go out as CRLF, as SMTP expects
+	parts := multipart.NewReader(parsed.Body, params["boundary"])
+	for _, want := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", "Overdue\r\n  - Café"},
+		{"text/html; charset=utf-8", "<p>Café</p>"},
+	} {
+		part, err := parts.NextRawPart()
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.Header.Get("Content-Type") != want.contentType || string(body) != want.body {
+			t.Errorf("part %s = %q", part.Header.Get("Content-Type"), body)
+		}
+	}
+	if _, err := parts.NextPart(); err != io.EOF {
+		t.Errorf("more than two parts: %v", err)
+	}
+}
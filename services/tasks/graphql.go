@@ -0,0 +1,475 @@
+package main
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/graph-gophers/dataloader/v7"
+	"github.com/graph-gophers/graphql-go"
+	"github.com/graph-gophers/graphql-go/relay"
+)
+
+//go:embed schema.graphql
+var graphqlSchema string
+
+// maxPageSize caps the first argument of connections.
+const maxPageSize = 100
+
+// newGraphQLHandler serves the schema over HTTP with tasks answering the task
+// queries and relations loading what the tasks refer to.
+//
+// @Summary Query tasks with GraphQL
+// @Description Fetch the user's tasks together with their owner, comments, attachments and time entries in one request. The schema is at services/tasks/schema.graphql and can be introspected.
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param query body GraphQLRequest true "GraphQL query and variables"
+// @Success 200 {object} GraphQLResponse
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Router /tasks/graphql [post]
+func newGraphQLHandler(tasks TaskOperations, relations TaskRelations) http.HandlerFunc {
+	handler := &relay.Handler{
+		Schema: graphql.MustParseSchema(graphqlSchema, &queryResolver{tasks: tasks},
+			graphql.UseStringDescriptions(),
+			// Comment replies nest arbitrarily, keep queries from following them forever
+			graphql.MaxDepth(10),
+			// Resolvers block while their loader collects keys. Let the four
+			// relations of a full page wait at once, so each loader sees all of it.
+			graphql.MaxParallelism(4*maxPageSize),
+		),
+	}
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		userID := requestUserID(r)
+
+		ctx := context.WithValue(r.Context(), gqlContextKey{}, newGQLRequest(relations, userID))
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	}
+}
+
+type gqlContextKey struct{}
+
+// gqlRequest is what the resolvers of one GraphQL request share: the caller
+// and the loaders that batch the lookups of related entities.
+type gqlRequest struct {
+	userID      uuid.UUID
+	users       *dataloader.Loader[uuid.UUID, *User]
+	comments    *dataloader.Loader[uuid.UUID, []*Comment]
+	attachments *dataloader.Loader[uuid.UUID, []Attachment]
+	timeEntries *dataloader.Loader[uuid.UUID, []TimeEntry]
+}
+
+// batchWait is how long a loader collects keys before querying. Sibling fields
+// resolve concurrently, so their lookups arrive well within it.
+var batchWait = 2 * time.Millisecond
+
+func newGQLRequest(relations TaskRelations, userID uuid.UUID) *gqlRequest {
+	return &gqlRequest{
+		userID:      userID,
+		users:       dataloader.NewBatchedLoader(loadUsers(relations), dataloader.WithWait[uuid.UUID, *User](batchWait)),
+		comments:    dataloader.NewBatchedLoader(loadComments(relations), dataloader.WithWait[uuid.UUID, []*Comment](batchWait)),
+		attachments: dataloader.NewBatchedLoader(loadAttachments(relations, userID), dataloader.WithWait[uuid.UUID, []Attachment](batchWait)),
+		timeEntries: dataloader.NewBatchedLoader(loadTimeEntries(relations, userID), dataloader.WithWait[uuid.UUID, []TimeEntry](batchWait)),
+	}
+}
+
+func gqlRequestFrom(ctx context.Context) *gqlRequest {
+	return ctx.Value(gqlContextKey{}).(*gqlRequest)
+}
+
+// Batch functions. Each returns one result per key, in the order of the keys.
+
+func loadUsers(relations TaskRelations) dataloader.BatchFunc[uuid.UUID, *User] {
+	return func(ctx context.Context, userIDs []uuid.UUID) []*dataloader.Result[*User] {
+		users, err := relations.Users(ctx, userIDs)
+
+		byID := make(map[uuid.UUID]*User, len(users))
+		for i := range users {
+			byID[users[i].UserID] = &users[i]
+		}
+		results := make([]*dataloader.Result[*User], len(userIDs))
+		for i, id := range userIDs {
+			results[i] = &dataloader.Result[*User]{Data: byID[id], Error: err}
+		}
+		return results
+	}
+}
+
+func loadComments(relations TaskRelations) dataloader.BatchFunc[uuid.UUID, []*Comment] {
+	return func(ctx context.Context, taskIDs []uuid.UUID) []*dataloader.Result[[]*Comment] {
+		comments, err := relations.Comments(ctx, taskIDs)
+
+		// Thread them like the REST listing does, per task
+		byID := make(map[uuid.UUID]*Comment, len(comments))
+		for _, c := range comments {
+			byID[c.CommentID] = c
+		}
+		threads := map[uuid.UUID][]*Comment{}
+		for _, c := range comments {
+			if c.ParentID != nil {
+				if parent, ok := byID[*c.ParentID]; ok {
+					parent.Replies = append(parent.Replies, c)
+					continue
+				}
+			}
+			threads[c.TaskID] = append(threads[c.TaskID], c)
+		}
+
+		results := make([]*dataloader.Result[[]*Comment], len(taskIDs))
+		for i, id := range taskIDs {
+			results[i] = &dataloader.Result[[]*Comment]{Data: threads[id], Error: err}
+		}
+		return results
+	}
+}
+
+func loadAttachments(relations TaskRelations, userID uuid.UUID) dataloader.BatchFunc[uuid.UUID, []Attachment] {
+	return func(ctx context.Context, taskIDs []uuid.UUID) []*dataloader.Result[[]Attachment] {
+		attachments, err := relations.Attachments(ctx, userID, taskIDs)
+
+		byTask := map[uuid.UUID][]Attachment{}
+		for _, a := range attachments {
+			byTask[a.TaskID] = append(byTask[a.TaskID], a)
+		}
+		results := make([]*dataloader.Result[[]Attachment], len(taskIDs))
+		for i, id := range taskIDs {
+			results[i] = &dataloader.Result[[]Attachment]{Data: byTask[id], Error: err}
+		}
+		return results
+	}
+}
+
+func loadTimeEntries(relations TaskRelations, userID uuid.UUID) dataloader.BatchFunc[uuid.UUID, []TimeEntry] {
+	return func(ctx context.Context, taskIDs []uuid.UUID) []*dataloader.Result[[]TimeEntry] {
+		entries, err := relations.TimeEntries(ctx, userID, taskIDs)
+
+		byTask := map[uuid.UUID][]TimeEntry{}
+		for _, e := range entries {
+			byTask[e.TaskID] = append(byTask[e.TaskID], e)
+		}
+		results := make([]*dataloader.Result[[]TimeEntry], len(taskIDs))
+		for i, id := range taskIDs {
+			results[i] = &dataloader.Result[[]TimeEntry]{Data: byTask[id], Error: err}
+		}
+		return results
+	}
+}
+
+// gqlError hides the details of unexpected errors from GraphQL clients, whose
+// responses carry error messages next to partial data.
+func gqlError(err error) error {
+	var input inputError
+	if errors.As(err, &input) {
+		return input
+	}
+	slog.Error("Couldn't resolve GraphQL field", "err", err)
+	return errors.New("Internal Server Error")
+}
+
+func gqlTime(t *time.Time) *graphql.Time {
+	if t == nil {
+		return nil
+	}
+	return &graphql.Time{Time: *t}
+}
+
+func gqlInt(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
+
+// Task cursors are opaque to clients; underneath they're offsets into the listing.
+
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("offset:" + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, inputError("Invalid cursor")
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), "offset:"))
+	if err != nil || offset < 0 || !strings.HasPrefix(string(raw), "offset:") {
+		return 0, inputError("Invalid cursor")
+	}
+	return offset, nil
+}
+
+type queryResolver struct {
+	tasks TaskOperations
+}
+
+func (q *queryResolver) Viewer(ctx context.Context) (*userResolver, error) {
+	req := gqlRequestFrom(ctx)
+	return loadUser(ctx, req.userID)
+}
+
+func (q *queryResolver) Task(ctx context.Context, args struct{ ID graphql.ID }) (*taskResolver, error) {
+	taskID, err := uuid.Parse(string(args.ID))
+	if err != nil {
+		return nil, nil
+	}
+
+	task, err := q.tasks.Get(ctx, gqlRequestFrom(ctx).userID, taskID)
+	if errors.Is(err, errTaskNotFound) {
+		return nil, nil
+	} else if err != nil {
+		return nil, gqlError(err)
+	}
+	return &taskResolver{task: task}, nil
+}
+
+type taskFilterInput struct {
+	Status   *string
+	Priority *string
+	Label    *string
+	Due      *string
+	Archive  *string
+}
+
+type taskOrderInput struct {
+	Field     string
+	Direction string
+}
+
+type tasksArgs struct {
+	Filter  *taskFilterInput
+	OrderBy *taskOrderInput
+	First   int32
+	After   *string
+}
+
+// filters converts the arguments to the REST API's query parameters.
+func (args tasksArgs) filters() Filters {
+	var filters Filters
+	if f := args.Filter; f != nil {
+		if f.Status != nil {
+			filters.Status = *f.Status
+		}
+		if f.Priority != nil {
+			filters.Priority = *f.Priority
+		}
+		if f.Label != nil {
+			filters.Label = *f.Label
+		}
+		if f.Due != nil {
+			filters.Due = strings.ToLower(*f.Due)
+		}
+		if f.Archive != nil {
+			filters.Archive = map[string]string{"EXCLUDE": "false", "INCLUDE": "true", "ONLY": "only"}[*f.Archive]
+		}
+	}
+
+	if o := args.OrderBy; o != nil {
+		filters.Sort = strings.ToLower(o.Field)
+		filters.Order = strings.ToLower(o.Direction)
+	}
+	return filters
+}
+
+func (q *queryResolver) Tasks(ctx context.Context, args tasksArgs) (*taskConnection, error) {
+	first := int(args.First)
+	if first < 0 || first > maxPageSize {
+		return nil, inputError(fmt.Sprintf("first must be between 0 and %d", maxPageSize))
+	}
+
+	offset := 0
+	if args.After != nil {
+		after, err := decodeCursor(*args.After)
+		if err != nil {
+			return nil, err
+		}
+		offset = after + 1
+	}
+
+	page, err := q.tasks.List(ctx, gqlRequestFrom(ctx).userID, args.filters(), offset, first)
+	if err != nil {
+		return nil, gqlError(err)
+	}
+	return &taskConnection{page: page, offset: offset}, nil
+}
+
+type taskConnection struct {
+	page   TaskPage
+	offset int
+}
+
+func (c *taskConnection) TotalCount() int32 { return int32(c.page.Total) }
+
+func (c *taskConnection) Edges() []*taskEdge {
+	edges := make([]*taskEdge, len(c.page.Tasks))
+	for i, task := range c.page.Tasks {
+		edges[i] = &taskEdge{cursor: encodeCursor(c.offset + i), node: &taskResolver{task: task}}
+	}
+	return edges
+}
+
+func (c *taskConnection) PageInfo() *pageInfo {
+	info := &pageInfo{
+		hasNext:     int64(c.offset+len(c.page.Tasks)) < c.page.Total,
+		hasPrevious: c.offset > 0,
+	}
+	if n := len(c.page.Tasks); n > 0 {
+		start, end := encodeCursor(c.offset), encodeCursor(c.offset+n-1)
+		info.start, info.end = &start, &end
+	}
+	return info
+}
+
+type taskEdge struct {
+	cursor string
+	node   *taskResolver
+}
+
+func (e *taskEdge) Cursor() string      { return e.cursor }
+func (e *taskEdge) Node() *taskResolver { return e.node }
+
+type pageInfo struct {
+	hasNext, hasPrevious bool
+	start, end           *string
+}
+
+func (p *pageInfo) HasNextPage() bool     { return p.hasNext }
+func (p *pageInfo) HasPreviousPage() bool { return p.hasPrevious }
+func (p *pageInfo) StartCursor() *string  { return p.start }
+func (p *pageInfo) EndCursor() *string    { return p.end }
+
+type taskResolver struct {
+	task Task
+}
+
+func (t *taskResolver) ID() graphql.ID             { return graphql.ID(t.task.TaskID.String()) }
+func (t *taskResolver) Title() string              { return t.task.Title }
+func (t *taskResolver) Description() string        { return t.task.Description }
+func (t *taskResolver) CreationDate() graphql.Time { return graphql.Time{Time: t.task.CreationDate} }
+func (t *taskResolver) Deadline() *graphql.Time    { return gqlTime(t.task.Deadline) }
+func (t *taskResolver) AllDay() bool               { return t.task.AllDay }
+func (t *taskResolver) Status() string             { return t.task.Status }
+func (t *taskResolver) Priority() string           { return t.task.Priority }
+func (t *taskResolver) EstimateMinutes() *int32    { return gqlInt(t.task.EstimateMinutes) }
+func (t *taskResolver) EstimatePoints() *int32     { return gqlInt(t.task.EstimatePoints) }
+func (t *taskResolver) Labels() []string           { return t.task.Labels }
+func (t *taskResolver) Project() string            { return t.task.Project }
+func (t *taskResolver) StartedAt() *graphql.Time   { return gqlTime(t.task.StartedAt) }
+func (t *taskResolver) CompletedAt() *graphql.Time { return gqlTime(t.task.CompletedAt) }
+func (t *taskResolver) ArchivedAt() *graphql.Time  { return gqlTime(t.task.ArchivedAt) }
+func (t *taskResolver) IsOverdue() bool            { return t.task.IsOverdue }
+func (t *taskResolver) TrackedSeconds() int32      { return int32(t.task.TrackedSeconds) }
+
+func (t *taskResolver) Owner(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, t.task.UserID)
+}
+
+func (t *taskResolver) Comments(ctx context.Context) ([]*commentResolver, error) {
+	comments, err := gqlRequestFrom(ctx).comments.Load(ctx, t.task.TaskID)()
+	if err != nil {
+		return nil, gqlError(err)
+	}
+	return commentResolvers(comments), nil
+}
+
+func (t *taskResolver) Attachments(ctx context.Context) ([]*attachmentResolver, error) {
+	attachments, err := gqlRequestFrom(ctx).attachments.Load(ctx, t.task.TaskID)()
+	if err != nil {
+		return nil, gqlError(err)
+	}
+	resolvers := make([]*attachmentResolver, len(attachments))
+	for i := range attachments {
+		resolvers[i] = &attachmentResolver{attachment: attachments[i]}
+	}
+	return resolvers, nil
+}
+
+func (t *taskResolver) TimeEntries(ctx context.Context) ([]*timeEntryResolver, error) {
+	entries, err := gqlRequestFrom(ctx).timeEntries.Load(ctx, t.task.TaskID)()
+	if err != nil {
+		return nil, gqlError(err)
+	}
+	resolvers := make([]*timeEntryResolver, len(entries))
+	for i := range entries {
+		resolvers[i] = &timeEntryResolver{entry: entries[i]}
+	}
+	return resolvers, nil
+}
+
+func loadUser(ctx context.Context, userID uuid.UUID) (*userResolver, error) {
+	user, err := gqlRequestFrom(ctx).users.Load(ctx, userID)()
+	if err != nil {
+		return nil, gqlError(err)
+	}
+	if user == nil {
+		return nil, nil
+	}
+	return &userResolver{user: *user}, nil
+}
+
+type userResolver struct {
+	user User
+}
+
+func (u *userResolver) ID() graphql.ID              { return graphql.ID(u.user.UserID.String()) }
+func (u *userResolver) Email() string               { return u.user.Email }
+func (u *userResolver) Timezone() string            { return u.user.Timezone }
+func (u *userResolver) WeekStart() int32            { return int32(u.user.WeekStart) }
+func (u *userResolver) DateFormat() string          { return u.user.DateFormat }
+func (u *userResolver) DailyCapacityMinutes() int32 { return int32(u.user.DailyCapacityMinutes) }
+func (u *userResolver) DailyCapacityPoints() int32  { return int32(u.user.DailyCapacityPoints) }
+
+type commentResolver struct {
+	comment *Comment
+}
+
+func commentResolvers(comments []*Comment) []*commentResolver {
+	resolvers := make([]*commentResolver, len(comments))
+	for i, c := range comments {
+		resolvers[i] = &commentResolver{comment: c}
+	}
+	return resolvers
+}
+
+func (c *commentResolver) ID() graphql.ID              { return graphql.ID(c.comment.CommentID.String()) }
+func (c *commentResolver) Body() string                { return c.comment.Body }
+func (c *commentResolver) CreatedAt() graphql.Time     { return graphql.Time{Time: c.comment.CreatedAt} }
+func (c *commentResolver) UpdatedAt() graphql.Time     { return graphql.Time{Time: c.comment.UpdatedAt} }
+func (c *commentResolver) DeletedAt() *graphql.Time    { return gqlTime(c.comment.DeletedAt) }
+func (c *commentResolver) Replies() []*commentResolver { return commentResolvers(c.comment.Replies) }
+
+func (c *commentResolver) Author(ctx context.Context) (*userResolver, error) {
+	return loadUser(ctx, c.comment.UserID)
+}
+
+type attachmentResolver struct {
+	attachment Attachment
+}
+
+func (a *attachmentResolver) ID() graphql.ID      { return graphql.ID(a.attachment.AttachmentID.String()) }
+func (a *attachmentResolver) FileName() string    { return a.attachment.FileName }
+func (a *attachmentResolver) ContentType() string { return a.attachment.ContentType }
+func (a *attachmentResolver) Size() int32         { return int32(a.attachment.Size) }
+func (a *attachmentResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: a.attachment.CreatedAt}
+}
+
+type timeEntryResolver struct {
+	entry TimeEntry
+}
+
+func (e *timeEntryResolver) ID() graphql.ID          { return graphql.ID(e.entry.EntryID.String()) }
+func (e *timeEntryResolver) StartedAt() graphql.Time { return graphql.Time{Time: e.entry.StartedAt} }
+func (e *timeEntryResolver) EndedAt() *graphql.Time  { return gqlTime(e.entry.EndedAt) }
+func (e *timeEntryResolver) Note() string            { return e.entry.Note }
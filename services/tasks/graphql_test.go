@@ -0,0 +1,298 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// queryGraphQL runs a query on behalf of the user and decodes its data into T.
+func queryGraphQL[T any](t *testing.T, handler http.Handler, user uuid.UUID, query string) (T, []GraphQLError) {
+	t.Helper()
+
+	body, err := json.Marshal(GraphQLRequest{Query: query})
+	if err != nil {
+		t.Fatal(err)
+	}
+	req := httptest.NewRequest("POST", "/api/tasks/graphql", bytes.NewReader(body))
+	req.Header.Set("X-User-ID", user.String())
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusOK)
+
+	var resp struct {
+		Data   T
+		Errors []GraphQLError
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	return resp.Data, resp.Errors
+}
+
+func TestGraphQLRelations(t *testing.T) {
+	repo := NewMemoryTaskRepository()
+	handler := requireUser(newGraphQLHandler(NewTaskService(repo, nil), repo))
+
+	task := Task{Title: "Plan sprint", UserID: ana, Status: "TODO", Priority: "LOW"}
+	if err := repo.Create(context.Background(), &task); err != nil {
+		t.Fatal(err)
+	}
+	repo.AddUser(User{UserID: ana, Email: "ana@example.com"})
+	repo.AddUser(User{UserID: bob, Email: "bob@example.com"})
+
+	root := Comment{CommentID: uuid.New(), TaskID: task.TaskID, UserID: ana, Body: "Kickoff on Monday"}
+	repo.AddComment(root)
+	repo.AddComment(Comment{CommentID: uuid.New(), TaskID: task.TaskID, UserID: bob, ParentID: &root.CommentID, Body: "Works for me"})
+	repo.AddAttachment(Attachment{AttachmentID: uuid.New(), TaskID: task.TaskID, UserID: ana, FileName: "agenda.pdf"})
+	// Attachments and time are only ever listed for their owner
+	repo.AddAttachment(Attachment{AttachmentID: uuid.New(), TaskID: task.TaskID, UserID: bob, FileName: "bobs.pdf"})
+	started := time.Date(2026, 10, 15, 9, 0, 0, 0, time.UTC)
+	repo.AddTimeEntry(TimeEntry{EntryID: uuid.New(), TaskID: task.TaskID, UserID: ana, StartedAt: started, Note: "first"})
+	repo.AddTimeEntry(TimeEntry{EntryID: uuid.New(), TaskID: task.TaskID, UserID: ana, StartedAt: started.Add(time.Hour), Note: "second"})
+	repo.AddTimeEntry(TimeEntry{EntryID: uuid.New(), TaskID: task.TaskID, UserID: bob, StartedAt: started, Note: "bob's"})
+
+	type user struct{ Email string }
+	data, errs := queryGraphQL[struct {
+		Task struct {
+			Owner    user
+			Comments []struct {
+				Body    string
+				Author  user
+				Replies []struct {
+					Body   string
+					Author user
+				}
+			}
+			Attachments []struct{ FileName string }
+			TimeEntries []struct{ Note string }
+		}
+	}](t, handler, ana, `{ task(id: "`+task.TaskID.String()+`") {
+		owner { email }
+		comments { body author { email } replies { body author { email } } }
+		attachments { fileName }
+		timeEntries { note }
+	} }`)
+	if len(errs) > 0 {
+		t.Fatalf("errors = %+v", errs)
+	}
+
+	got := data.Task
+	switch {
+	case got.Owner.Email != "ana@example.com":
+		t.Errorf("owner = %+v", got.Owner)
+	case len(got.Comments) != 1 || got.Comments[0].Body != "Kickoff on Monday" || got.Comments[0].Author.Email != "ana@example.com":
+		t.Errorf("comments = %+v", got.Comments)
+	case len(got.Comments[0].Replies) != 1 || got.Comments[0].Replies[0].Author.Email != "bob@example.com":
+		t.Errorf("replies = %+v", got.Comments[0].Replies)
+	case len(got.Attachments) != 1 || got.Attachments[0].FileName != "agenda.pdf":
+		t.Errorf("attachments = %+v", got.Attachments)
+	case len(got.TimeEntries) != 2 || got.TimeEntries[0].Note != "second" || got.TimeEntries[1].Note != "first":
+		t.Errorf("time entries = %+v", got.TimeEntries)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	for _, offset := range []int{0, 1, 99, 12345} {
+		got, err := decodeCursor(encodeCursor(offset))
+		if err != nil || got != offset {
+			t.Errorf("offset %d came back as %d, %v", offset, got, err)
+		}
+	}
+
+	encode := func(raw string) string { return base64.RawURLEncoding.EncodeToString([]byte(raw)) }
+	for _, cursor := range []string{"", "not base64!", encode("offset:"), encode("offset:-1"), encode("offset:x"), encode("page:3"), encode("3")} {
+		_, err := decodeCursor(cursor)
+		var input inputError
+		if !errors.As(err, &input) {
+			t.Errorf("decodeCursor(%q) = %v, want an input error", cursor, err)
+		}
+	}
+}
+
+func TestTasksArgsFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		args tasksArgs
+		want Filters
+	}{
+		{"none", tasksArgs{}, Filters{}},
+		{
+			"every filter",
+			tasksArgs{Filter: &taskFilterInput{Status: ptr("IN_PROGRESS"), Priority: ptr("HIGH"), Label: ptr("work"), Due: ptr("THIS_WEEK"), Archive: ptr("INCLUDE")}},
+			Filters{Status: "IN_PROGRESS", Priority: "HIGH", Label: "work", Due: "this_week", Archive: "true"},
+		},
+		{"archived only", tasksArgs{Filter: &taskFilterInput{Archive: ptr("ONLY")}}, Filters{Archive: "only"}},
+		{"archived excluded", tasksArgs{Filter: &taskFilterInput{Archive: ptr("EXCLUDE")}}, Filters{Archive: "false"}},
+		{"order", tasksArgs{OrderBy: &taskOrderInput{Field: "CREATION_DATE", Direction: "DESC"}}, Filters{Sort: "creation_date", Order: "desc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.args.filters(); got != tt.want {
+				t.Errorf("filters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGraphQLTasksPaging(t *testing.T) {
+	repo := NewMemoryTaskRepository()
+	handler := requireUser(newGraphQLHandler(NewTaskService(repo, nil), repo))
+	for i, title := range []string{"one", "two", "three"} {
+		task := Task{Title: title, UserID: ana, Status: "TODO", Priority: "LOW", CreationDate: time.Date(2026, 10, 1+i, 0, 0, 0, 0, time.UTC)}
+		if title == "two" {
+			task.Project = "Website"
+		}
+		if err := repo.Create(context.Background(), &task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	type page struct {
+		Tasks struct {
+			TotalCount int
+			Edges      []struct {
+				Cursor string
+				Node   struct{ Title, Project string }
+			}
+			PageInfo struct {
+				HasNextPage, HasPreviousPage bool
+				StartCursor, EndCursor       *string
+			}
+		}
+	}
+	query := func(args string) (page, []GraphQLError) {
+		t.Helper()
+		return queryGraphQL[page](t, handler, ana, `{ tasks(`+args+`, orderBy: {field: CREATION_DATE}) {
+			totalCount edges { cursor node { title project } } pageInfo { hasNextPage hasPreviousPage startCursor endCursor }
+		} }`)
+	}
+
+	for _, first := range []string{"-1", "101"} {
+		if _, errs := query("first: " + first); len(errs) != 1 || errs[0].Message != "first must be between 0 and 100" {
+			t.Errorf("first: %s gave errors %+v", first, errs)
+		}
+	}
+
+	data, errs := query("first: 0")
+	if len(errs) > 0 || data.Tasks.TotalCount != 3 || len(data.Tasks.Edges) != 0 || !data.Tasks.PageInfo.HasNextPage || data.Tasks.PageInfo.EndCursor != nil {
+		t.Errorf("first: 0 gave %+v, %+v", data.Tasks, errs)
+	}
+
+	data, errs = query("first: 2")
+	info := data.Tasks.PageInfo
+	switch {
+	case len(errs) > 0:
+		t.Fatalf("errors = %+v", errs)
+	case len(data.Tasks.Edges) != 2 || data.Tasks.Edges[0].Node.Title != "one" || data.Tasks.Edges[1].Node.Title != "two" || data.Tasks.Edges[1].Node.Project != "Website":
+		t.Fatalf("first page = %+v", data.Tasks.Edges)
+	case !info.HasNextPage || info.HasPreviousPage || info.EndCursor == nil || *info.EndCursor != data.Tasks.Edges[1].Cursor:
+		t.Fatalf("first page info = %+v", info)
+	}
+
+	data, errs = query(`first: 2, after: "` + *info.EndCursor + `"`)
+	info = data.Tasks.PageInfo
+	if len(errs) > 0 || len(data.Tasks.Edges) != 1 || data.Tasks.Edges[0].Node.Title != "three" || info.HasNextPage || !info.HasPreviousPage {
+		t.Errorf("second page = %+v, %+v", data.Tasks, errs)
+	}
+
+	if _, errs := query(`first: 2, after: "bogus"`); len(errs) != 1 || errs[0].Message != "Invalid cursor" {
+		t.Errorf("bogus cursor gave errors %+v", errs)
+	}
+}
+
+// countingRelations counts the batches each relation is loaded in.
+type countingRelations struct {
+	TaskRelations
+
+	mu    sync.Mutex
+	calls map[string][]int
+}
+
+func (c *countingRelations) record(relation string, keys int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.calls[relation] = append(c.calls[relation], keys)
+}
+
+func (c *countingRelations) Users(ctx context.Context, userIDs []uuid.UUID) ([]User, error) {
+	c.record("users", len(userIDs))
+	return c.TaskRelations.Users(ctx, userIDs)
+}
+
+func (c *countingRelations) Comments(ctx context.Context, taskIDs []uuid.UUID) ([]*Comment, error) {
+	c.record("comments", len(taskIDs))
+	return c.TaskRelations.Comments(ctx, taskIDs)
+}
+
+func (c *countingRelations) Attachments(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]Attachment, error) {
+	c.record("attachments", len(taskIDs))
+	return c.TaskRelations.Attachments(ctx, userID, taskIDs)
+}
+
+func (c *countingRelations) TimeEntries(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]TimeEntry, error) {
+	c.record("timeEntries", len(taskIDs))
+	return c.TaskRelations.TimeEntries(ctx, userID, taskIDs)
+}
+
+func TestGraphQLBatchesRelations(t *testing.T) {
+	// Wait long enough that a busy test machine can't split the batches
+	saved := batchWait
+	t.Cleanup(func() { batchWait = saved })
+	batchWait = 200 * time.Millisecond
+
+	repo := NewMemoryTaskRepository()
+	relations := &countingRelations{TaskRelations: repo, calls: map[string][]int{}}
+	handler := requireUser(newGraphQLHandler(NewTaskService(repo, nil), relations))
+
+	repo.AddUser(User{UserID: ana, Email: "ana@example.com"})
+	repo.AddUser(User{UserID: bob, Email: "bob@example.com"})
+	for _, title := range []string{"one", "two", "three", "four"} {
+		task := Task{Title: title, UserID: ana, Status: "TODO", Priority: "LOW"}
+		if err := repo.Create(context.Background(), &task); err != nil {
+			t.Fatal(err)
+		}
+		repo.AddComment(Comment{CommentID: uuid.New(), TaskID: task.TaskID, UserID: bob, Body: "On " + title})
+	}
+
+	data, errs := queryGraphQL[struct {
+		Tasks struct {
+			Edges []struct {
+				Node struct {
+					Owner    struct{ Email string }
+					Comments []struct{ Author struct{ Email string } }
+				}
+			}
+		}
+	}](t, handler, ana, `{ tasks(first: 10) { edges { node {
+		owner { email } comments { author { email } } attachments { id } timeEntries { id }
+	} } } }`)
+	if len(errs) > 0 {
+		t.Fatalf("errors = %+v", errs)
+	}
+	if len(data.Tasks.Edges) != 4 {
+		t.Fatalf("%d tasks, want 4", len(data.Tasks.Edges))
+	}
+	for _, edge := range data.Tasks.Edges {
+		if edge.Node.Owner.Email != "ana@example.com" || len(edge.Node.Comments) != 1 || edge.Node.Comments[0].Author.Email != "bob@example.com" {
+			t.Errorf("node = %+v", edge.Node)
+		}
+	}
+
+	// One lookup per relation for all four tasks; owners and comment authors
+	// are resolved at different depths, so users take one batch per level
+	want := map[string][]int{"comments": {4}, "attachments": {4}, "timeEntries": {4}, "users": {1, 1}}
+	if !reflect.DeepEqual(relations.calls, want) {
+		t.Errorf("batches = %v, want %v", relations.calls, want)
+	}
+}
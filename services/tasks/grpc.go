@@ -0,0 +1,405 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"platform/logging"
+	"tasks/taskspb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// taskServer implements the gRPC TaskService on top of the same service layer
+// as the REST handlers.
+type taskServer struct {
+	taskspb.UnimplementedTaskServiceServer
+	tasks TaskOperations
+}
+
+// newGRPCServer returns a server with the task service and reflection registered.
+func newGRPCServer(tasks TaskOperations) *grpc.Server {
+	server := grpc.NewServer(
+		grpc.UnaryInterceptor(unaryUserInterceptor),
+		grpc.StreamInterceptor(streamUserInterceptor),
+	)
+	taskspb.RegisterTaskServiceServer(server, &taskServer{tasks: tasks})
+	reflection.Register(server)
+	return server
+}
+
+type userIDKey struct{}
+
+// callerID reads the user from the x-user-id metadata entry and checks the
+// bearer token in authorization is theirs. Unlike REST calls, which come
+// through the API Gateway, gRPC calls always need a verified token.
+func callerID(ctx context.Context) (uuid.UUID, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("x-user-id")
+	if len(values) == 0 || values[0] == "" {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "Unauthorized User")
+	}
+
+	userID, err := uuid.Parse(values[0])
+	if err != nil {
+		return uuid.Nil, status.Error(codes.Unauthenticated, "Invalid User ID")
+	}
+
+	var token string
+	if values := md.Get("authorization"); len(values) > 0 {
+		token, _ = strings.CutPrefix(values[0], "Bearer ")
+	}
+	if err := checkToken(ctx, token, userID); err != nil {
+		logging.ContextLogger(ctx).Warn("Token verification failed", "user_id", userID.String(), "err", err)
+		return uuid.Nil, status.Error(codes.Unauthenticated, "Unauthorized User")
+	}
+	return userID, nil
+}
+
+// requiresUser reports whether a method acts on behalf of a user. Reflection doesn't.
+func requiresUser(fullMethod string) bool {
+	return strings.HasPrefix(fullMethod, "/"+taskspb.TaskService_ServiceDesc.ServiceName+"/")
+}
+
+func unaryUserInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	if !requiresUser(info.FullMethod) {
+		return handler(ctx, req)
+	}
+
+	userID, err := callerID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(context.WithValue(ctx, userIDKey{}, userID), req)
+}
+
+// userStream carries the caller's ID in the context of a streaming call.
+type userStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *userStream) Context() context.Context { return s.ctx }
+
+func streamUserInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	if !requiresUser(info.FullMethod) {
+		return handler(srv, ss)
+	}
+
+	userID, err := callerID(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &userStream{ServerStream: ss, ctx: context.WithValue(ss.Context(), userIDKey{}, userID)})
+}
+
+// contextUserID returns the user the interceptors, or requireUser for HTTP
+// requests, stored in ctx.
+func contextUserID(ctx context.Context) uuid.UUID {
+	userID, _ := ctx.Value(userIDKey{}).(uuid.UUID)
+	return userID
+}
+
+// grpcError maps a service-layer error to its gRPC status, like writeServiceError does for REST.
+func grpcError(err error) error {
+	var input inputError
+	switch {
+	case errors.As(err, &input):
+		return status.Error(codes.InvalidArgument, input.Error())
+	case errors.Is(err, errTaskNotFound):
+		return status.Error(codes.NotFound, "Task not found")
+	default:
+		slog.Error("Couldn't serve task request", "err", err)
+		return status.Error(codes.Internal, "Internal Server Error")
+	}
+}
+
+// parseTaskID rejects task IDs that can't exist before they reach the database.
+func parseTaskID(taskID string) (uuid.UUID, error) {
+	id, err := uuid.Parse(taskID)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, "Invalid Task ID")
+	}
+	return id, nil
+}
+
+func timestampOrNil(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func int32OrNil(n *int) *int32 {
+	if n == nil {
+		return nil
+	}
+	v := int32(*n)
+	return &v
+}
+
+func intOrNil(n *int32) *int {
+	if n == nil {
+		return nil
+	}
+	v := int(*n)
+	return &v
+}
+
+// taskToProto converts a task to its protobuf form.
+func taskToProto(task Task) *taskspb.Task {
+	return &taskspb.Task{
+		TaskId:          task.TaskID.String(),
+		UserId:          task.UserID.String(),
+		Title:           task.Title,
+		Description:     task.Description,
+		CreationDate:    timestamppb.New(task.CreationDate),
+		Deadline:        timestampOrNil(task.Deadline),
+		AllDay:          task.AllDay,
+		Status:          taskspb.Status(taskspb.Status_value["STATUS_"+task.Status]),
+		Priority:        taskspb.Priority(taskspb.Priority_value["PRIORITY_"+task.Priority]),
+		EstimateMinutes: int32OrNil(task.EstimateMinutes),
+		EstimatePoints:  int32OrNil(task.EstimatePoints),
+		Labels:          task.Labels,
+		StartedAt:       timestampOrNil(task.StartedAt),
+		CompletedAt:     timestampOrNil(task.CompletedAt),
+		ArchivedAt:      timestampOrNil(task.ArchivedAt),
+		IsOverdue:       task.IsOverdue,
+		TrackedSeconds:  task.TrackedSeconds,
+		Project:         task.Project,
+	}
+}
+
+// taskRequest converts a task input to the request the REST API would have received.
+func taskRequest(input *taskspb.TaskInput) (TaskRequest, error) {
+	if input == nil {
+		return TaskRequest{}, status.Error(codes.InvalidArgument, "Invalid input")
+	}
+	if input.GetStatus() == taskspb.Status_STATUS_UNSPECIFIED {
+		return TaskRequest{}, status.Error(codes.InvalidArgument, "Status is required")
+	}
+	if input.GetPriority() == taskspb.Priority_PRIORITY_UNSPECIFIED {
+		return TaskRequest{}, status.Error(codes.InvalidArgument, "Priority is required")
+	}
+
+	req := TaskRequest{
+		Title:           input.GetTitle(),
+		Description:     input.GetDescription(),
+		Status:          strings.TrimPrefix(input.GetStatus().String(), "STATUS_"),
+		Priority:        strings.TrimPrefix(input.GetPriority().String(), "PRIORITY_"),
+		EstimateMinutes: intOrNil(input.EstimateMinutes),
+		EstimatePoints:  intOrNil(input.EstimatePoints),
+	}
+
+	switch deadline := input.GetDeadline().(type) {
+	case *taskspb.TaskInput_DeadlineAt:
+		if err := deadline.DeadlineAt.CheckValid(); err != nil {
+			return TaskRequest{}, status.Error(codes.InvalidArgument, "Invalid date format")
+		}
+		at := deadline.DeadlineAt.AsTime().Format(time.RFC3339Nano)
+		req.Deadline = &at
+	case *taskspb.TaskInput_DeadlineDate:
+		if _, err := time.Parse(dateLayout, deadline.DeadlineDate); err != nil {
+			return TaskRequest{}, status.Error(codes.InvalidArgument, "Invalid date format")
+		}
+		req.Deadline = &deadline.DeadlineDate
+	}
+	return req, nil
+}
+
+// listFilters converts the filters of a list request to the REST API's query parameters.
+func listFilters(req *taskspb.ListTasksRequest) Filters {
+	var filters Filters
+	if req.GetStatus() != taskspb.Status_STATUS_UNSPECIFIED {
+		filters.Status = strings.TrimPrefix(req.GetStatus().String(), "STATUS_")
+	}
+	if req.GetPriority() != taskspb.Priority_PRIORITY_UNSPECIFIED {
+		filters.Priority = strings.TrimPrefix(req.GetPriority().String(), "PRIORITY_")
+	}
+	filters.Label = req.GetLabel()
+
+	if req.GetDue() != taskspb.DueFilter_DUE_FILTER_UNSPECIFIED {
+		filters.Due = strings.ToLower(strings.TrimPrefix(req.GetDue().String(), "DUE_FILTER_"))
+	}
+
+	switch req.GetArchive() {
+	case taskspb.ArchiveFilter_ARCHIVE_FILTER_INCLUDE:
+		filters.Archive = "true"
+	case taskspb.ArchiveFilter_ARCHIVE_FILTER_ONLY:
+		filters.Archive = "only"
+	}
+
+	if req.GetSort() != taskspb.SortField_SORT_FIELD_UNSPECIFIED {
+		filters.Sort = strings.ToLower(strings.TrimPrefix(req.GetSort().String(), "SORT_FIELD_"))
+	}
+	if req.GetDescending() {
+		filters.Order = "desc"
+	}
+	return filters
+}
+
+func (s *taskServer) ListTasks(ctx context.Context, req *taskspb.ListTasksRequest) (*taskspb.ListTasksResponse, error) {
+	page, limit := int(req.GetPage()), int(req.GetPageSize())
+	if page <= 0 {
+		page = 1
+	}
+	if limit <= 0 {
+		limit = 10
+	}
+
+	result, err := s.tasks.List(ctx, contextUserID(ctx), listFilters(req), (page-1)*limit, limit)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &taskspb.ListTasksResponse{Total: result.Total}
+	for _, task := range result.Tasks {
+		resp.Tasks = append(resp.Tasks, taskToProto(task))
+	}
+	return resp, nil
+}
+
+func (s *taskServer) GetTask(ctx context.Context, req *taskspb.GetTaskRequest) (*taskspb.Task, error) {
+	taskID, err := parseTaskID(req.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.tasks.Get(ctx, contextUserID(ctx), taskID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) CreateTask(ctx context.Context, req *taskspb.CreateTaskRequest) (*taskspb.Task, error) {
+	taskReq, err := taskRequest(req.GetTask())
+	if err != nil {
+		return nil, err
+	}
+	taskReq.Labels = req.GetLabels()
+	project := req.GetProject()
+	taskReq.Project = &project
+
+	task, err := s.tasks.Create(ctx, contextUserID(ctx), taskReq)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) QuickAddTask(ctx context.Context, req *taskspb.QuickAddTaskRequest) (*taskspb.Task, error) {
+	task, err := s.tasks.QuickAdd(ctx, contextUserID(ctx), req.GetText())
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) UpdateTask(ctx context.Context, req *taskspb.UpdateTaskRequest) (*taskspb.Task, error) {
+	taskID, err := parseTaskID(req.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+
+	taskReq, err := taskRequest(req.GetTask())
+	if err != nil {
+		return nil, err
+	}
+	if req.GetLabels() != nil {
+		// A non-nil slice tells updateTask to replace the labels, even with none
+		taskReq.Labels = append([]string{}, req.GetLabels().GetValues()...)
+	}
+	taskReq.Project = req.Project
+
+	task, err := s.tasks.Update(ctx, contextUserID(ctx), taskID, taskReq)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToProto(task), nil
+}
+
+func (s *taskServer) DeleteTask(ctx context.Context, req *taskspb.DeleteTaskRequest) (*taskspb.DeleteTaskResponse, error) {
+	taskID, err := parseTaskID(req.GetTaskId())
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.tasks.Delete(ctx, contextUserID(ctx), taskID); err != nil {
+		return nil, grpcError(err)
+	}
+	return &taskspb.DeleteTaskResponse{}, nil
+}
+
+func (s *taskServer) ArchiveTask(ctx context.Context, req *taskspb.ArchiveTaskRequest) (*taskspb.Task, error) {
+	return s.setArchived(ctx, req.GetTaskId(), true)
+}
+
+func (s *taskServer) UnarchiveTask(ctx context.Context, req *taskspb.UnarchiveTaskRequest) (*taskspb.Task, error) {
+	return s.setArchived(ctx, req.GetTaskId(), false)
+}
+
+func (s *taskServer) setArchived(ctx context.Context, id string, archive bool) (*taskspb.Task, error) {
+	taskID, err := parseTaskID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	task, err := s.tasks.SetArchived(ctx, contextUserID(ctx), taskID, archive)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return taskToProto(task), nil
+}
+
+var changeTypes = map[string]taskspb.TaskEvent_Type{
+	"INSERT": taskspb.TaskEvent_TYPE_CREATED,
+	"UPDATE": taskspb.TaskEvent_TYPE_UPDATED,
+	"DELETE": taskspb.TaskEvent_TYPE_DELETED,
+}
+
+func (s *taskServer) WatchTasks(req *taskspb.WatchTasksRequest, stream grpc.ServerStreamingServer[taskspb.TaskEvent]) error {
+	ctx := stream.Context()
+	userID := contextUserID(ctx)
+
+	changes, unsubscribe := taskEvents.subscribe(userID)
+	defer unsubscribe()
+
+	for {
+		var change taskChange
+		select {
+		case <-ctx.Done():
+			return nil
+		case c, ok := <-changes:
+			if !ok {
+				return status.Error(codes.Unavailable, "Changes may have been missed, watch again and reload the tasks")
+			}
+			change = c
+		}
+
+		event := &taskspb.TaskEvent{Type: changeTypes[change.Op], TaskId: change.TaskID.String()}
+		if event.Type != taskspb.TaskEvent_TYPE_DELETED {
+			task, err := s.tasks.Get(ctx, userID, change.TaskID)
+			if errors.Is(err, errTaskNotFound) {
+				// Deleted since, its own event follows
+				continue
+			} else if err != nil {
+				return grpcError(err)
+			}
+			event.Task = taskToProto(task)
+		}
+
+		if err := stream.Send(event); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"tasks/taskspb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// startGRPCServer serves the task service over an in-memory connection and
+// returns a client for it.
+func startGRPCServer(t *testing.T, tasks TaskOperations) taskspb.TaskServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := newGRPCServer(tasks)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return listener.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return taskspb.NewTaskServiceClient(conn)
+}
+
+// asUser returns a context calling on behalf of the user with token.
+func asUser(userID uuid.UUID, token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), "x-user-id", userID.String(), "authorization", "Bearer "+token)
+}
+
+func expectCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("code = %v (%v), want %v", got, err, want)
+	}
+}
+
+func TestGRPCAuthentication(t *testing.T) {
+	client := startGRPCServer(t, NewTaskService(NewMemoryTaskRepository(), nil))
+	req := &taskspb.ListTasksRequest{}
+
+	// Without VERIFY_TOKENS there's nothing to check tokens with, so no call gets through
+	_, err := client.ListTasks(asUser(ana, "unverified"), req)
+	expectCode(t, err, codes.Unauthenticated)
+
+	key := verifyTokens(t)
+	tests := []struct {
+		name string
+		ctx  context.Context
+	}{
+		{"no metadata", context.Background()},
+		{"invalid user ID", metadata.AppendToOutgoingContext(context.Background(), "x-user-id", "ana")},
+		{"no token", metadata.AppendToOutgoingContext(context.Background(), "x-user-id", ana.String())},
+		{"invalid token", asUser(ana, "not-a-token")},
+		{"someone else's token", asUser(ana, idToken(t, key, bob))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.ListTasks(tt.ctx, req)
+			expectCode(t, err, codes.Unauthenticated)
+		})
+	}
+
+	if _, err := client.ListTasks(asUser(ana, idToken(t, key, ana)), req); err != nil {
+		t.Errorf("verified caller: %v", err)
+	}
+}
+
+func TestTaskServer(t *testing.T) {
+	key := verifyTokens(t)
+	client := startGRPCServer(t, NewTaskService(NewMemoryTaskRepository(), nil))
+	asAna, asBob := asUser(ana, idToken(t, key, ana)), asUser(bob, idToken(t, key, bob))
+
+	created, err := client.CreateTask(asAna, &taskspb.CreateTaskRequest{
+		Task: &taskspb.TaskInput{
+			Title:    "Plan sprint",
+			Status:   taskspb.Status_STATUS_TODO,
+			Priority: taskspb.Priority_PRIORITY_HIGH,
+			Deadline: &taskspb.TaskInput_DeadlineDate{DeadlineDate: "2030-01-15"},
+		},
+		Labels:  []string{"work"},
+		Project: "Roadmap",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetUserId() != ana.String() || !created.GetAllDay() || created.GetPriority() != taskspb.Priority_PRIORITY_HIGH || created.GetProject() != "Roadmap" {
+		t.Errorf("created %v", created)
+	}
+
+	if _, err := client.QuickAddTask(asAna, &taskspb.QuickAddTaskRequest{Text: "Pay rent !low #finance"}); err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := client.ListTasks(asAna, &taskspb.ListTasksRequest{Label: "work"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if list.GetTotal() != 1 || list.GetTasks()[0].GetTaskId() != created.GetTaskId() {
+		t.Errorf("listed %v", list)
+	}
+	if list, err := client.ListTasks(asAna, &taskspb.ListTasksRequest{PageSize: 1, Page: 2}); err != nil || list.GetTotal() != 2 || len(list.GetTasks()) != 1 {
+		t.Errorf("second page = %v, %v", list, err)
+	}
+
+	_, err = client.GetTask(asBob, &taskspb.GetTaskRequest{TaskId: created.GetTaskId()})
+	expectCode(t, err, codes.NotFound)
+	_, err = client.GetTask(asAna, &taskspb.GetTaskRequest{TaskId: "task"})
+	expectCode(t, err, codes.InvalidArgument)
+
+	updated, err := client.UpdateTask(asAna, &taskspb.UpdateTaskRequest{
+		TaskId: created.GetTaskId(),
+		Task: &taskspb.TaskInput{
+			Title:    "Plan sprint",
+			Status:   taskspb.Status_STATUS_IN_PROGRESS,
+			Priority: taskspb.Priority_PRIORITY_HIGH,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Labels and the project are kept when the update leaves them out
+	if updated.GetStatus() != taskspb.Status_STATUS_IN_PROGRESS || updated.GetStartedAt() == nil || len(updated.GetLabels()) != 1 || updated.GetProject() != "Roadmap" {
+		t.Errorf("updated %v", updated)
+	}
+	cleared, err := client.UpdateTask(asAna, &taskspb.UpdateTaskRequest{
+		TaskId:  created.GetTaskId(),
+		Task:    &taskspb.TaskInput{Title: "Plan sprint", Status: taskspb.Status_STATUS_IN_PROGRESS, Priority: taskspb.Priority_PRIORITY_HIGH},
+		Project: proto.String(""),
+	})
+	if err != nil || cleared.GetProject() != "" {
+		t.Errorf("cleared project: %v, %v", cleared, err)
+	}
+	_, err = client.UpdateTask(asAna, &taskspb.UpdateTaskRequest{TaskId: created.GetTaskId(), Task: &taskspb.TaskInput{Title: "Plan sprint"}})
+	expectCode(t, err, codes.InvalidArgument)
+
+	if archived, err := client.ArchiveTask(asAna, &taskspb.ArchiveTaskRequest{TaskId: created.GetTaskId()}); err != nil || archived.GetArchivedAt() == nil {
+		t.Errorf("archived %v, %v", archived, err)
+	}
+	if list, err := client.ListTasks(asAna, &taskspb.ListTasksRequest{Archive: taskspb.ArchiveFilter_ARCHIVE_FILTER_ONLY}); err != nil || list.GetTotal() != 1 {
+		t.Errorf("archived tasks = %v, %v", list, err)
+	}
+	if unarchived, err := client.UnarchiveTask(asAna, &taskspb.UnarchiveTaskRequest{TaskId: created.GetTaskId()}); err != nil || unarchived.GetArchivedAt() != nil {
+		t.Errorf("unarchived %v, %v", unarchived, err)
+	}
+
+	_, err = client.DeleteTask(asBob, &taskspb.DeleteTaskRequest{TaskId: created.GetTaskId()})
+	expectCode(t, err, codes.NotFound)
+	if _, err := client.DeleteTask(asAna, &taskspb.DeleteTaskRequest{TaskId: created.GetTaskId()}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.GetTask(asAna, &taskspb.GetTaskRequest{TaskId: created.GetTaskId()})
+	expectCode(t, err, codes.NotFound)
+}
+
+func TestWatchTasks(t *testing.T) {
+	saved := taskEvents
+	taskEvents = &taskBroker{subs: map[uuid.UUID]map[chan taskChange]bool{}}
+	t.Cleanup(func() { taskEvents = saved })
+
+	key := verifyTokens(t)
+	repo := NewMemoryTaskRepository()
+	client := startGRPCServer(t, NewTaskService(repo, nil))
+
+	task := Task{Title: "Plan sprint", UserID: ana, Status: "TODO", Priority: "LOW"}
+	if err := repo.Create(context.Background(), &task); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(asUser(ana, idToken(t, key, ana)))
+	defer cancel()
+	stream, err := client.WatchTasks(ctx, &taskspb.WatchTasksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The server subscribes once the call reaches it
+	for deadline := time.Now().Add(5 * time.Second); ; {
+		taskEvents.mu.Lock()
+		watching := len(taskEvents.subs[ana]) > 0
+		taskEvents.mu.Unlock()
+		if watching {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the watcher never subscribed")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	taskEvents.publish(taskChange{Op: "UPDATE", UserID: ana, TaskID: task.TaskID})
+	// Gone by the time the server loads it, so only its delete event is sent
+	taskEvents.publish(taskChange{Op: "UPDATE", UserID: ana, TaskID: uuid.New()})
+	deleted := uuid.New()
+	taskEvents.publish(taskChange{Op: "DELETE", UserID: ana, TaskID: deleted})
+
+	event, err := stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetType() != taskspb.TaskEvent_TYPE_UPDATED || event.GetTask().GetTitle() != "Plan sprint" {
+		t.Errorf("first event = %v", event)
+	}
+	event, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if event.GetType() != taskspb.TaskEvent_TYPE_DELETED || event.GetTaskId() != deleted.String() || event.GetTask() != nil {
+		t.Errorf("second event = %v", event)
+	}
+
+	taskEvents.reset()
+	_, err = stream.Recv()
+	expectCode(t, err, codes.Unavailable)
+}
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		err  error
+		code codes.Code
+		msg  string
+	}{
+		{inputError("Invalid date format"), codes.InvalidArgument, "Invalid date format"},
+		{errTaskNotFound, codes.NotFound, "Task not found"},
+		{errors.New(`pq: relation "tasks" does not exist`), codes.Internal, "Internal Server Error"},
+	}
+	for _, tt := range tests {
+		s := status.Convert(grpcError(tt.err))
+		if s.Code() != tt.code || s.Message() != tt.msg {
+			t.Errorf("grpcError(%v) = %v %q, want %v %q", tt.err, s.Code(), s.Message(), tt.code, tt.msg)
+		}
+	}
+}
+
+func TestTaskRequest(t *testing.T) {
+	deadline := time.Date(2030, 1, 15, 9, 30, 0, 0, time.UTC)
+	// input returns a valid task input, changed by set
+	input := func(set func(*taskspb.TaskInput)) *taskspb.TaskInput {
+		in := &taskspb.TaskInput{Title: "Plan sprint", Status: taskspb.Status_STATUS_DONE, Priority: taskspb.Priority_PRIORITY_MEDIUM}
+		if set != nil {
+			set(in)
+		}
+		return in
+	}
+
+	tests := []struct {
+		name     string
+		input    *taskspb.TaskInput
+		deadline *string
+		err      string
+	}{
+		{"no input", nil, nil, "Invalid input"},
+		{"no status", &taskspb.TaskInput{Priority: taskspb.Priority_PRIORITY_LOW}, nil, "Status is required"},
+		{"no priority", &taskspb.TaskInput{Status: taskspb.Status_STATUS_TODO}, nil, "Priority is required"},
+		{"no deadline", input(nil), nil, ""},
+		{"deadline at", input(func(in *taskspb.TaskInput) {
+			in.Deadline = &taskspb.TaskInput_DeadlineAt{DeadlineAt: timestamppb.New(deadline)}
+		}), ptr("2030-01-15T09:30:00Z"), ""},
+		{"invalid timestamp", input(func(in *taskspb.TaskInput) {
+			in.Deadline = &taskspb.TaskInput_DeadlineAt{DeadlineAt: &timestamppb.Timestamp{Nanos: -1}}
+		}), nil, "Invalid date format"},
+		{"deadline date", input(func(in *taskspb.TaskInput) { in.Deadline = &taskspb.TaskInput_DeadlineDate{DeadlineDate: "2030-01-15"} }), ptr("2030-01-15"), ""},
+		{"invalid date", input(func(in *taskspb.TaskInput) { in.Deadline = &taskspb.TaskInput_DeadlineDate{DeadlineDate: "15/01/2030"} }), nil, "Invalid date format"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := taskRequest(tt.input)
+			if tt.err != "" {
+				if s := status.Convert(err); s.Code() != codes.InvalidArgument || s.Message() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if req.Status != "DONE" || req.Priority != "MEDIUM" || req.Title != "Plan sprint" {
+				t.Errorf("req = %+v", req)
+			}
+			if (req.Deadline == nil) != (tt.deadline == nil) || req.Deadline != nil && *req.Deadline != *tt.deadline {
+				t.Errorf("deadline = %v, want %v", req.Deadline, tt.deadline)
+			}
+		})
+	}
+}
+
+func TestListFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *taskspb.ListTasksRequest
+		want Filters
+	}{
+		{"none", &taskspb.ListTasksRequest{}, Filters{}},
+		{"status and priority", &taskspb.ListTasksRequest{Status: taskspb.Status_STATUS_IN_PROGRESS, Priority: taskspb.Priority_PRIORITY_HIGH}, Filters{Status: "IN_PROGRESS", Priority: "HIGH"}},
+		{"label", &taskspb.ListTasksRequest{Label: "work"}, Filters{Label: "work"}},
+		{"due this week", &taskspb.ListTasksRequest{Due: taskspb.DueFilter_DUE_FILTER_THIS_WEEK}, Filters{Due: "this_week"}},
+		{"archive excluded", &taskspb.ListTasksRequest{Archive: taskspb.ArchiveFilter_ARCHIVE_FILTER_EXCLUDE}, Filters{}},
+		{"archive included", &taskspb.ListTasksRequest{Archive: taskspb.ArchiveFilter_ARCHIVE_FILTER_INCLUDE}, Filters{Archive: "true"}},
+		{"archive only", &taskspb.ListTasksRequest{Archive: taskspb.ArchiveFilter_ARCHIVE_FILTER_ONLY}, Filters{Archive: "only"}},
+		{"sorted descending", &taskspb.ListTasksRequest{Sort: taskspb.SortField_SORT_FIELD_CREATION_DATE, Descending: true}, Filters{Sort: "creation_date", Order: "desc"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := listFilters(tt.req); got != tt.want {
+				t.Errorf("listFilters() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTaskBroker(t *testing.T) {
+	broker := &taskBroker{subs: map[uuid.UUID]map[chan taskChange]bool{}}
+	change := taskChange{Op: "INSERT", UserID: ana, TaskID: uuid.New()}
+
+	first, unsubscribeFirst := broker.subscribe(ana)
+	second, unsubscribeSecond := broker.subscribe(ana)
+	others, unsubscribeOthers := broker.subscribe(bob)
+	defer unsubscribeOthers()
+
+	broker.publish(change)
+	for _, ch := range []<-chan taskChange{first, second} {
+		if got := <-ch; got != change {
+			t.Errorf("received %v, want %v", got, change)
+		}
+	}
+	if len(others) != 0 {
+		t.Error("another user's watcher got the change")
+	}
+
+	// Unsubscribing closes the channel, and doing it twice is harmless
+	unsubscribeFirst()
+	unsubscribeFirst()
+	if _, ok := <-first; ok {
+		t.Error("first watcher still open after unsubscribing")
+	}
+
+	// A watcher that falls behind is dropped rather than blocking the others
+	for range subscriberBuffer + 1 {
+		broker.publish(change)
+	}
+	for range subscriberBuffer {
+		<-second
+	}
+	if _, ok := <-second; ok {
+		t.Error("slow watcher wasn't dropped")
+	}
+	unsubscribeSecond()
+
+	broker.reset()
+	if _, ok := <-others; ok {
+		t.Error("reset left a watcher open")
+	}
+	if len(broker.subs) != 0 {
+		t.Errorf("%d users still subscribed", len(broker.subs))
+	}
+}
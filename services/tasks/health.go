@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"platform/health"
+)
+
+// jwksCheck makes sure the keys to verify tokens with are at hand, fetching
+// them from Cognito when the cache has expired. It's optional: Cognito being
+// unreachable fails it on every replica at once, and taking them all out of
+// rotation would turn a sign-in outage into an outage of the whole service.
+func jwksCheck() health.Check {
+	return health.Check{Name: "jwks", Optional: true, Run: func(ctx context.Context) error {
+		return tokenVerifier.Keys.Refresh(ctx)
+	}}
+}
+
+// @Summary Liveness probe
+// @Description Returns 200 as long as the process is serving requests. It doesn't look at dependencies, so a database outage doesn't get the task restarted.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Router /tasks/live [get]
+func handleLiveness(w http.ResponseWriter, r *http.Request) {
+	health.HandleLiveness(w, r)
+}
+
+// newReadinessHandler reports whether the service can take traffic.
+//
+// @Summary Readiness probe
+// @Description Checks that the database answers and that its schema is migrated far enough for this version of the service. With VERIFY_TOKENS set it also reports whether the Cognito signing keys can be loaded, answering 200 with status "degraded" when they can't. Every check reports its latency.
+// @Tags Health
+// @Produce json
+// @Success 200 {object} health.Report
+// @Failure 503 {object} health.Report
+// @Router /tasks/ready [get]
+func newReadinessHandler(checks ...health.Check) http.HandlerFunc {
+	return health.NewReadinessHandler(checks...)
+}
@@ -0,0 +1,1144 @@
+//go:build integration
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"platform/health"
+	"platform/testdb"
+
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMain(m *testing.M) {
+	dsn, stop, err := testdb.Database()
+	if err != nil {
+		log.Fatalf("Couldn't set up the test database: %v", err)
+	}
+
+	db, err = InitDB(dsn)
+	if err != nil {
+		stop()
+		log.Fatalf("Couldn't connect to the test database: %v", err)
+	}
+
+	code := m.Run()
+
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	stop()
+	os.Exit(code)
+}
+
+// newIntegrationServer empties the database, signs up ana and bob and routes
+// requests the way main does, with attachments kept in a temporary directory.
+func newIntegrationServer(t *testing.T) *testServer {
+	t.Helper()
+
+	if err := db.Exec("TRUNCATE users CASCADE").Error; err != nil {
+		t.Fatalf("couldn't empty the database: %v", err)
+	}
+	for _, user := range []User{
+		{UserID: ana, Email: "ana@example.com"},
+		{UserID: bob, Email: "bob@example.com"},
+	} {
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatalf("couldn't create user %s: %v", user.Email, err)
+		}
+	}
+
+	blobDir := t.TempDir()
+	store, err := NewLocalBlobStore(blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	blobs = store
+
+	return &testServer{blobDir: blobDir, mux: newMux(NewTaskService(NewGormTaskRepository(db), blobs))}
+}
+
+func setUserColumns(t *testing.T, userID uuid.UUID, columns map[string]any) {
+	t.Helper()
+	if err := db.Model(&User{}).Where("user_id = ?", userID).Updates(columns).Error; err != nil {
+		t.Fatalf("couldn't update user: %v", err)
+	}
+}
+
+// createTask creates a task through the API.
+func (s *testServer) createTask(t *testing.T, userID uuid.UUID, req TaskRequest) Task {
+	t.Helper()
+
+	if req.Status == "" {
+		req.Status = "TODO"
+	}
+	if req.Priority == "" {
+		req.Priority = "MEDIUM"
+	}
+	rec := s.do(t, "POST", "/api/tasks/create", userID.String(), req)
+	expectStatus(t, rec, http.StatusCreated)
+	return decode[Task](t, rec)
+}
+
+func today() time.Time {
+	return calendarDay(time.Now(), time.UTC)
+}
+
+func TestIntegrationHealthCheck(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "GET", "/api/tasks/", "", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != "OK" {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestIntegrationReadiness(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	ready := func(status int) health.Report {
+		t.Helper()
+		rec := s.do(t, "GET", "/api/tasks/ready", "", nil)
+		expectStatus(t, rec, status)
+		return decode[health.Report](t, rec)
+	}
+
+	report := ready(http.StatusOK)
+	if report.Status != "ok" || len(report.Checks) != 2 || report.Checks[0].Name != "database" || report.Checks[1].Name != "migrations" {
+		t.Errorf("report = %+v", report)
+	}
+
+	// A half-applied migration takes the service out of rotation
+	if err := db.Exec("UPDATE schema_migrations SET dirty = true").Error; err != nil {
+		t.Fatal(err)
+	}
+	defer db.Exec("UPDATE schema_migrations SET dirty = false")
+
+	report = ready(http.StatusServiceUnavailable)
+	if report.Status != "failed" || report.Checks[0].Status != "ok" || report.Checks[1].Error != fmt.Sprintf("migration %d is dirty", health.RequiredSchemaVersion) {
+		t.Errorf("report = %+v", report)
+	}
+
+	expectStatus(t, s.do(t, "GET", "/api/tasks/live", "", nil), http.StatusOK)
+}
+
+func TestIntegrationRequireUser(t *testing.T) {
+	s := newIntegrationServer(t)
+	id := uuid.NewString()
+
+	routes := []struct{ method, path string }{
+		{"POST", "/api/tasks/create"},
+		{"POST", "/api/tasks/quick"},
+		{"PUT", "/api/tasks/update/" + id},
+		{"DELETE", "/api/tasks/delete/" + id},
+		{"GET", "/api/tasks/read"},
+		{"GET", "/api/tasks/read/" + id},
+		{"POST", "/api/tasks/graphql"},
+		{"PUT", "/api/tasks/archive/" + id},
+		{"PUT", "/api/tasks/unarchive/" + id},
+		{"POST", "/api/tasks/timer/start/" + id},
+		{"POST", "/api/tasks/timer/stop"},
+		{"POST", "/api/tasks/time/create/" + id},
+		{"GET", "/api/tasks/time/report"},
+		{"GET", "/api/tasks/capacity"},
+		{"GET", "/api/tasks/stats"},
+		{"POST", "/api/tasks/attachments/create/" + id},
+		{"GET", "/api/tasks/attachments/" + id},
+		{"GET", "/api/tasks/attachments/download/" + id},
+		{"DELETE", "/api/tasks/attachments/delete/" + id},
+		{"GET", "/api/tasks/comments/" + id},
+		{"POST", "/api/tasks/comments/create/" + id},
+		{"PUT", "/api/tasks/comments/update/" + id},
+		{"DELETE", "/api/tasks/comments/delete/" + id},
+		{"GET", "/api/tasks/notifications"},
+		{"GET", "/api/tasks/templates"},
+		{"POST", "/api/tasks/templates/create"},
+		{"DELETE", "/api/tasks/templates/delete/" + id},
+		{"POST", "/api/tasks/from-template/" + id},
+	}
+	body := TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW"}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			expectStatus(t, s.do(t, route.method, route.path, "", body), http.StatusUnauthorized)
+			expectStatus(t, s.do(t, route.method, route.path, "not-a-uuid", body), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestIntegrationTaskLifecycle(t *testing.T) {
+	s := newIntegrationServer(t)
+	deadline := time.Now().Add(48 * time.Hour).UTC().Truncate(time.Second)
+
+	created := s.createTask(t, ana, TaskRequest{
+		Title:           "Write report",
+		Description:     "Q3 numbers",
+		Status:          "IN_PROGRESS",
+		Priority:        "HIGH",
+		Deadline:        ptr(deadline.Format(time.RFC3339)),
+		EstimateMinutes: ptr(90),
+		Labels:          []string{"#Work"},
+	})
+	if created.TaskID == uuid.Nil || created.StartedAt == nil {
+		t.Fatalf("created task = %+v", created)
+	}
+	path := created.TaskID.String()
+
+	rec := s.do(t, "GET", "/api/tasks/read/"+path, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	got := decode[Task](t, rec)
+	switch {
+	case got.Title != "Write report" || got.Description != "Q3 numbers" || got.Status != "IN_PROGRESS" || got.Priority != "HIGH":
+		t.Errorf("task = %+v", got)
+	case got.Deadline == nil || !got.Deadline.Equal(deadline) || got.AllDay:
+		t.Errorf("deadline = %v, all day %v, want %v", got.Deadline, got.AllDay, deadline)
+	case got.EstimateMinutes == nil || *got.EstimateMinutes != 90:
+		t.Errorf("estimate = %v", got.EstimateMinutes)
+	case !reflect.DeepEqual([]string(got.Labels), []string{"work"}):
+		t.Errorf("labels = %v", got.Labels)
+	case !got.StartedAt.Equal(*created.StartedAt):
+		t.Errorf("started_at = %v, want %v", got.StartedAt, created.StartedAt)
+	case got.IsOverdue:
+		t.Errorf("task due in two days is overdue")
+	}
+
+	// An update without a deadline clears it; labels it doesn't mention stay
+	rec = s.do(t, "PUT", "/api/tasks/update/"+path, ana.String(), TaskRequest{Title: "Write final report", Status: "DONE", Priority: "LOW"})
+	expectStatus(t, rec, http.StatusOK)
+
+	rec = s.do(t, "GET", "/api/tasks/read/"+path, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	got = decode[Task](t, rec)
+	switch {
+	case got.Title != "Write final report" || got.Status != "DONE" || got.Priority != "LOW":
+		t.Errorf("updated task = %+v", got)
+	case got.Deadline != nil || got.AllDay:
+		t.Errorf("deadline = %v, all day %v after clearing it", got.Deadline, got.AllDay)
+	case got.EstimateMinutes != nil:
+		t.Errorf("estimate = %v after clearing it", *got.EstimateMinutes)
+	case got.CompletedAt == nil || got.StartedAt == nil:
+		t.Errorf("started %v, completed %v", got.StartedAt, got.CompletedAt)
+	case !reflect.DeepEqual([]string(got.Labels), []string{"work"}):
+		t.Errorf("labels = %v", got.Labels)
+	}
+
+	listed := func(query string) int64 {
+		rec := s.do(t, "GET", "/api/tasks/read"+query, ana.String(), nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[TaskPage](t, rec).Total
+	}
+
+	rec = s.do(t, "PUT", "/api/tasks/archive/"+path, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if decode[Task](t, rec).ArchivedAt == nil {
+		t.Errorf("archived_at not set")
+	}
+	if n, archived := listed(""), listed("?archive=only"); n != 0 || archived != 1 {
+		t.Errorf("listed %d, archived %d after archiving", n, archived)
+	}
+
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/unarchive/"+path, ana.String(), nil), http.StatusOK)
+	if n := listed(""); n != 1 {
+		t.Errorf("listed %d after unarchiving", n)
+	}
+
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/delete/"+path, ana.String(), nil), http.StatusOK)
+	expectStatus(t, s.do(t, "GET", "/api/tasks/read/"+path, ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/delete/"+path, ana.String(), nil), http.StatusNotFound)
+}
+
+func TestIntegrationOtherUsersTasks(t *testing.T) {
+	s := newIntegrationServer(t)
+	theirs := s.createTask(t, bob, TaskRequest{Title: "Bob's task"}).TaskID.String()
+
+	requests := []struct {
+		method, path string
+		body         any
+	}{
+		{"GET", "/api/tasks/read/" + theirs, nil},
+		{"PUT", "/api/tasks/update/" + theirs, TaskRequest{Title: "Mine now", Status: "TODO", Priority: "LOW"}},
+		{"DELETE", "/api/tasks/delete/" + theirs, nil},
+		{"PUT", "/api/tasks/archive/" + theirs, nil},
+		{"PUT", "/api/tasks/unarchive/" + theirs, nil},
+		{"POST", "/api/tasks/timer/start/" + theirs, nil},
+		{"POST", "/api/tasks/time/create/" + theirs, TimeEntryRequest{StartedAt: time.Now().Format(time.RFC3339), DurationMinutes: ptr(5)}},
+		{"GET", "/api/tasks/comments/" + theirs, nil},
+		{"POST", "/api/tasks/comments/create/" + theirs, CommentRequest{Body: "Hi"}},
+	}
+	for _, req := range requests {
+		t.Run(req.method+" "+req.path, func(t *testing.T) {
+			expectStatus(t, s.do(t, req.method, req.path, ana.String(), req.body), http.StatusNotFound)
+		})
+	}
+
+	// Malformed IDs are unknown IDs rather than database errors
+	malformed := []struct {
+		method, path string
+		body         any
+	}{
+		{"GET", "/api/tasks/read/42", nil},
+		{"PUT", "/api/tasks/update/42", TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW"}},
+		{"DELETE", "/api/tasks/delete/42", nil},
+		{"PUT", "/api/tasks/archive/42", nil},
+		{"POST", "/api/tasks/timer/start/42", nil},
+		{"GET", "/api/tasks/attachments/42", nil},
+		{"GET", "/api/tasks/attachments/download/42", nil},
+		{"DELETE", "/api/tasks/attachments/delete/42", nil},
+		{"GET", "/api/tasks/comments/42", nil},
+		{"PUT", "/api/tasks/comments/update/42", CommentRequest{Body: "Edited"}},
+		{"DELETE", "/api/tasks/comments/delete/42", nil},
+		{"DELETE", "/api/tasks/templates/delete/42", nil},
+		{"POST", "/api/tasks/from-template/42", nil},
+	}
+	for _, req := range malformed {
+		t.Run(req.method+" "+req.path, func(t *testing.T) {
+			expectStatus(t, s.do(t, req.method, req.path, ana.String(), req.body), http.StatusNotFound)
+		})
+	}
+
+	rec := s.do(t, "GET", "/api/tasks/read/"+theirs, bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Task](t, rec); got.Title != "Bob's task" || got.ArchivedAt != nil {
+		t.Errorf("bob's task was changed: %+v", got)
+	}
+}
+
+func TestIntegrationTaskValidation(t *testing.T) {
+	s := newIntegrationServer(t)
+	task := s.createTask(t, ana, TaskRequest{Title: "Task"})
+	update := "/api/tasks/update/" + task.TaskID.String()
+
+	tests := []struct {
+		name, method, path string
+		body               any
+	}{
+		{"create malformed body", "POST", "/api/tasks/create", "{"},
+		{"create invalid deadline", "POST", "/api/tasks/create", TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Deadline: ptr("next tuesday")}},
+		{"create negative estimate", "POST", "/api/tasks/create", TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", EstimatePoints: ptr(-1)}},
+		{"create invalid label", "POST", "/api/tasks/create", TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Labels: []string{"two words"}}},
+		{"update malformed body", "PUT", update, "[]"},
+		{"update invalid deadline", "PUT", update, TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Deadline: ptr("31/10/2026")}},
+		{"update negative estimate", "PUT", update, TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", EstimateMinutes: ptr(-5)}},
+		{"quick-add without title", "POST", "/api/tasks/quick", QuickAddRequest{Text: "tomorrow !high"}},
+		{"list with invalid due filter", "GET", "/api/tasks/read?due=soon", nil},
+		{"list with invalid archive filter", "GET", "/api/tasks/read?archive=maybe", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.do(t, tt.method, tt.path, ana.String(), tt.body), http.StatusBadRequest)
+		})
+	}
+
+	var count int64
+	if err := db.Model(&Task{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Errorf("%d tasks stored, want 1", count)
+	}
+}
+
+func TestIntegrationListTasks(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	s.createTask(t, ana, TaskRequest{Title: "overdue", Priority: "HIGH", Deadline: ptr(time.Now().Add(-48 * time.Hour).Format(time.RFC3339)), Labels: []string{"work"}})
+	s.createTask(t, ana, TaskRequest{Title: "today", Deadline: ptr(today().Format(dateLayout))})
+	s.createTask(t, ana, TaskRequest{Title: "later", Deadline: ptr(today().AddDate(0, 0, 30).Format(dateLayout)), Labels: []string{"home"}})
+	s.createTask(t, ana, TaskRequest{Title: "someday", Status: "DONE", Priority: "LOW"})
+	archived := s.createTask(t, ana, TaskRequest{Title: "archived"})
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/archive/"+archived.TaskID.String(), ana.String(), nil), http.StatusOK)
+	s.createTask(t, bob, TaskRequest{Title: "bob's"})
+
+	tests := []struct {
+		query  string
+		titles []string
+		total  int64
+	}{
+		{"sort=deadline", []string{"overdue", "today", "later", "someday"}, 4},
+		{"sort=deadline&order=desc", []string{"someday", "later", "today", "overdue"}, 4},
+		{"status=DONE", []string{"someday"}, 1},
+		{"priority=HIGH", []string{"overdue"}, 1},
+		{"label=WORK", []string{"overdue"}, 1},
+		{"due=overdue", []string{"overdue"}, 1},
+		{"due=today", []string{"today"}, 1},
+		{"due=none", []string{"someday"}, 1},
+		{"archive=only", []string{"archived"}, 1},
+		{"archive=true&sort=priority&order=desc&status=TODO&due=none", []string{"archived"}, 1},
+		{"sort=deadline&limit=2&page=2", []string{"later", "someday"}, 4},
+		{"sort=deadline&limit=2&page=3", nil, 4},
+		{"sort=title;drop&status=DONE", []string{"someday"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := s.do(t, "GET", "/api/tasks/read?"+tt.query, ana.String(), nil)
+			expectStatus(t, rec, http.StatusOK)
+
+			page := decode[TaskPage](t, rec)
+			var titles []string
+			for _, task := range page.Tasks {
+				titles = append(titles, task.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) || page.Total != tt.total {
+				t.Errorf("got %v (total %d), want %v (total %d)", titles, page.Total, tt.titles, tt.total)
+			}
+		})
+	}
+}
+
+func TestIntegrationQuickAdd(t *testing.T) {
+	s := newIntegrationServer(t)
+	setUserColumns(t, ana, map[string]any{"timezone": "Europe/Lisbon"})
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tomorrow := calendarDay(time.Now(), lisbon).AddDate(0, 0, 1)
+
+	rec := s.do(t, "POST", "/api/tasks/quick", ana.String(), QuickAddRequest{Text: "Pay rent tomorrow !high #finance"})
+	expectStatus(t, rec, http.StatusCreated)
+	created := decode[Task](t, rec)
+
+	rec = s.do(t, "GET", "/api/tasks/read/"+created.TaskID.String(), ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	got := decode[Task](t, rec)
+	switch {
+	case got.Title != "Pay rent" || got.Priority != "HIGH" || got.Status != "TODO":
+		t.Errorf("task = %+v", got)
+	case got.Deadline == nil || !got.Deadline.Equal(tomorrow) || !got.AllDay:
+		t.Errorf("deadline = %v, all day %v, want %v", got.Deadline, got.AllDay, tomorrow)
+	case !reflect.DeepEqual([]string(got.Labels), []string{"finance"}):
+		t.Errorf("labels = %v", got.Labels)
+	}
+
+	expectStatus(t, s.do(t, "POST", "/api/tasks/quick", ana.String(), "text"), http.StatusBadRequest)
+}
+
+func TestIntegrationTimeTracking(t *testing.T) {
+	s := newIntegrationServer(t)
+	task := s.createTask(t, ana, TaskRequest{Title: "Tracked", Project: ptr("Website")})
+	id := task.TaskID.String()
+
+	rec := s.do(t, "POST", "/api/tasks/timer/start/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusCreated)
+	if entry := decode[TimeEntry](t, rec); entry.TaskID != task.TaskID || entry.EndedAt != nil {
+		t.Errorf("started entry = %+v", entry)
+	}
+	expectStatus(t, s.do(t, "POST", "/api/tasks/timer/start/"+id, ana.String(), nil), http.StatusConflict)
+
+	rec = s.do(t, "POST", "/api/tasks/timer/stop", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if entry := decode[TimeEntry](t, rec); entry.EndedAt == nil || entry.EndedAt.Before(entry.StartedAt) {
+		t.Errorf("stopped entry = %+v", entry)
+	}
+	expectStatus(t, s.do(t, "POST", "/api/tasks/timer/stop", ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "POST", "/api/tasks/timer/stop", bob.String(), nil), http.StatusNotFound)
+
+	startedAt := time.Now().Add(-2 * time.Hour).UTC().Truncate(time.Second)
+	rec = s.do(t, "POST", "/api/tasks/time/create/"+id, ana.String(), TimeEntryRequest{
+		StartedAt:       startedAt.Format(time.RFC3339),
+		DurationMinutes: ptr(30),
+		Note:            "review",
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	if entry := decode[TimeEntry](t, rec); entry.EndedAt == nil || !entry.EndedAt.Equal(startedAt.Add(30*time.Minute)) || entry.Note != "review" {
+		t.Errorf("logged entry = %+v", entry)
+	}
+
+	invalid := map[string]TimeEntryRequest{
+		"invalid started_at":   {StartedAt: "yesterday", DurationMinutes: ptr(5)},
+		"invalid ended_at":     {StartedAt: startedAt.Format(time.RFC3339), EndedAt: ptr("later")},
+		"no end or duration":   {StartedAt: startedAt.Format(time.RFC3339)},
+		"zero duration":        {StartedAt: startedAt.Format(time.RFC3339), DurationMinutes: ptr(0)},
+		"ended before started": {StartedAt: startedAt.Format(time.RFC3339), EndedAt: ptr(startedAt.Add(-time.Minute).Format(time.RFC3339))},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "POST", "/api/tasks/time/create/"+id, ana.String(), req), http.StatusBadRequest)
+		})
+	}
+	expectStatus(t, s.do(t, "POST", "/api/tasks/time/create/"+id, ana.String(), "{"), http.StatusBadRequest)
+
+	rec = s.do(t, "GET", "/api/tasks/read/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Task](t, rec); got.TrackedSeconds < 30*60 {
+		t.Errorf("tracked_seconds = %d, want at least %d", got.TrackedSeconds, 30*60)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/time/report?group_by=task", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	report := decode[TimeReport](t, rec)
+	if len(report.Buckets) != 1 || report.Buckets[0].Key != id || report.Buckets[0].Label != "Tracked" || report.Buckets[0].Seconds < 30*60 {
+		t.Errorf("report = %+v", report)
+	}
+	if report.TotalSeconds != report.Buckets[0].Seconds {
+		t.Errorf("total %d doesn't match the bucket", report.TotalSeconds)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/time/report?group_by=priority", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if report := decode[TimeReport](t, rec); len(report.Buckets) != 1 || report.Buckets[0].Key != "MEDIUM" {
+		t.Errorf("report by priority = %+v", report)
+	}
+
+	other := s.createTask(t, ana, TaskRequest{Title: "Untracked project"}).TaskID.String()
+	expectStatus(t, s.do(t, "POST", "/api/tasks/time/create/"+other, ana.String(), TimeEntryRequest{
+		StartedAt:       startedAt.Format(time.RFC3339),
+		DurationMinutes: ptr(15),
+	}), http.StatusCreated)
+	rec = s.do(t, "GET", "/api/tasks/time/report?group_by=project", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	byProject := decode[TimeReport](t, rec)
+	switch {
+	case len(byProject.Buckets) != 2:
+		t.Errorf("report by project = %+v", byProject)
+	case byProject.Buckets[0].Key != "" || byProject.Buckets[0].Label != "No project" || byProject.Buckets[0].Seconds != 15*60:
+		t.Errorf("tasks without a project = %+v", byProject.Buckets[0])
+	case byProject.Buckets[1].Key != "Website" || byProject.Buckets[1].Seconds != report.Buckets[0].Seconds:
+		t.Errorf("website bucket = %+v, want %d seconds", byProject.Buckets[1], report.Buckets[0].Seconds)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/time/report", bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if report := decode[TimeReport](t, rec); len(report.Buckets) != 0 || report.GroupBy != "day" {
+		t.Errorf("bob's report = %+v", report)
+	}
+
+	for _, query := range []string{"group_by=month", "from=last-week", "from=2026-10-10&to=2026-10-01"} {
+		t.Run(query, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", "/api/tasks/time/report?"+query, ana.String(), nil), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestIntegrationCapacity(t *testing.T) {
+	s := newIntegrationServer(t)
+	setUserColumns(t, ana, map[string]any{"daily_capacity_minutes": 60})
+	day := today()
+
+	s.createTask(t, ana, TaskRequest{Title: "Big job", Deadline: ptr(day.Format(dateLayout)), EstimateMinutes: ptr(90)})
+	s.createTask(t, ana, TaskRequest{Title: "Unestimated", Deadline: ptr(day.Format(dateLayout))})
+	s.createTask(t, ana, TaskRequest{Title: "Done already", Status: "DONE", Deadline: ptr(day.Format(dateLayout)), EstimateMinutes: ptr(100)})
+	s.createTask(t, ana, TaskRequest{Title: "No deadline", EstimateMinutes: ptr(30)})
+
+	query := fmt.Sprintf("?from=%s&to=%s", day.Format(dateLayout), day.AddDate(0, 0, 1).Format(dateLayout))
+	rec := s.do(t, "GET", "/api/tasks/capacity"+query, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	summary := decode[CapacitySummary](t, rec)
+
+	want := []CapacityDay{
+		{Date: day.Format(dateLayout), Tasks: 2, Unestimated: 1, EstimateMinutes: 90, Overloaded: true},
+		{Date: day.AddDate(0, 0, 1).Format(dateLayout)},
+	}
+	if summary.CapacityMinutes != 60 || !reflect.DeepEqual(summary.Days, want) {
+		t.Errorf("summary = %+v, want days %+v", summary, want)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/capacity", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if summary := decode[CapacitySummary](t, rec); len(summary.Days) != 7 || summary.From != day.Format(dateLayout) {
+		t.Errorf("default range = %s with %d days", summary.From, len(summary.Days))
+	}
+
+	for _, query := range []string{"from=today", "from=2026-10-10&to=2026-10-01", "from=2026-01-01&to=2026-12-31"} {
+		t.Run(query, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", "/api/tasks/capacity?"+query, ana.String(), nil), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestIntegrationStats(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	s.createTask(t, ana, TaskRequest{Title: "Shipped", Status: "DONE", Priority: "LOW"})
+	s.createTask(t, ana, TaskRequest{Title: "Late", Priority: "HIGH", Deadline: ptr(time.Now().Add(-48 * time.Hour).Format(time.RFC3339))})
+	s.createTask(t, ana, TaskRequest{Title: "Open"})
+
+	rec := s.do(t, "GET", "/api/tasks/stats", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	stats := decode[TaskStats](t, rec)
+
+	switch {
+	case !reflect.DeepEqual(stats.ByStatus, map[string]int64{"DONE": 1, "TODO": 2}):
+		t.Errorf("by status = %v", stats.ByStatus)
+	case !reflect.DeepEqual(stats.ByPriority, map[string]int64{"LOW": 1, "MEDIUM": 1, "HIGH": 1}):
+		t.Errorf("by priority = %v", stats.ByPriority)
+	case stats.Overdue != 1:
+		t.Errorf("overdue = %d", stats.Overdue)
+	case stats.Completed != 1 || stats.AverageCycleSeconds == nil || stats.AverageActiveSeconds != nil:
+		t.Errorf("completed %d, cycle %v, active %v", stats.Completed, stats.AverageCycleSeconds, stats.AverageActiveSeconds)
+	case len(stats.CompletedPerDay) != 30 || stats.CompletedPerDay[29].Count != 1 || stats.To != today().Format(dateLayout):
+		t.Errorf("completed per day = %+v", stats.CompletedPerDay)
+	case stats.CurrentStreak != 1 || stats.LongestStreak != 1:
+		t.Errorf("streaks = %d, %d", stats.CurrentStreak, stats.LongestStreak)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/stats", bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if stats := decode[TaskStats](t, rec); stats.Completed != 0 || len(stats.ByStatus) != 0 {
+		t.Errorf("bob's stats = %+v", stats)
+	}
+
+	for _, query := range []string{"to=tomorrow", "from=2026-10-10&to=2026-10-01", "from=2020-01-01&to=2026-01-01"} {
+		t.Run(query, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", "/api/tasks/stats?"+query, ana.String(), nil), http.StatusBadRequest)
+		})
+	}
+}
+
+// upload sends a multipart form with the given parts, as written by write.
+func (s *testServer) upload(t *testing.T, user string, taskID string, write func(*multipart.Writer)) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	write(form)
+	if err := form.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/tasks/attachments/create/"+taskID, &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	req.Header.Set("X-User-ID", user)
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+func filePart(name, contentType string, content []byte) func(*multipart.Writer) {
+	return func(form *multipart.Writer) {
+		header := textproto.MIMEHeader{}
+		header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="file"; filename="%s"`, name))
+		header.Set("Content-Type", contentType)
+		part, err := form.CreatePart(header)
+		if err != nil {
+			panic(err)
+		}
+		part.Write(content)
+	}
+}
+
+// storedBlobs counts the files in the blob store.
+func (s *testServer) storedBlobs(t *testing.T) int {
+	t.Helper()
+
+	n := 0
+	err := filepath.WalkDir(s.blobDir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.Type().IsRegular() {
+			n++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestIntegrationAttachments(t *testing.T) {
+	s := newIntegrationServer(t)
+	task := s.createTask(t, ana, TaskRequest{Title: "With files"}).TaskID.String()
+	notes := []byte("Agenda\n- budget\n- hiring\n")
+
+	rec := s.upload(t, ana.String(), task, filePart("notes.txt", "text/plain", notes))
+	expectStatus(t, rec, http.StatusCreated)
+	attachment := decode[Attachment](t, rec)
+	if attachment.FileName != "notes.txt" || attachment.ContentType != "text/plain" || attachment.Size != int64(len(notes)) {
+		t.Errorf("attachment = %+v", attachment)
+	}
+	id := attachment.AttachmentID.String()
+
+	rec = s.do(t, "GET", "/api/tasks/attachments/"+task, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]Attachment](t, rec); len(list) != 1 || list[0].AttachmentID != attachment.AttachmentID {
+		t.Errorf("attachments = %+v", list)
+	}
+
+	rec = s.do(t, "GET", "/api/tasks/attachments/download/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if !bytes.Equal(rec.Body.Bytes(), notes) || rec.Header().Get("Content-Type") != "text/plain" ||
+		!strings.Contains(rec.Header().Get("Content-Disposition"), "notes.txt") {
+		t.Errorf("download = %q, headers %v", rec.Body.String(), rec.Header())
+	}
+
+	// Bob can neither see nor touch ana's attachments
+	rec = s.do(t, "GET", "/api/tasks/attachments/"+task, bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]Attachment](t, rec); len(list) != 0 {
+		t.Errorf("bob sees %d attachments", len(list))
+	}
+	expectStatus(t, s.do(t, "GET", "/api/tasks/attachments/download/"+id, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/attachments/delete/"+id, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.upload(t, bob.String(), task, filePart("mine.txt", "text/plain", notes)), http.StatusNotFound)
+
+	invalid := []struct {
+		name   string
+		write  func(*multipart.Writer)
+		status int
+	}{
+		{"executable", filePart("setup.exe", "application/x-msdownload", []byte("MZ\x90\x00\x03\x00\x00\x00\x04\x00")), http.StatusUnsupportedMediaType},
+		{"no file field", func(form *multipart.Writer) { form.WriteField("note", "hello") }, http.StatusBadRequest},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.upload(t, ana.String(), task, tt.write), tt.status)
+		})
+	}
+	expectStatus(t, s.do(t, "POST", "/api/tasks/attachments/create/"+task, ana.String(), "not a form"), http.StatusBadRequest)
+
+	if n := s.storedBlobs(t); n != 1 {
+		t.Errorf("%d blobs stored, want 1", n)
+	}
+
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/attachments/delete/"+id, ana.String(), nil), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/attachments/delete/"+id, ana.String(), nil), http.StatusNotFound)
+	if n := s.storedBlobs(t); n != 0 {
+		t.Errorf("%d blobs left after deleting the attachment", n)
+	}
+
+	// Deleting the task takes its attachments along
+	expectStatus(t, s.upload(t, ana.String(), task, filePart("a.txt", "text/plain", notes)), http.StatusCreated)
+	expectStatus(t, s.upload(t, ana.String(), task, filePart("b.csv", "text/csv", []byte("a,b\n1,2\n"))), http.StatusCreated)
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/delete/"+task, ana.String(), nil), http.StatusOK)
+
+	var rows int64
+	if err := db.Model(&Attachment{}).Count(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if n := s.storedBlobs(t); rows != 0 || n != 0 {
+		t.Errorf("%d attachment rows and %d blobs left after deleting the task", rows, n)
+	}
+}
+
+func TestIntegrationComments(t *testing.T) {
+	s := newIntegrationServer(t)
+	task := s.createTask(t, ana, TaskRequest{Title: "Discussed"}).TaskID.String()
+
+	notifications := func(user uuid.UUID, query string) []Notification {
+		t.Helper()
+		rec := s.do(t, "GET", "/api/tasks/notifications"+query, user.String(), nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[[]Notification](t, rec)
+	}
+	thread := func() []*Comment {
+		t.Helper()
+		rec := s.do(t, "GET", "/api/tasks/comments/"+task, ana.String(), nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[[]*Comment](t, rec)
+	}
+
+	rec := s.do(t, "POST", "/api/tasks/comments/create/"+task, ana.String(), CommentRequest{Body: "Thoughts, @BOB@example.com? cc @nobody@example.com"})
+	expectStatus(t, rec, http.StatusCreated)
+	root := decode[Comment](t, rec)
+	// Mentions are echoed as written, whether or not they match an account
+	if !reflect.DeepEqual(root.Mentions, []string{"bob@example.com", "nobody@example.com"}) {
+		t.Errorf("mentions = %v", root.Mentions)
+	}
+
+	rec = s.do(t, "POST", "/api/tasks/comments/create/"+task, ana.String(), CommentRequest{Body: "Answering myself", ParentID: ptr(root.CommentID.String())})
+	expectStatus(t, rec, http.StatusCreated)
+	reply := decode[Comment](t, rec)
+	if reply.ParentID == nil || *reply.ParentID != root.CommentID {
+		t.Errorf("reply parent = %v", reply.ParentID)
+	}
+
+	invalid := map[string]CommentRequest{
+		"blank body":       {Body: "   "},
+		"body too long":    {Body: strings.Repeat("a", maxCommentLength+1)},
+		"too many letters": {Body: strings.Repeat("é", maxCommentLength+1)},
+		"malformed parent": {Body: "Reply", ParentID: ptr("first")},
+		"unknown parent":   {Body: "Reply", ParentID: ptr(uuid.NewString())},
+	}
+	for name, req := range invalid {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "POST", "/api/tasks/comments/create/"+task, ana.String(), req), http.StatusBadRequest)
+		})
+	}
+
+	comments := thread()
+	if len(comments) != 1 || comments[0].CommentID != root.CommentID || len(comments[0].Replies) != 1 || comments[0].Replies[0].CommentID != reply.CommentID {
+		t.Fatalf("threads = %+v", comments)
+	}
+
+	// Bob is notified, though he still can't read Ana's task
+	got := notifications(bob, "?unread=true")
+	if len(got) != 1 || got[0].Kind != "MENTION" || *got[0].CommentID != root.CommentID || *got[0].ActorID != ana || got[0].ReadAt != nil {
+		t.Fatalf("bob's notifications = %+v", got)
+	}
+	expectStatus(t, s.do(t, "GET", "/api/tasks/comments/"+task, bob.String(), nil), http.StatusNotFound)
+	if got := notifications(ana, ""); len(got) != 0 {
+		t.Errorf("ana has %d notifications about her own comments", len(got))
+	}
+
+	// Only the recipient may mark a notification read, and marking it again keeps the time
+	read := "/api/tasks/notifications/read/" + got[0].NotificationID.String()
+	expectStatus(t, s.do(t, "PUT", read, ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/notifications/read/first", bob.String(), nil), http.StatusNotFound)
+	rec = s.do(t, "PUT", read, bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	first := decode[Notification](t, rec)
+	if first.ReadAt == nil || first.NotificationID != got[0].NotificationID {
+		t.Fatalf("read notification = %+v", first)
+	}
+	rec = s.do(t, "PUT", read, bob.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if again := decode[Notification](t, rec); again.ReadAt == nil || !again.ReadAt.Equal(*first.ReadAt) {
+		t.Errorf("read again at %v, first read at %v", again.ReadAt, first.ReadAt)
+	}
+	if got := notifications(bob, "?unread=true"); len(got) != 0 {
+		t.Errorf("bob's unread notifications = %+v", got)
+	}
+
+	// Only the author may edit, and re-mentioning doesn't notify again
+	path := "/api/tasks/comments/update/" + root.CommentID.String()
+	expectStatus(t, s.do(t, "PUT", path, bob.String(), CommentRequest{Body: "Hijacked"}), http.StatusNotFound)
+	expectStatus(t, s.do(t, "PUT", path, ana.String(), CommentRequest{Body: ""}), http.StatusBadRequest)
+	rec = s.do(t, "PUT", path, ana.String(), CommentRequest{Body: "Edited, @bob@example.com"})
+	expectStatus(t, rec, http.StatusOK)
+	if edited := decode[Comment](t, rec); edited.Body != "Edited, @bob@example.com" || !reflect.DeepEqual(edited.Mentions, []string{"bob@example.com"}) {
+		t.Errorf("edited comment = %+v", edited)
+	}
+	if got := notifications(bob, ""); len(got) != 1 {
+		t.Errorf("bob has %d notifications after the edit, want 1", len(got))
+	}
+
+	// A mention in a new comment is notified, and marking all read clears it
+	rec = s.do(t, "POST", "/api/tasks/comments/create/"+task, ana.String(), CommentRequest{Body: "Ping @bob@example.com"})
+	expectStatus(t, rec, http.StatusCreated)
+	if got := notifications(bob, "?unread=true"); len(got) != 1 {
+		t.Fatalf("bob has %d unread notifications, want 1", len(got))
+	}
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/notifications/read", bob.String(), nil), http.StatusOK)
+	if got := notifications(bob, "?unread=true"); len(got) != 0 {
+		t.Errorf("bob's unread notifications after reading all = %+v", got)
+	}
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/comments/delete/"+decode[Comment](t, rec).CommentID.String(), ana.String(), nil), http.StatusOK)
+
+	// A comment with replies is blanked rather than removed
+	path = "/api/tasks/comments/delete/" + root.CommentID.String()
+	expectStatus(t, s.do(t, "DELETE", path, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusOK)
+	comments = thread()
+	if len(comments) != 1 || comments[0].Body != "" || comments[0].DeletedAt == nil || len(comments[0].Replies) != 1 {
+		t.Errorf("threads after deleting the root = %+v", comments)
+	}
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/comments/update/"+root.CommentID.String(), ana.String(), CommentRequest{Body: "Back"}), http.StatusNotFound)
+
+	expectStatus(t, s.do(t, "DELETE", "/api/tasks/comments/delete/"+reply.CommentID.String(), ana.String(), nil), http.StatusOK)
+	if comments = thread(); len(comments) != 1 || len(comments[0].Replies) != 0 {
+		t.Errorf("threads after deleting the reply = %+v", comments)
+	}
+}
+
+func TestIntegrationTemplates(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "POST", "/api/tasks/templates/create", ana.String(), TemplateRequest{
+		Name:            "Weekly review",
+		Title:           "Review {{date}}",
+		Description:     "Numbers for {{client}}",
+		Priority:        "HIGH",
+		DeadlineOffset:  "+3d",
+		EstimateMinutes: ptr(45),
+	})
+	expectStatus(t, rec, http.StatusCreated)
+	template := decode[TaskTemplate](t, rec)
+	id := template.TemplateID.String()
+
+	invalid := []struct {
+		name   string
+		body   any
+		status int
+	}{
+		{"duplicate name", TemplateRequest{Name: "Weekly review", Title: "Again", Priority: "LOW"}, http.StatusConflict},
+		{"blank name", TemplateRequest{Name: " ", Title: "Title", Priority: "LOW"}, http.StatusBadRequest},
+		{"unknown priority", TemplateRequest{Name: "Urgent", Title: "Title", Priority: "URGENT"}, http.StatusBadRequest},
+		{"invalid offset", TemplateRequest{Name: "Soon", Title: "Title", Priority: "LOW", DeadlineOffset: "3 days"}, http.StatusBadRequest},
+		{"negative estimate", TemplateRequest{Name: "Free", Title: "Title", Priority: "LOW", EstimatePoints: ptr(-1)}, http.StatusBadRequest},
+		{"malformed body", "{", http.StatusBadRequest},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "POST", "/api/tasks/templates/create", ana.String(), tt.body), tt.status)
+		})
+	}
+
+	// Names only have to be unique per user
+	expectStatus(t, s.do(t, "POST", "/api/tasks/templates/create", bob.String(), TemplateRequest{Name: "Weekly review", Title: "Bob's review", Priority: "LOW"}), http.StatusCreated)
+
+	rec = s.do(t, "GET", "/api/tasks/templates", ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if list := decode[[]TaskTemplate](t, rec); len(list) != 1 || list[0].TemplateID != template.TemplateID {
+		t.Errorf("templates = %+v", list)
+	}
+
+	from := "/api/tasks/from-template/" + id
+	expectStatus(t, s.do(t, "POST", from, ana.String(), nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, "POST", from, ana.String(), "{"), http.StatusBadRequest)
+	expectStatus(t, s.do(t, "POST", from, bob.String(), nil), http.StatusNotFound)
+
+	created := testutil.ToFloat64(tasksCreated.WithLabelValues("template"))
+	rec = s.do(t, "POST", from, ana.String(), FromTemplateRequest{Variables: map[string]string{"client": "ACME"}})
+	expectStatus(t, rec, http.StatusCreated)
+	task := decode[Task](t, rec)
+	if got := testutil.ToFloat64(tasksCreated.WithLabelValues("template")) - created; got != 1 {
+		t.Errorf("%v tasks created from templates, want 1", got)
+	}
+	day := today()
+	switch {
+	case task.Title != "Review "+day.Format(dateLayout) || task.Description != "Numbers for ACME":
+		t.Errorf("rendered %q / %q", task.Title, task.Description)
+	case task.Status != "TODO" || task.Priority != "HIGH" || task.EstimateMinutes == nil || *task.EstimateMinutes != 45:
+		t.Errorf("task = %+v", task)
+	case task.Deadline == nil || !task.Deadline.Equal(day.AddDate(0, 0, 3)) || !task.AllDay:
+		t.Errorf("deadline = %v, all day %v", task.Deadline, task.AllDay)
+	}
+	expectStatus(t, s.do(t, "GET", "/api/tasks/read/"+task.TaskID.String(), ana.String(), nil), http.StatusOK)
+
+	// Save an existing task as a template, keeping its deadline five days out
+	source := s.createTask(t, ana, TaskRequest{
+		Title:          "Onboard new hire",
+		Description:    "Laptop, accounts, buddy",
+		Priority:       "HIGH",
+		Deadline:       ptr(today().AddDate(0, 0, 5).Format(dateLayout)),
+		AllDay:         ptr(true),
+		EstimatePoints: ptr(3),
+	}).TaskID.String()
+	fromTask := "/api/tasks/templates/from-task/" + source
+	expectStatus(t, s.do(t, "POST", fromTask, bob.String(), TemplateFromTaskRequest{Name: "Onboarding"}), http.StatusNotFound)
+	expectStatus(t, s.do(t, "POST", fromTask, ana.String(), TemplateFromTaskRequest{Name: "Weekly review"}), http.StatusConflict)
+	expectStatus(t, s.do(t, "POST", fromTask, ana.String(), TemplateFromTaskRequest{Name: "Later", DeadlineOffset: ptr("soon")}), http.StatusBadRequest)
+
+	rec = s.do(t, "POST", fromTask, ana.String(), TemplateFromTaskRequest{Name: "Onboarding"})
+	expectStatus(t, rec, http.StatusCreated)
+	saved := decode[TaskTemplate](t, rec)
+	if saved.Title != "Onboard new hire" || saved.Description != "Laptop, accounts, buddy" || saved.Priority != "HIGH" ||
+		saved.DeadlineOffset != "+5d" || saved.EstimatePoints == nil || *saved.EstimatePoints != 3 {
+		t.Errorf("template from task = %+v", saved)
+	}
+
+	// Instantiating needs no body, chunked or not
+	req := httptest.NewRequest("POST", "/api/tasks/from-template/"+saved.TemplateID.String(), strings.NewReader(""))
+	req.Header.Set("X-User-ID", ana.String())
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	rec = httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	expectStatus(t, rec, http.StatusCreated)
+	if copied := decode[Task](t, rec); copied.Title != "Onboard new hire" || copied.Deadline == nil || !copied.Deadline.Equal(today().AddDate(0, 0, 5)) {
+		t.Errorf("task from saved template = %+v", copied)
+	}
+
+	path := "/api/tasks/templates/delete/" + id
+	expectStatus(t, s.do(t, "DELETE", path, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusOK)
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "POST", from, ana.String(), nil), http.StatusNotFound)
+}
+
+// failingMailer fails every delivery.
+type failingMailer struct{}
+
+func (failingMailer) Send(context.Context, Message) error {
+	return errors.New("mail server unavailable")
+}
+
+func TestIntegrationDigests(t *testing.T) {
+	s := newIntegrationServer(t)
+	dir := t.TempDir()
+	files, err := NewFileMailer(dir, "TaskNest <no-reply@tasknest.local>")
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := mailer
+	t.Cleanup(func() { mailer = saved })
+
+	ctx := context.Background()
+	now := time.Date(2026, 10, 15, 8, 30, 0, 0, time.UTC)
+	for _, user := range []uuid.UUID{ana, bob} {
+		setUserColumns(t, user, map[string]any{"digest_frequency": "DAILY", "digest_hour": 8, "date_format": "DD/MM/YYYY"})
+	}
+	s.createTask(t, ana, TaskRequest{Title: "File taxes", Priority: "HIGH", Deadline: ptr("2026-10-10")})
+
+	sentAt := func(user uuid.UUID) *time.Time {
+		t.Helper()
+		var stored User
+		if err := db.First(&stored, "user_id = ?", user).Error; err != nil {
+			t.Fatal(err)
+		}
+		return stored.DigestSentAt
+	}
+	mails := func() []string {
+		t.Helper()
+		var bodies []string
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, entry := range entries {
+			data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			bodies = append(bodies, string(data))
+		}
+		return bodies
+	}
+
+	// A failed delivery releases the claim so the next run retries. Bob has
+	// nothing to report, his empty digest is claimed but not sent.
+	mailer = failingMailer{}
+	if n, err := sendDueDigests(ctx, now); n != 0 || err != nil {
+		t.Fatalf("sent %d, %v with a failing mailer", n, err)
+	}
+	if at := sentAt(ana); at != nil {
+		t.Errorf("ana's digest still claimed at %v after failing", at)
+	}
+	if at := sentAt(bob); at == nil || !at.Equal(now) {
+		t.Errorf("bob's digest claimed at %v, want %v", at, now)
+	}
+
+	// Replicas running at the same time claim each digest once
+	mailer = files
+	var wg sync.WaitGroup
+	counts := make([]int, 4)
+	for i := range counts {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			n, err := sendDueDigests(ctx, now)
+			if err != nil {
+				t.Error(err)
+			}
+			counts[i] = n
+		}()
+	}
+	wg.Wait()
+	if total := counts[0] + counts[1] + counts[2] + counts[3]; total != 1 {
+		t.Errorf("sent %d digests across replicas, want 1", total)
+	}
+	sent := mails()
+	if len(sent) != 1 || !strings.Contains(sent[0], "To: ana@example.com") ||
+		!strings.Contains(sent[0], "Your daily TaskNest digest for 15/10/2026") || !strings.Contains(sent[0], "File taxes [HIGH] (10/10/2026)") {
+		t.Fatalf("mail = %v", sent)
+	}
+	if at := sentAt(ana); at == nil || !at.Equal(now) {
+		t.Errorf("ana's digest claimed at %v, want %v", at, now)
+	}
+
+	// Within the same slot nothing goes out again, the next day it does
+	if n, err := sendDueDigests(ctx, now.Add(time.Hour)); n != 0 || err != nil {
+		t.Errorf("sent %d, %v again in the same slot", n, err)
+	}
+	if n, err := sendDueDigests(ctx, now.AddDate(0, 0, 1)); n != 1 || err != nil {
+		t.Errorf("sent %d, %v the next day, want 1", n, err)
+	}
+
+	// A slot missed by more than the grace period is skipped
+	late := now.AddDate(0, 0, 2).Add(digestGrace)
+	if n, err := sendDueDigests(ctx, late); n != 0 || err != nil {
+		t.Errorf("sent %d, %v for a stale slot", n, err)
+	}
+	if len(mails()) != 2 {
+		t.Errorf("%d mails written, want 2", len(mails()))
+	}
+}
+
+func TestIntegrationGraphQL(t *testing.T) {
+	s := newIntegrationServer(t)
+	task := s.createTask(t, ana, TaskRequest{Title: "Plan sprint"}).TaskID.String()
+	theirs := s.createTask(t, bob, TaskRequest{Title: "Bob's task"}).TaskID.String()
+	expectStatus(t, s.do(t, "POST", "/api/tasks/comments/create/"+task, ana.String(), CommentRequest{Body: "Kickoff on Monday"}), http.StatusCreated)
+
+	rec := s.do(t, "POST", "/api/tasks/graphql", ana.String(), GraphQLRequest{
+		Query: `query($theirs: ID!) {
+			viewer { email }
+			tasks(first: 10) { totalCount edges { node { id title owner { email } comments { body author { email } } } } }
+			theirs: task(id: $theirs) { title }
+		}`,
+		Variables: map[string]any{"theirs": theirs},
+	})
+	expectStatus(t, rec, http.StatusOK)
+
+	var resp struct {
+		Data struct {
+			Viewer struct{ Email string }
+			Tasks  struct {
+				TotalCount int
+				Edges      []struct {
+					Node struct {
+						ID       string
+						Title    string
+						Owner    struct{ Email string }
+						Comments []struct {
+							Body   string
+							Author struct{ Email string }
+						}
+					}
+				}
+			}
+			Theirs *struct{ Title string }
+		}
+		Errors []GraphQLError
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Errors) > 0 {
+		t.Fatalf("errors = %+v", resp.Errors)
+	}
+
+	data := resp.Data
+	switch {
+	case data.Viewer.Email != "ana@example.com":
+		t.Errorf("viewer = %+v", data.Viewer)
+	case data.Tasks.TotalCount != 1 || len(data.Tasks.Edges) != 1:
+		t.Errorf("tasks = %+v", data.Tasks)
+	case data.Tasks.Edges[0].Node.ID != task || data.Tasks.Edges[0].Node.Owner.Email != "ana@example.com":
+		t.Errorf("node = %+v", data.Tasks.Edges[0].Node)
+	case len(data.Tasks.Edges[0].Node.Comments) != 1 || data.Tasks.Edges[0].Node.Comments[0].Author.Email != "ana@example.com":
+		t.Errorf("comments = %+v", data.Tasks.Edges[0].Node.Comments)
+	case data.Theirs != nil:
+		t.Errorf("ana can read bob's task: %+v", data.Theirs)
+	}
+}
+
+func TestIntegrationOpenTasksMetric(t *testing.T) {
+	s := newIntegrationServer(t)
+	s.createTask(t, ana, TaskRequest{Title: "One"})
+	s.createTask(t, bob, TaskRequest{Title: "Two"})
+	s.createTask(t, ana, TaskRequest{Title: "Three", Status: "DONE"})
+	archived := s.createTask(t, ana, TaskRequest{Title: "Four"})
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/archive/"+archived.TaskID.String(), ana.String(), nil), http.StatusOK)
+
+	want := `
+# HELP tasknest_tasks Unarchived tasks by status.
+# TYPE tasknest_tasks gauge
+tasknest_tasks{status="DONE"} 1
+tasknest_tasks{status="TODO"} 2
+`
+	if err := testutil.CollectAndCompare(newOpenTasksCollector(db), strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/logging"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(&buf)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines decodes the JSON log lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q isn't JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// TestRequestLogging checks the logs carry the user requireUser let through,
+// never one the caller merely claimed.
+func TestRequestLogging(t *testing.T) {
+	buf := captureLogs(t)
+	key := verifyTokens(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/tasks/read/{id}", requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.RequestLogger(r).Warn("Looking up task")
+		http.Error(w, "Task not found", http.StatusNotFound)
+	})))
+	handler := newHandler(mux, "")
+
+	serve := func(token string) []map[string]any {
+		t.Helper()
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/api/tasks/read/42", nil)
+		req.Header.Set("X-User-ID", ana.String())
+		req.Header.Set(logging.RequestIDHeader, "apigw-4f1c")
+		req.AddCookie(&http.Cookie{Name: "id_token", Value: token})
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return logLines(t, buf)
+	}
+
+	lines := serve(idToken(t, key, ana))
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2", len(lines))
+	}
+	for _, entry := range lines {
+		if entry["request_id"] != "apigw-4f1c" || entry["route"] != "GET /api/tasks/read/{id}" || entry["user_id"] != ana.String() {
+			t.Errorf("log line missing request context: %v", entry)
+		}
+	}
+
+	// Bob claiming to be Ana is turned away, and nobody's ID is logged as the user
+	for _, entry := range serve(idToken(t, key, bob)) {
+		if entry["user_id"] != nil {
+			t.Errorf("unverified user logged: %v", entry)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Message is an email with both a plain-text and an HTML body.
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// Mailer delivers email.
+type Mailer interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// newMailer sends through SMTP_HOST when it is set and otherwise dumps
+// messages as .eml files under MAIL_DIR, which is handy in development and tests.
+func newMailer() (Mailer, error) {
+	from := getEnv("MAIL_FROM", "TaskNest <no-reply@tasknest.local>")
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		return NewSMTPMailer(host, getEnv("SMTP_PORT", "587"), os.Getenv("SMTP_USERNAME"), os.Getenv("SMTP_PASSWORD"), from)
+	}
+	return NewFileMailer(getEnv("MAIL_DIR", "mail"), from)
+}
+
+// composeMessage renders msg as a multipart/alternative MIME message.
+func composeMessage(from string, msg Message, now time.Time) ([]byte, error) {
+	var out bytes.Buffer
+	body := multipart.NewWriter(&out)
+
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: %s\r\n\r\n", mime.FormatMediaType("multipart/alternative", map[string]string{"boundary": body.Boundary()}))
+
+	// Plain text first, mail clients show the last alternative they understand
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := body.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		qp := quotedprintable.NewWriter(w)
+		if _, err := qp.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+		if err := qp.Close(); err != nil {
+			return nil, err
+		}
+	}
+	if err := body.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type SMTPMailer struct {
+	host     string
+	port     string
+	auth     smtp.Auth
+	from     string
+	envelope string
+}
+
+func NewSMTPMailer(host, port, username, password, from string) (*SMTPMailer, error) {
+	addr, err := mail.ParseAddress(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid sender address %q: %w", from, err)
+	}
+
+	m := &SMTPMailer{host: host, port: port, from: from, envelope: addr.Address}
+	if username != "" {
+		m.auth = smtp.PlainAuth("", username, password, host)
+	}
+	return m, nil
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, msg Message) error {
+	data, err := composeMessage(m.from, msg, time.Now())
+	if err != nil {
+		return fmt.Errorf("unable to compose message: %w", err)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(m.host, m.port))
+	if err != nil {
+		return fmt.Errorf("unable to reach SMTP server: %w", err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	client, err := smtp.NewClient(conn, m.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("unable to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if ok, _ := client.Extension("STARTTLS"); ok {
+		if err := client.StartTLS(&tls.Config{ServerName: m.host}); err != nil {
+			return fmt.Errorf("unable to start TLS: %w", err)
+		}
+	}
+	if m.auth != nil {
+		if err := client.Auth(m.auth); err != nil {
+			return fmt.Errorf("unable to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(m.envelope); err != nil {
+		return err
+	}
+	if err := client.Rcpt(msg.To); err != nil {
+		return err
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(data); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// FileMailer writes every message to its own .eml file instead of sending it.
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+func NewFileMailer(dir, from string) (*FileMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("unable to create mail directory: %w", err)
+	}
+	return &FileMailer{dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+	now := time.Now()
+	data, err := composeMessage(m.from, msg, now)
+	if err != nil {
+		return fmt.Errorf("unable to compose message: %w", err)
+	}
+
+	recipient := strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r == os.PathSeparator {
+			return '_'
+		}
+		return r
+	}, msg.To)
+	name := fmt.Sprintf("%d-%s.eml", now.UnixNano(), recipient)
+	return os.WriteFile(filepath.Join(m.dir, name), data, 0o644)
+}
@@ -1,19 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
+	_ "time/tzdata"
 
-	"context"
-
+	"auth"
+	"platform/health"
+	"platform/logging"
+	"platform/metrics"
+	"platform/middleware"
+	"platform/server"
+	"platform/tracing"
 	_ "tasks/docs"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 	"gorm.io/gorm"
 )
@@ -24,7 +37,11 @@ import (
 // @BasePath /api
 
 var (
-	db *gorm.DB
+	db     *gorm.DB
+	blobs  BlobStore
+	mailer Mailer
+	// tokenVerifier is set when VERIFY_TOKENS is, see requireUser and callerID
+	tokenVerifier *auth.Verifier
 )
 
 func getEnv(key, defaultValue string) string {
@@ -35,12 +52,24 @@ func getEnv(key, defaultValue string) string {
 }
 
 func main() {
-	ctx := context.Background()
+	if err := logging.Init(getEnv("LOG_LEVEL", "INFO")); err != nil {
+		logging.Fatal("Invalid logging configuration", "err", err)
+	}
+
+	// ECS sends SIGTERM when it drains the task, Ctrl-C sends SIGINT locally
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(getEnv("AWS_REGION", "us-east-1")),
 	)
 	if err != nil {
-		log.Fatalf("Failed to create AWS config: %v", err)
+		logging.Fatal("Failed to create AWS config", "err", err)
+	}
+
+	shutdownTracing, err := tracing.Init(ctx, "tasks")
+	if err != nil {
+		logging.Fatal("Error initializing tracing", "err", err)
 	}
 
 	ssmClient := ssm.NewFromConfig(cfg)
@@ -48,47 +77,206 @@ func main() {
 	secretsManagerClient := secretsmanager.NewFromConfig(cfg)
 	val, err := getSecretValue(secretsManagerClient, "postgres", ctx)
 	if err != nil {
-		log.Fatal("Can't get credentials:", err)
+		logging.Fatal("Can't get credentials", "err", err)
 	}
 
 	var creds DBCreds
 	if err := json.Unmarshal(val, &creds); err != nil {
-		log.Fatalf("unable to parse secret: %v", err)
+		logging.Fatal("Unable to parse secret", "err", err)
 	}
 
 	rdsEndpoint := getParameter(ssmClient, "rds_endpoint", ctx)
 	dbName := getParameter(ssmClient, "db_name", ctx)
+	frontendURL := getParameter(ssmClient, "frontend_url", ctx)
+
+	verifyTokens, err := strconv.ParseBool(getEnv("VERIFY_TOKENS", "false"))
+	if err != nil {
+		logging.Fatal("Invalid VERIFY_TOKENS", "err", err)
+	}
+	if verifyTokens {
+		tokenVerifier = auth.NewVerifier(getEnv("AWS_REGION", "us-east-1"),
+			getParameter(ssmClient, "userpool_id", ctx),
+			getParameter(ssmClient, "cognito_client_id", ctx),
+			tracing.Client)
+		slog.Info("Verifying Cognito tokens against X-User-ID")
+	}
 
-	db, err = InitDB(rdsEndpoint, creds.Username, creds.Password, dbName)
+	dsn := postgresDSN(rdsEndpoint, creds.Username, creds.Password, dbName)
+	db, err = InitDB(dsn)
 	if err != nil {
-		log.Fatalf("Error initializing database: %v\n", err)
+		logging.Fatal("Error initializing database", "err", err)
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("failed to get sql.DB from gorm.DB: %v", err)
+		logging.Fatal("Failed to get sql.DB from gorm.DB", "err", err)
 	}
 
 	if err := sqlDB.Ping(); err != nil {
-		log.Fatalf("database connection test failed: %v", err)
+		logging.Fatal("Database connection test failed", "err", err)
+	}
+
+	slog.Info("Database initialized successfully")
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, dbName), newOpenTasksCollector(db))
+
+	blobs, err = newBlobStore(cfg)
+	if err != nil {
+		logging.Fatal("Error initializing blob store", "err", err)
+	}
+
+	archiveInterval, err := time.ParseDuration(getEnv("AUTO_ARCHIVE_INTERVAL", "1h"))
+	if err != nil {
+		logging.Fatal("Invalid AUTO_ARCHIVE_INTERVAL", "err", err)
+	}
+	go runAutoArchiver(ctx, archiveInterval)
+
+	mailer, err = newMailer()
+	if err != nil {
+		logging.Fatal("Error initializing mailer", "err", err)
+	}
+
+	digestInterval, err := time.ParseDuration(getEnv("DIGEST_INTERVAL", "5m"))
+	if err != nil {
+		logging.Fatal("Invalid DIGEST_INTERVAL", "err", err)
 	}
+	go runDigestScheduler(ctx, digestInterval)
 
-	log.Println("Database initialized successfully")
-	defer sqlDB.Close()
+	go runTaskListener(ctx, dsn)
 
-	http.HandleFunc("GET /api/tasks/swagger/", httpSwagger.WrapHandler)
-	http.HandleFunc("GET /api/tasks/{$}", handleHealthCheck)
-	http.HandleFunc("POST /api/tasks/create", handleCreateTask)
-	http.HandleFunc("PUT /api/tasks/update/{id}", handleUpdateTask)
-	http.HandleFunc("DELETE /api/tasks/delete/{id}", handleDeleteTask)
-	http.HandleFunc("GET /api/tasks/read", handleGetTasks)
+	serverCfg, err := server.LoadConfig()
+	if err != nil {
+		logging.Fatal("Invalid server configuration", "err", err)
+	}
+
+	taskService := NewTaskService(NewGormTaskRepository(db), blobs)
+
+	// gRPC callers don't come through the API Gateway, so without a verifier
+	// for their tokens the server isn't started at all
+	grpcStopped := make(chan struct{})
+	if tokenVerifier != nil {
+		grpcPort := getEnv("GRPC_PORT", "9090")
+		grpcListener, err := net.Listen("tcp", ":"+grpcPort)
+		if err != nil {
+			logging.Fatal("Failed to listen on gRPC port", "err", err)
+		}
+		grpcServer := newGRPCServer(taskService)
+		go func() {
+			slog.Info("Starting gRPC server", "port", grpcPort)
+			if err := grpcServer.Serve(grpcListener); err != nil {
+				logging.Fatal("GRPC server stopped", "err", err)
+			}
+		}()
+		go func() {
+			<-ctx.Done()
+			stopGRPCServer(grpcServer, serverCfg.ShutdownTimeout)
+			close(grpcStopped)
+		}()
+	} else {
+		slog.Warn("Not serving gRPC, it needs VERIFY_TOKENS")
+		close(grpcStopped)
+	}
+
+	metricsPort := getEnv("METRICS_PORT", "9100")
+	metricsListener, err := net.Listen("tcp", ":"+metricsPort)
+	if err != nil {
+		logging.Fatal("Failed to listen on metrics port", "err", err)
+	}
+	go func() {
+		slog.Info("Serving metrics", "port", metricsPort)
+		if err := server.Serve(ctx, server.New(metrics.NewMux(), serverCfg), metricsListener, serverCfg.ShutdownTimeout); err != nil {
+			slog.Error("Metrics server stopped", "err", err)
+		}
+	}()
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	fmt.Printf("Starting server on port %s\n", port)
-	if err = http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Failed to create AWS session: %v", err)
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		logging.Fatal("Failed to listen", "port", port, "err", err)
+	}
+	slog.Info("Starting server", "port", port)
+	handler := newHandler(newMux(taskService), frontendURL)
+	err = server.Serve(ctx, server.New(handler, serverCfg), listener, serverCfg.ShutdownTimeout)
+	if err != nil {
+		slog.Error("HTTP server stopped", "err", err)
+	}
+
+	// Stop the background jobs and wait for gRPC to drain before the pool goes away
+	stop()
+	<-grpcStopped
+
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Couldn't close the database", "err", err)
+	}
+
+	// Flush the spans still buffered, on a fresh context since ctx is done
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Couldn't flush traces", "err", err)
 	}
+	slog.Info("Server stopped")
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// newMux routes the REST and GraphQL endpoints, with the task endpoints served by taskService.
+func newMux(taskService *TaskService) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks/swagger/", httpSwagger.WrapHandler)
+	mux.HandleFunc("GET /api/tasks/{$}", handleHealthCheck)
+	mux.HandleFunc("GET /api/tasks/live", handleLiveness)
+	checks := []health.Check{health.Database(db), health.Migrations(db)}
+	if tokenVerifier != nil {
+		checks = append(checks, jwksCheck())
+	}
+	mux.HandleFunc("GET /api/tasks/ready", newReadinessHandler(checks...))
+	handlers := &taskHandlers{tasks: taskService}
+	handlers.register(mux)
+	mux.Handle("POST /api/tasks/graphql", forUser(newGraphQLHandler(taskService, taskService.repo)))
+	mux.Handle("POST /api/tasks/timer/start/{id}", forUser(handleStartTimer))
+	mux.Handle("POST /api/tasks/timer/stop", forUser(handleStopTimer))
+	mux.Handle("POST /api/tasks/time/create/{id}", forUser(handleCreateTimeEntry))
+	mux.Handle("GET /api/tasks/time/report", forUser(handleTimeReport))
+	mux.Handle("GET /api/tasks/capacity", forUser(handleGetCapacity))
+	mux.Handle("GET /api/tasks/stats", forUser(handleGetStats))
+	mux.Handle("POST /api/tasks/attachments/create/{id}", requireUser(http.HandlerFunc(handleUploadAttachment)))
+	mux.Handle("GET /api/tasks/attachments/{id}", forUser(handleListAttachments))
+	mux.Handle("GET /api/tasks/attachments/download/{id}", forUser(handleDownloadAttachment))
+	mux.Handle("DELETE /api/tasks/attachments/delete/{id}", forUser(handleDeleteAttachment))
+	mux.Handle("GET /api/tasks/comments/{id}", forUser(handleListComments))
+	mux.Handle("POST /api/tasks/comments/create/{id}", forUser(handleCreateComment))
+	mux.Handle("PUT /api/tasks/comments/update/{id}", forUser(handleUpdateComment))
+	mux.Handle("DELETE /api/tasks/comments/delete/{id}", forUser(handleDeleteComment))
+	mux.Handle("GET /api/tasks/notifications", forUser(handleListNotifications))
+	mux.Handle("PUT /api/tasks/notifications/read/{id}", forUser(handleReadNotification))
+	mux.Handle("PUT /api/tasks/notifications/read", forUser(handleReadAllNotifications))
+	mux.Handle("GET /api/tasks/templates", forUser(handleListTemplates))
+	mux.Handle("POST /api/tasks/templates/create", forUser(handleCreateTemplate))
+	mux.Handle("POST /api/tasks/templates/from-task/{id}", forUser(handleCreateTemplateFromTask))
+	mux.Handle("DELETE /api/tasks/templates/delete/{id}", forUser(handleDeleteTemplate))
+	mux.Handle("POST /api/tasks/from-template/{id}", forUser(handlers.handleCreateFromTemplate))
+
+	return mux
+}
+
+// register routes the task endpoints to h.
+func (h *taskHandlers) register(mux *http.ServeMux) {
+	mux.Handle("POST /api/tasks/create", forUser(h.handleCreateTask))
+	mux.Handle("POST /api/tasks/quick", forUser(h.handleQuickAddTask))
+	mux.Handle("PUT /api/tasks/update/{id}", forUser(h.handleUpdateTask))
+	mux.Handle("DELETE /api/tasks/delete/{id}", forUser(h.handleDeleteTask))
+	mux.Handle("GET /api/tasks/read", forUser(h.handleGetTasks))
+	mux.Handle("GET /api/tasks/read/{id}", forUser(h.handleGetTask))
+	mux.Handle("PUT /api/tasks/archive/{id}", forUser(h.handleArchiveTask))
+	mux.Handle("PUT /api/tasks/unarchive/{id}", forUser(h.handleUnarchiveTask))
+}
+
+// forUser serves a route on behalf of the user the API Gateway
+// authenticated. Uploads set their own, larger body limit.
+func forUser(h http.HandlerFunc) http.Handler {
+	return middleware.Chain(h, requireUser, middleware.LimitBody(maxBodySize))
 }
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryTaskRepository is a TaskRepository kept in memory, for tests and for
+// running the service without a database. It answers queries the way the
+// Postgres one does, including ordering and the due filters.
+type MemoryTaskRepository struct {
+	mu          sync.Mutex
+	tasks       map[uuid.UUID]Task
+	prefs       map[uuid.UUID]UserPrefs
+	tracked     map[uuid.UUID]int64
+	users       map[uuid.UUID]User
+	comments    []Comment
+	attachments []Attachment
+	timeEntries []TimeEntry
+}
+
+func NewMemoryTaskRepository() *MemoryTaskRepository {
+	return &MemoryTaskRepository{
+		tasks:   map[uuid.UUID]Task{},
+		prefs:   map[uuid.UUID]UserPrefs{},
+		tracked: map[uuid.UUID]int64{},
+		users:   map[uuid.UUID]User{},
+	}
+}
+
+// SetUserPrefs sets the preferences UserPrefs returns for the user.
+func (r *MemoryTaskRepository) SetUserPrefs(userID uuid.UUID, prefs UserPrefs) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.prefs[userID] = prefs
+}
+
+// SetTrackedSeconds sets the time TrackedSeconds reports for the task.
+func (r *MemoryTaskRepository) SetTrackedSeconds(taskID uuid.UUID, seconds int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.tracked[taskID] = seconds
+}
+
+// AddUser stores a user for Users to find.
+func (r *MemoryTaskRepository) AddUser(user User) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.users[user.UserID] = user
+}
+
+// AddComment stores a comment on a task. Comments are expected in the order
+// they were written.
+func (r *MemoryTaskRepository) AddComment(comment Comment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.comments = append(r.comments, comment)
+}
+
+// AddAttachment records an attachment belonging to a task, whose blob is
+// stored under its storage key. Attachments are expected in upload order.
+func (r *MemoryTaskRepository) AddAttachment(attachment Attachment) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.attachments = append(r.attachments, attachment)
+}
+
+// AddTimeEntry stores time logged against a task.
+func (r *MemoryTaskRepository) AddTimeEntry(entry TimeEntry) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.timeEntries = append(r.timeEntries, entry)
+}
+
+// copyTask keeps callers from sharing the stored labels.
+func copyTask(task Task) Task {
+	task.Labels = slices.Clone(task.Labels)
+	return task
+}
+
+// Postgres sorts enums in declaration order.
+var (
+	statusOrder   = map[string]int{"TODO": 0, "IN_PROGRESS": 1, "DONE": 2}
+	priorityOrder = map[string]int{"LOW": 0, "MEDIUM": 1, "HIGH": 2}
+)
+
+// compareBy compares two tasks on a sort column, with Postgres' default of
+// sorting missing deadlines after all others.
+func compareBy(column string, a, b Task) int {
+	switch column {
+	case "creation_date":
+		return a.CreationDate.Compare(b.CreationDate)
+	case "deadline":
+		switch {
+		case a.Deadline == nil && b.Deadline == nil:
+			return 0
+		case a.Deadline == nil:
+			return 1
+		case b.Deadline == nil:
+			return -1
+		}
+		return a.Deadline.Compare(*b.Deadline)
+	case "priority":
+		return priorityOrder[a.Priority] - priorityOrder[b.Priority]
+	case "status":
+		return statusOrder[a.Status] - statusOrder[b.Status]
+	}
+	return 0
+}
+
+func (r *MemoryTaskRepository) List(ctx context.Context, userID uuid.UUID, filters Filters, prefs UserPrefs, now time.Time, offset, limit int) ([]Task, int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	label := strings.ToLower(filters.Label)
+	var matches []Task
+	for _, task := range r.tasks {
+		switch {
+		case task.UserID != userID,
+			filters.Status != "" && task.Status != filters.Status,
+			filters.Priority != "" && task.Priority != filters.Priority,
+			label != "" && !slices.Contains(task.Labels, label),
+			(filters.Archive == "" || filters.Archive == "false") && task.ArchivedAt != nil,
+			filters.Archive == "only" && task.ArchivedAt == nil,
+			filters.Due != "" && !matchesDue(task, filters.Due, now, prefs):
+			continue
+		}
+		matches = append(matches, copyTask(task))
+	}
+
+	desc := strings.ToLower(filters.Order) == "desc"
+	slices.SortFunc(matches, func(a, b Task) int {
+		if c := compareBy(filters.Sort, a, b); c != 0 {
+			if desc {
+				return -c
+			}
+			return c
+		}
+		return strings.Compare(a.TaskID.String(), b.TaskID.String())
+	})
+
+	total := int64(len(matches))
+	offset = max(offset, 0)
+	if offset >= len(matches) {
+		return nil, total, nil
+	}
+	matches = matches[offset:]
+	if limit >= 0 && limit < len(matches) {
+		matches = matches[:limit]
+	}
+	return matches, total, nil
+}
+
+func (r *MemoryTaskRepository) Get(ctx context.Context, userID, taskID uuid.UUID) (Task, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok || task.UserID != userID {
+		return Task{}, errTaskNotFound
+	}
+	return copyTask(task), nil
+}
+
+func (r *MemoryTaskRepository) Create(ctx context.Context, task *Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if task.TaskID == uuid.Nil {
+		task.TaskID = uuid.New()
+	}
+	if task.Labels == nil {
+		task.Labels = []string{}
+	}
+	r.tasks[task.TaskID] = copyTask(*task)
+	return nil
+}
+
+func (r *MemoryTaskRepository) Save(ctx context.Context, task *Task) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.tasks[task.TaskID] = copyTask(*task)
+	return nil
+}
+
+func (r *MemoryTaskRepository) SetArchivedAt(ctx context.Context, userID, taskID uuid.UUID, archivedAt *time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok || task.UserID != userID {
+		return errTaskNotFound
+	}
+	task.ArchivedAt = archivedAt
+	r.tasks[taskID] = task
+	return nil
+}
+
+func (r *MemoryTaskRepository) Delete(ctx context.Context, userID, taskID uuid.UUID) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	task, ok := r.tasks[taskID]
+	if !ok || task.UserID != userID {
+		return nil, errTaskNotFound
+	}
+	// Like the foreign keys in Postgres, everything on the task goes with it
+	var storageKeys []string
+	for _, a := range r.attachments {
+		if a.TaskID == taskID {
+			storageKeys = append(storageKeys, a.StorageKey)
+		}
+	}
+	r.attachments = slices.DeleteFunc(r.attachments, func(a Attachment) bool { return a.TaskID == taskID })
+	r.comments = slices.DeleteFunc(r.comments, func(c Comment) bool { return c.TaskID == taskID })
+	r.timeEntries = slices.DeleteFunc(r.timeEntries, func(e TimeEntry) bool { return e.TaskID == taskID })
+	delete(r.tasks, taskID)
+	delete(r.tracked, taskID)
+	return storageKeys, nil
+}
+
+func (r *MemoryTaskRepository) UserPrefs(ctx context.Context, userID uuid.UUID) (UserPrefs, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if prefs, ok := r.prefs[userID]; ok {
+		return prefs, nil
+	}
+	return defaultPrefs, nil
+}
+
+func (r *MemoryTaskRepository) TrackedSeconds(ctx context.Context, taskIDs []uuid.UUID, now time.Time) (map[uuid.UUID]int64, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	totals := make(map[uuid.UUID]int64, len(taskIDs))
+	for _, id := range taskIDs {
+		if seconds, ok := r.tracked[id]; ok {
+			totals[id] = seconds
+		}
+	}
+	return totals, nil
+}
+
+func (r *MemoryTaskRepository) Users(ctx context.Context, userIDs []uuid.UUID) ([]User, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var users []User
+	for _, id := range userIDs {
+		if user, ok := r.users[id]; ok {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+func (r *MemoryTaskRepository) Comments(ctx context.Context, taskIDs []uuid.UUID) ([]*Comment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var comments []*Comment
+	for _, c := range r.comments {
+		if slices.Contains(taskIDs, c.TaskID) {
+			// Fresh copies, the caller threads replies into them
+			c.Replies = nil
+			comments = append(comments, &c)
+		}
+	}
+	return comments, nil
+}
+
+func (r *MemoryTaskRepository) Attachments(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]Attachment, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var attachments []Attachment
+	for _, a := range r.attachments {
+		if a.UserID == userID && slices.Contains(taskIDs, a.TaskID) {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments, nil
+}
+
+func (r *MemoryTaskRepository) TimeEntries(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]TimeEntry, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var entries []TimeEntry
+	for _, e := range r.timeEntries {
+		if e.UserID == userID && slices.Contains(taskIDs, e.TaskID) {
+			entries = append(entries, e)
+		}
+	}
+	slices.SortStableFunc(entries, func(a, b TimeEntry) int { return b.StartedAt.Compare(a.StartedAt) })
+	return entries, nil
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+
+	"platform/health"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+var (
+	tasksCreated = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_tasks_created_total",
+		Help: "Tasks created, by how they were entered: form, quick_add or template.",
+	}, []string{"source"})
+
+	tasksCompleted = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tasknest_tasks_completed_total",
+		Help: "Tasks moved to DONE.",
+	})
+
+	commentsCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tasknest_comments_created_total",
+		Help: "Comments posted on tasks.",
+	})
+
+	attachmentsUploaded = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tasknest_attachments_uploaded_total",
+		Help: "Attachments uploaded to tasks.",
+	})
+
+	tokenRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_token_rejections_total",
+		Help: "Requests turned away by VERIFY_TOKENS, by reason: missing, invalid or mismatch (the token's subject isn't X-User-ID).",
+	}, []string{"reason"})
+)
+
+// openTasksCollector exports how many unarchived tasks there are in each
+// status, counted when Prometheus scrapes.
+type openTasksCollector struct {
+	db   *gorm.DB
+	desc *prometheus.Desc
+}
+
+func newOpenTasksCollector(db *gorm.DB) *openTasksCollector {
+	return &openTasksCollector{
+		db:   db,
+		desc: prometheus.NewDesc("tasknest_tasks", "Unarchived tasks by status.", []string{"status"}, nil),
+	}
+}
+
+func (c *openTasksCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *openTasksCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), health.Timeout)
+	defer cancel()
+
+	var rows []struct {
+		Status string
+		Count  int64
+	}
+	if err := c.db.WithContext(ctx).Model(&Task{}).
+		Select("status, COUNT(*) AS count").
+		Where("archived_at IS NULL").
+		Group("status").
+		Scan(&rows).Error; err != nil {
+		slog.Error("Couldn't count tasks for metrics", "err", err)
+		return
+	}
+	for _, row := range rows {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(row.Count), row.Status)
+	}
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestBusinessCounters(t *testing.T) {
+	s := newTestServer(t)
+	created, quick, completed := testutil.ToFloat64(tasksCreated.WithLabelValues("form")),
+		testutil.ToFloat64(tasksCreated.WithLabelValues("quick_add")), testutil.ToFloat64(tasksCompleted)
+
+	rec := s.do(t, "POST", "/api/tasks/create", ana.String(), TaskRequest{Title: "Write report", Status: "TODO", Priority: "LOW"})
+	expectStatus(t, rec, http.StatusCreated)
+	task := decode[Task](t, rec)
+	expectStatus(t, s.do(t, "POST", "/api/tasks/quick", ana.String(), QuickAddRequest{Text: "Call mum tomorrow"}), http.StatusCreated)
+	expectStatus(t, s.do(t, "POST", "/api/tasks/create", ana.String(), TaskRequest{Title: "Bad", Status: "TODO", Priority: "LOW", Deadline: ptr("soon")}), http.StatusBadRequest)
+
+	done := TaskRequest{Title: "Write report", Status: "DONE", Priority: "LOW"}
+	for range 2 {
+		expectStatus(t, s.do(t, "PUT", "/api/tasks/update/"+task.TaskID.String(), ana.String(), done), http.StatusOK)
+	}
+
+	if got := testutil.ToFloat64(tasksCreated.WithLabelValues("form")) - created; got != 1 {
+		t.Errorf("%v tasks created from the form, want 1", got)
+	}
+	if got := testutil.ToFloat64(tasksCreated.WithLabelValues("quick_add")) - quick; got != 1 {
+		t.Errorf("%v tasks quick-added, want 1", got)
+	}
+	if got := testutil.ToFloat64(tasksCompleted) - completed; got != 1 {
+		t.Errorf("%v tasks completed, want 1", got)
+	}
+}
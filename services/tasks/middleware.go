@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"platform/logging"
+	"platform/metrics"
+	"platform/middleware"
+	"platform/tracing"
+
+	"github.com/google/uuid"
+)
+
+// maxBodySize caps request bodies on routes that don't set their own limit.
+const maxBodySize = 1 << 20
+
+// newHandler wraps the mux in the stack every request goes through.
+// tracing.Handler, logging.LogRequests and metrics.Handler read the route
+// the mux matched off the request they pass down, so nothing between them
+// and the mux may replace the request; route-specific middleware such as
+// requireUser goes on the routes instead.
+func newHandler(mux *http.ServeMux, frontendURL string) http.Handler {
+	return middleware.Chain(mux,
+		logging.WithRequestID,
+		tracing.Handler,
+		logging.LogRequests,
+		metrics.Handler,
+		middleware.RecoverPanics,
+		middleware.CORS(frontendURL),
+	)
+}
+
+// requireUser rejects requests without a valid X-User-ID, which the API
+// Gateway fills in from the authorizer, and hands the parsed ID on to next
+// and to the request's logs. With VERIFY_TOKENS set the caller's token must
+// also be for that user.
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header := r.Header.Get("X-User-ID")
+		if header == "" {
+			http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+			return
+		}
+		userID, err := uuid.Parse(header)
+		if err != nil {
+			http.Error(w, "Invalid User ID", http.StatusBadRequest)
+			return
+		}
+		if err := verifyCaller(r.Context(), requestToken(r), userID); err != nil {
+			logging.RequestLogger(r).Warn("Token verification failed", "err", err)
+			http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+			return
+		}
+		logging.SetUser(r.Context(), userID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey{}, userID)))
+	})
+}
+
+// requestToken returns the token the authorizer checked: the bearer token
+// API clients send, or else the browser's id_token cookie.
+func requestToken(r *http.Request) string {
+	if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok {
+		return token
+	}
+	if cookie, err := r.Cookie("id_token"); err == nil {
+		return cookie.Value
+	}
+	return ""
+}
+
+// verifyCaller checks token is a current Cognito token for userID when
+// tokenVerifier is set, so a caller reaching the service without going
+// through the API Gateway can't act as another user by setting X-User-ID.
+func verifyCaller(ctx context.Context, token string, userID uuid.UUID) error {
+	if tokenVerifier == nil {
+		return nil
+	}
+	return checkToken(ctx, token, userID)
+}
+
+// checkToken verifies token is a current Cognito token for userID. Without
+// tokenVerifier nothing can be verified and every token is turned away.
+func checkToken(ctx context.Context, token string, userID uuid.UUID) error {
+	if tokenVerifier == nil {
+		return errors.New("token verification is off")
+	}
+	if token == "" {
+		tokenRejections.WithLabelValues("missing").Inc()
+		return errors.New("no token")
+	}
+	claims, err := tokenVerifier.Verify(ctx, token)
+	if err != nil {
+		tokenRejections.WithLabelValues("invalid").Inc()
+		return err
+	}
+	sub, _ := claims["sub"].(string)
+	if subject, err := uuid.Parse(sub); err != nil || subject != userID {
+		tokenRejections.WithLabelValues("mismatch").Inc()
+		return fmt.Errorf("token subject %q isn't X-User-ID", sub)
+	}
+	return nil
+}
+
+// requestUserID returns the user requireUser let the request through for.
+func requestUserID(r *http.Request) uuid.UUID {
+	return contextUserID(r.Context())
+}
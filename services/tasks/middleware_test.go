@@ -0,0 +1,188 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auth"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRequireUser(t *testing.T) {
+	var got uuid.UUID
+	h := requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestUserID(r)
+	}))
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"missing", "", http.StatusUnauthorized},
+		{"malformed", "ana", http.StatusBadRequest},
+		{"valid", ana.String(), http.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = uuid.Nil
+			req := httptest.NewRequest("GET", "/api/tasks/read", nil)
+			if tt.header != "" {
+				req.Header.Set("X-User-ID", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			expectStatus(t, rec, tt.status)
+			want := uuid.Nil
+			if tt.status == http.StatusOK {
+				want = ana
+			}
+			if got != want {
+				t.Errorf("handler saw user %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+// verifyTokens turns VERIFY_TOKENS on for the rest of the test, trusting
+// tokens signed with the returned key.
+func verifyTokens(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokenVerifier = auth.NewVerifier("us-east-1", "us-east-1_TEST", "tasknest-web", http.DefaultClient)
+	tokenVerifier.Keys.Set(map[string]*rsa.PublicKey{"test-key": &key.PublicKey})
+	t.Cleanup(func() { tokenVerifier = nil })
+	return key
+}
+
+// idToken signs an ID token for the user.
+func idToken(t *testing.T, key *rsa.PrivateKey, userID uuid.UUID) string {
+	t.Helper()
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+		"sub":       userID.String(),
+		"token_use": "id",
+		"aud":       "tasknest-web",
+		"iss":       auth.Issuer("us-east-1", "us-east-1_TEST"),
+		"exp":       time.Now().Add(time.Hour).Unix(),
+	})
+	token.Header["kid"] = "test-key"
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func TestRequireUserVerifiesToken(t *testing.T) {
+	key := verifyTokens(t)
+	forger, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	tests := []struct {
+		name   string
+		bearer string
+		cookie string
+		status int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"bearer token", idToken(t, key, ana), "", http.StatusOK},
+		{"cookie", "", idToken(t, key, ana), http.StatusOK},
+		{"someone else's token", idToken(t, key, bob), "", http.StatusUnauthorized},
+		{"bearer token wins over the cookie", idToken(t, key, bob), idToken(t, key, ana), http.StatusUnauthorized},
+		{"forged token", idToken(t, forger, ana), "", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/tasks/read", nil)
+			req.Header.Set("X-User-ID", ana.String())
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "id_token", Value: tt.cookie})
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+			expectStatus(t, rec, tt.status)
+		})
+	}
+
+	if got := testutil.ToFloat64(tokenRejections.WithLabelValues("mismatch")); got < 2 {
+		t.Errorf("%v mismatches counted, want at least 2", got)
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	buf := captureLogs(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/tasks/capacity", func(w http.ResponseWriter, r *http.Request) {
+		var deadline *string
+		_ = *deadline
+	})
+	h := newHandler(mux, "")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/tasks/capacity", nil))
+	expectStatus(t, rec, http.StatusInternalServerError)
+
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2", len(lines))
+	}
+	if panicked := lines[0]; panicked["msg"] != "Handler panicked" || !strings.Contains(panicked["stack"].(string), "middleware_test.go") {
+		t.Errorf("panic log = %v", panicked)
+	}
+	if access := lines[1]; access["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("access log = %v", access)
+	}
+}
+
+// TestCORS checks newHandler answers the frontend's preflights, which the
+// mux has no routes for.
+func TestCORS(t *testing.T) {
+	h := newHandler(newMux(NewTaskService(NewMemoryTaskRepository(), nil)), "https://tasknest.example.com/")
+
+	req := httptest.NewRequest("OPTIONS", "/api/tasks/update/"+uuid.NewString(), nil)
+	req.Header.Set("Origin", "https://tasknest.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	expectStatus(t, rec, http.StatusNoContent)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://tasknest.example.com" {
+		t.Errorf("preflight got headers %v", rec.Header())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	s := newTestServer(t)
+
+	padded := strings.Repeat(" ", maxBodySize) + `{"title": "Task", "status": "TODO", "priority": "LOW"}`
+	rec := s.do(t, "POST", "/api/tasks/create", ana.String(), padded)
+	expectStatus(t, rec, http.StatusBadRequest)
+	if _, total, _ := s.repo.List(context.Background(), ana, Filters{}, UserPrefs{}, now, 0, 10); total != 0 {
+		t.Errorf("oversized request created %d tasks", total)
+	}
+
+	rec = s.do(t, "POST", "/api/tasks/create", ana.String(), padded[len(padded)-maxBodySize:])
+	expectStatus(t, rec, http.StatusCreated)
+}
@@ -4,22 +4,100 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
 type User struct {
-	UserID uuid.UUID `gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
-	Email  string    `gorm:"not null;unique"`
+	UserID     uuid.UUID `gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
+	Email      string    `gorm:"not null;unique"`
+	Timezone   string    `gorm:"size:64;not null;default:UTC"`
+	WeekStart  int       `gorm:"type:smallint;not null;default:1"`
+	DateFormat string    `gorm:"size:16;not null;default:YYYY-MM-DD"`
+
+	DailyCapacityMinutes int `gorm:"not null;default:480"`
+	DailyCapacityPoints  int `gorm:"not null;default:0"`
+
+	DigestFrequency string     `gorm:"size:8;not null;default:NONE"`
+	DigestHour      int        `gorm:"type:smallint;not null;default:8"`
+	DigestSentAt    *time.Time `gorm:"type:timestamptz"`
 }
 
 type Task struct {
-	TaskID       uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"task_id"`
-	UserID       uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
-	Title        string     `gorm:"size:50;not null" json:"title"`
-	Description  string     `gorm:"type:text" json:"description"`
-	CreationDate time.Time  `gorm:"type:date;default:current_date;not null" json:"creation_date"`
-	Deadline     *time.Time `gorm:"type:date" json:"deadline"`
-	Status       string     `gorm:"type:enum('TODO', 'IN_PROGRESS', 'DONE');not null" json:"status"`
-	Priority     string     `gorm:"type:enum('LOW', 'MEDIUM', 'HIGH');not null" json:"priority"`
+	TaskID          uuid.UUID      `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"task_id"`
+	UserID          uuid.UUID      `gorm:"type:uuid;not null" json:"user_id"`
+	Title           string         `gorm:"size:50;not null" json:"title"`
+	Description     string         `gorm:"type:text" json:"description"`
+	CreationDate    time.Time      `gorm:"type:timestamptz;default:now();not null" json:"creation_date"`
+	Deadline        *time.Time     `gorm:"type:timestamptz" json:"deadline"`
+	AllDay          bool           `gorm:"not null;default:false" json:"all_day"`
+	Status          string         `gorm:"type:enum('TODO', 'IN_PROGRESS', 'DONE');not null" json:"status"`
+	Priority        string         `gorm:"type:enum('LOW', 'MEDIUM', 'HIGH');not null" json:"priority"`
+	EstimateMinutes *int           `json:"estimate_minutes"`
+	EstimatePoints  *int           `json:"estimate_points"`
+	Labels          pq.StringArray `gorm:"type:text[];not null;default:'{}'" json:"labels" swaggertype:"array,string"`
+	Project         string         `gorm:"size:50;not null;default:''" json:"project"`
+	StartedAt       *time.Time     `gorm:"type:timestamptz" json:"started_at"`
+	CompletedAt     *time.Time     `gorm:"type:timestamptz" json:"completed_at"`
+	ArchivedAt      *time.Time     `gorm:"type:timestamptz" json:"archived_at"`
+	IsOverdue       bool           `gorm:"-" json:"is_overdue"`
+	TrackedSeconds  int64          `gorm:"-" json:"tracked_seconds"`
+}
+
+type TimeEntry struct {
+	EntryID   uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"entry_id"`
+	TaskID    uuid.UUID  `gorm:"type:uuid;not null" json:"task_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	StartedAt time.Time  `gorm:"type:timestamptz;not null" json:"started_at"`
+	EndedAt   *time.Time `gorm:"type:timestamptz" json:"ended_at"`
+	Note      string     `gorm:"type:text" json:"note"`
+}
+
+type Attachment struct {
+	AttachmentID uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"attachment_id"`
+	TaskID       uuid.UUID `gorm:"type:uuid;not null" json:"task_id"`
+	UserID       uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	FileName     string    `gorm:"size:255;not null" json:"file_name"`
+	ContentType  string    `gorm:"size:255;not null" json:"content_type"`
+	Size         int64     `gorm:"not null" json:"size"`
+	StorageKey   string    `gorm:"size:512;not null;unique" json:"-"`
+	CreatedAt    time.Time `gorm:"type:timestamptz;default:now();not null" json:"created_at"`
+}
+
+type Comment struct {
+	CommentID uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"comment_id"`
+	TaskID    uuid.UUID  `gorm:"type:uuid;not null" json:"task_id"`
+	UserID    uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	ParentID  *uuid.UUID `gorm:"type:uuid" json:"parent_id"`
+	Body      string     `gorm:"type:text;not null" json:"body"`
+	CreatedAt time.Time  `gorm:"type:timestamptz;default:now();not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"type:timestamptz;default:now();not null" json:"updated_at"`
+	DeletedAt *time.Time `gorm:"type:timestamptz" json:"deleted_at,omitempty"`
+	Mentions  []string   `gorm:"-" json:"mentions,omitempty"`
+	Replies   []*Comment `gorm:"-" json:"replies,omitempty"`
+}
+
+type Notification struct {
+	NotificationID uuid.UUID  `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"notification_id"`
+	UserID         uuid.UUID  `gorm:"type:uuid;not null" json:"user_id"`
+	ActorID        *uuid.UUID `gorm:"type:uuid" json:"actor_id"`
+	Kind           string     `gorm:"size:32;not null" json:"kind"`
+	TaskID         *uuid.UUID `gorm:"type:uuid" json:"task_id"`
+	CommentID      *uuid.UUID `gorm:"type:uuid" json:"comment_id"`
+	CreatedAt      time.Time  `gorm:"type:timestamptz;default:now();not null" json:"created_at"`
+	ReadAt         *time.Time `gorm:"type:timestamptz" json:"read_at"`
+}
+
+type TaskTemplate struct {
+	TemplateID      uuid.UUID `gorm:"type:uuid;default:uuid_generate_v4();primary_key" json:"template_id"`
+	UserID          uuid.UUID `gorm:"type:uuid;not null" json:"user_id"`
+	Name            string    `gorm:"size:100;not null" json:"name"`
+	Title           string    `gorm:"size:255;not null" json:"title"`
+	Description     string    `gorm:"type:text" json:"description"`
+	Priority        string    `gorm:"type:enum('LOW', 'MEDIUM', 'HIGH');not null" json:"priority"`
+	DeadlineOffset  string    `gorm:"size:16" json:"deadline_offset"`
+	EstimateMinutes *int      `json:"estimate_minutes"`
+	EstimatePoints  *int      `json:"estimate_points"`
+	CreatedAt       time.Time `gorm:"type:timestamptz;default:now();not null" json:"created_at"`
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"tasks/quickadd"
+)
+
+// quickAddRequest turns a parsed quick-add line into a regular task request.
+// Quick-added tasks start as TODO with MEDIUM priority unless the line says otherwise.
+func quickAddRequest(parsed quickadd.Result) TaskRequest {
+	req := TaskRequest{
+		Title:    parsed.Title,
+		Status:   "TODO",
+		Priority: "MEDIUM",
+		Labels:   parsed.Labels,
+	}
+	if parsed.Priority != "" {
+		req.Priority = parsed.Priority
+	}
+
+	if parsed.Deadline != nil {
+		deadline := parsed.Deadline.Format(time.RFC3339)
+		if parsed.AllDay {
+			deadline = parsed.Deadline.Format(dateLayout)
+		}
+		req.Deadline = &deadline
+	}
+	return req
+}
+
+// @Summary Quick-add a task
+// @Description Create a task from a single line such as "Pay rent tomorrow !high #finance". Dates and times are read in the user's timezone; !high, !medium and !low set the priority and #words become labels. Text in double quotes is kept as is.
+// @Tags Tasks
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param task body QuickAddRequest true "Line to parse"
+// @Success 201 {object} Task "Task created successfully"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/quick [post]
+func (h *taskHandlers) handleQuickAddTask(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req QuickAddRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	task, err := h.tasks.QuickAdd(r.Context(), userID, req.Text)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(task)
+}
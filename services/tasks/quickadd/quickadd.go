@@ -0,0 +1,464 @@
+// Package quickadd parses one-line task descriptions such as
+// "Pay rent tomorrow !high #finance" into their parts.
+//
+// Recognised anywhere in the line:
+//
+//   - priorities: !high, !h, !1, !medium, !med, !m, !2, !low, !l, !3
+//   - labels: #word, lowercased and deduplicated
+//   - days: today, tomorrow (tmr, tmrw), weekday names, next <weekday>,
+//     next week/month, in N days/weeks/months, 2026-11-03, nov 3, 3rd november,
+//     optionally with a year and introduced by on, by or due; abbreviated
+//     weekdays (mon, sat...) only count when introduced
+//   - times: 5pm, 9:30am, 17:00, noon, optionally introduced by at or by
+//   - exact offsets: in N minutes/hours
+//
+// Only the first day and the first time are used; later ones stay in the
+// title. Text inside double quotes is never interpreted.
+package quickadd
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ErrEmptyTitle is returned when nothing is left for the title once
+// everything recognised has been taken out.
+var ErrEmptyTitle = errors.New("quickadd: empty title")
+
+// Result is a parsed line.
+type Result struct {
+	Title string
+
+	// Deadline is nil when the line names no date or time. It is in the
+	// location of the now passed to Parse; for all-day deadlines it is
+	// midnight at the start of the day.
+	Deadline *time.Time
+	AllDay   bool
+
+	// Priority is LOW, MEDIUM or HIGH, or empty when not given.
+	Priority string
+
+	Labels []string
+}
+
+var priorities = map[string]string{
+	"high": "HIGH", "h": "HIGH", "1": "HIGH",
+	"medium": "MEDIUM", "med": "MEDIUM", "m": "MEDIUM", "2": "MEDIUM",
+	"low": "LOW", "l": "LOW", "3": "LOW",
+}
+
+var weekdays = map[string]time.Weekday{
+	"sunday": time.Sunday, "sun": time.Sunday,
+	"monday": time.Monday, "mon": time.Monday,
+	"tuesday": time.Tuesday, "tue": time.Tuesday, "tues": time.Tuesday,
+	"wednesday": time.Wednesday, "wed": time.Wednesday,
+	"thursday": time.Thursday, "thu": time.Thursday, "thur": time.Thursday, "thurs": time.Thursday,
+	"friday": time.Friday, "fri": time.Friday,
+	"saturday": time.Saturday, "sat": time.Saturday,
+}
+
+var months = map[string]time.Month{
+	"january": time.January, "jan": time.January,
+	"february": time.February, "feb": time.February,
+	"march": time.March, "mar": time.March,
+	"april": time.April, "apr": time.April,
+	"may":  time.May,
+	"june": time.June, "jun": time.June,
+	"july": time.July, "jul": time.July,
+	"august": time.August, "aug": time.August,
+	"september": time.September, "sep": time.September, "sept": time.September,
+	"october": time.October, "oct": time.October,
+	"november": time.November, "nov": time.November,
+	"december": time.December, "dec": time.December,
+}
+
+var numberWords = map[string]int{
+	"a": 1, "an": 1, "one": 1, "two": 2, "three": 3, "four": 4, "five": 5,
+	"six": 6, "seven": 7, "eight": 8, "nine": 9, "ten": 10,
+}
+
+var (
+	labelPattern   = regexp.MustCompile(`^#([\p{L}\p{N}_-]+)$`)
+	isoDatePattern = regexp.MustCompile(`^(\d{4})-(\d{2})-(\d{2})$`)
+	dayPattern     = regexp.MustCompile(`^(\d{1,2})(?:st|nd|rd|th)?$`)
+	yearPattern    = regexp.MustCompile(`^\d{4}$`)
+	clockPattern   = regexp.MustCompile(`^(\d{1,2})(?::(\d{2}))?(am|pm)?$`)
+	offsetPattern  = regexp.MustCompile(`^(\d+)([a-z]+)$`)
+)
+
+// token is a word of the input. Quoted tokens are kept verbatim.
+type token struct {
+	text   string
+	quoted bool
+}
+
+// tokenize splits the line on whitespace, keeping double-quoted runs together.
+func tokenize(input string) []token {
+	var tokens []token
+	var b strings.Builder
+	quoted := false
+	flush := func(q bool) {
+		if b.Len() > 0 || q {
+			tokens = append(tokens, token{text: b.String(), quoted: q})
+		}
+		b.Reset()
+	}
+
+	for _, r := range input {
+		switch {
+		case r == '"' && !quoted:
+			flush(false)
+			quoted = true
+		case r == '"' && quoted:
+			flush(true)
+			quoted = false
+		case unicode.IsSpace(r) && !quoted:
+			flush(false)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	flush(quoted)
+	return tokens
+}
+
+// word returns the lowercased token with trailing punctuation removed,
+// or "" for quoted tokens so they never match anything.
+func word(tokens []token, i int) string {
+	if i >= len(tokens) || tokens[i].quoted {
+		return ""
+	}
+	return strings.TrimRight(strings.ToLower(tokens[i].text), ",.;")
+}
+
+// date is a calendar day, or an exact instant for minute and hour offsets.
+type date struct {
+	year    int
+	month   time.Month
+	day     int
+	instant *time.Time
+}
+
+type clock struct {
+	hour, minute int
+}
+
+type parser struct {
+	tokens []token
+	now    time.Time
+}
+
+// Parse splits input into its title, deadline, priority and labels. Relative
+// dates and times are resolved against now, in now's location.
+func Parse(input string, now time.Time) (Result, error) {
+	p := parser{tokens: tokenize(input), now: now}
+
+	var res Result
+	var day *date
+	var at *clock
+	seenLabel := map[string]bool{}
+	var title []string
+
+	for i := 0; i < len(p.tokens); {
+		w := word(p.tokens, i)
+
+		if strings.HasPrefix(w, "!") {
+			if priority, ok := priorities[w[1:]]; ok {
+				res.Priority = priority
+				i++
+				continue
+			}
+		}
+
+		if m := labelPattern.FindStringSubmatch(w); m != nil {
+			if !seenLabel[m[1]] {
+				seenLabel[m[1]] = true
+				res.Labels = append(res.Labels, m[1])
+			}
+			i++
+			continue
+		}
+
+		if day == nil {
+			if d, n := p.dateAt(i); n > 0 {
+				day = &d
+				i += n
+				continue
+			}
+		}
+
+		if at == nil {
+			if c, n := p.clockAt(i); n > 0 {
+				at = &c
+				i += n
+				continue
+			}
+		}
+
+		title = append(title, p.tokens[i].text)
+		i++
+	}
+
+	res.Title = strings.TrimRight(strings.Join(title, " "), ",;:")
+	if res.Title == "" {
+		return res, ErrEmptyTitle
+	}
+
+	res.Deadline, res.AllDay = p.resolve(day, at)
+	return res, nil
+}
+
+// resolve combines the parsed day and time into a deadline.
+func (p parser) resolve(day *date, at *clock) (*time.Time, bool) {
+	loc := p.now.Location()
+	switch {
+	case day != nil && day.instant != nil:
+		return day.instant, false
+	case day != nil && at != nil:
+		t := time.Date(day.year, day.month, day.day, at.hour, at.minute, 0, 0, loc)
+		return &t, false
+	case day != nil:
+		t := time.Date(day.year, day.month, day.day, 0, 0, 0, 0, loc)
+		return &t, true
+	case at != nil:
+		// A time on its own means the next time the clock shows it
+		y, m, d := p.now.Date()
+		t := time.Date(y, m, d, at.hour, at.minute, 0, 0, loc)
+		if !t.After(p.now) {
+			t = time.Date(y, m, d+1, at.hour, at.minute, 0, 0, loc)
+		}
+		return &t, false
+	}
+	return nil, false
+}
+
+// dayOffset returns the calendar day n days from today.
+func (p parser) dayOffset(n int) date {
+	y, m, d := p.now.Date()
+	t := time.Date(y, m, d+n, 0, 0, 0, 0, time.UTC)
+	return date{year: t.Year(), month: t.Month(), day: t.Day()}
+}
+
+// dateAt tries to read a date starting at token i, returning it and
+// the number of tokens it spans, or 0 if there is none.
+func (p parser) dateAt(i int) (date, int) {
+	switch word(p.tokens, i) {
+	case "on", "by", "due":
+		if d, n := p.bareDateAt(i+1, true); n > 0 {
+			return d, n + 1
+		}
+		return date{}, 0
+	}
+	return p.bareDateAt(i, false)
+}
+
+// bareDateAt reads a date without a leading preposition. Abbreviated weekdays
+// like "sun" or "sat" only count after one, they are too common as words.
+func (p parser) bareDateAt(i int, introduced bool) (date, int) {
+	w := word(p.tokens, i)
+
+	switch w {
+	case "today", "tonight":
+		return p.dayOffset(0), 1
+	case "tomorrow", "tmr", "tmrw":
+		return p.dayOffset(1), 1
+	}
+
+	if wd, ok := weekdays[w]; ok && (introduced || strings.HasSuffix(w, "day")) {
+		return p.dayOffset(p.daysUntil(wd)), 1
+	}
+
+	if w == "next" {
+		next := word(p.tokens, i+1)
+		if wd, ok := weekdays[next]; ok {
+			return p.dayOffset(p.daysUntil(wd) + 7), 2
+		}
+		switch next {
+		case "week":
+			return p.dayOffset(7), 2
+		case "month":
+			return p.monthOffset(1), 2
+		}
+		return date{}, 0
+	}
+
+	if w == "in" {
+		return p.offsetAt(i + 1)
+	}
+
+	if m := isoDatePattern.FindStringSubmatch(w); m != nil {
+		y, _ := strconv.Atoi(m[1])
+		mo, _ := strconv.Atoi(m[2])
+		d, _ := strconv.Atoi(m[3])
+		if validDay(y, time.Month(mo), d) {
+			return date{year: y, month: time.Month(mo), day: d}, 1
+		}
+		return date{}, 0
+	}
+
+	// "nov 3" or "november 3rd"
+	if mo, ok := months[w]; ok {
+		if m := dayPattern.FindStringSubmatch(word(p.tokens, i+1)); m != nil {
+			d, _ := strconv.Atoi(m[1])
+			return p.monthDay(mo, d, i+2, 2)
+		}
+		return date{}, 0
+	}
+
+	// "3 nov" or "3rd of november"
+	if m := dayPattern.FindStringSubmatch(w); m != nil {
+		j := i + 1
+		if word(p.tokens, j) == "of" {
+			j++
+		}
+		if mo, ok := months[word(p.tokens, j)]; ok {
+			d, _ := strconv.Atoi(m[1])
+			return p.monthDay(mo, d, j+1, j+1-i)
+		}
+	}
+
+	return date{}, 0
+}
+
+// monthDay resolves a day of a month, reading an optional year at token i.
+// Without a year the next occurrence from today on is used.
+func (p parser) monthDay(mo time.Month, d, i, n int) (date, int) {
+	y := p.now.Year()
+	if yearPattern.MatchString(word(p.tokens, i)) {
+		y, _ = strconv.Atoi(word(p.tokens, i))
+		n++
+	} else {
+		today := p.dayOffset(0)
+		if mo < today.month || (mo == today.month && d < today.day) {
+			y++
+		}
+	}
+
+	if !validDay(y, mo, d) {
+		return date{}, 0
+	}
+	return date{year: y, month: mo, day: d}, n
+}
+
+// offsetAt reads the "N unit" or "Nunit" after "in", starting at token i.
+func (p parser) offsetAt(i int) (date, int) {
+	w := word(p.tokens, i)
+
+	var n int
+	var unit string
+	var span int
+	if m := offsetPattern.FindStringSubmatch(w); m != nil {
+		n, _ = strconv.Atoi(m[1])
+		unit, span = m[2], 2
+	} else {
+		var err error
+		if n, err = strconv.Atoi(w); err != nil {
+			var ok bool
+			if n, ok = numberWords[w]; !ok {
+				return date{}, 0
+			}
+		}
+		unit, span = word(p.tokens, i+1), 3
+	}
+
+	switch unit {
+	case "m", "min", "mins", "minute", "minutes":
+		t := p.now.Add(time.Duration(n) * time.Minute).Truncate(time.Minute)
+		return date{instant: &t}, span
+	case "h", "hr", "hrs", "hour", "hours":
+		t := p.now.Add(time.Duration(n) * time.Hour).Truncate(time.Minute)
+		return date{instant: &t}, span
+	case "d", "day", "days":
+		return p.dayOffset(n), span
+	case "w", "wk", "wks", "week", "weeks":
+		return p.dayOffset(7 * n), span
+	case "mo", "month", "months":
+		return p.monthOffset(n), span
+	}
+	return date{}, 0
+}
+
+// monthOffset moves n months ahead, clamping to the end of shorter months.
+func (p parser) monthOffset(n int) date {
+	y, m, d := p.now.Date()
+	first := time.Date(y, m+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	last := first.AddDate(0, 1, -1).Day()
+	return date{year: first.Year(), month: first.Month(), day: min(d, last)}
+}
+
+// daysUntil counts the days to the next wd strictly after today.
+func (p parser) daysUntil(wd time.Weekday) int {
+	n := (int(wd) - int(p.now.Weekday()) + 7) % 7
+	if n == 0 {
+		n = 7
+	}
+	return n
+}
+
+// clockAt tries to read a time of day starting at token i.
+func (p parser) clockAt(i int) (clock, int) {
+	switch word(p.tokens, i) {
+	case "at", "by":
+		if c, ok := parseClock(word(p.tokens, i+1)); ok {
+			return c, 2
+		}
+		return clock{}, 0
+	}
+	if c, ok := parseClock(word(p.tokens, i)); ok {
+		return c, 1
+	}
+	return clock{}, 0
+}
+
+// parseClock reads 5pm, 9:30am, 17:00, noon or midnight. Bare numbers
+// aren't times, "5" is too ambiguous.
+func parseClock(w string) (clock, bool) {
+	switch w {
+	case "noon":
+		return clock{12, 0}, true
+	case "midnight":
+		return clock{0, 0}, true
+	}
+
+	m := clockPattern.FindStringSubmatch(w)
+	if m == nil || (m[2] == "" && m[3] == "") {
+		return clock{}, false
+	}
+
+	h, _ := strconv.Atoi(m[1])
+	minute := 0
+	if m[2] != "" {
+		minute, _ = strconv.Atoi(m[2])
+	}
+	if minute > 59 {
+		return clock{}, false
+	}
+
+	switch m[3] {
+	case "":
+		if h > 23 {
+			return clock{}, false
+		}
+	case "am", "pm":
+		if h < 1 || h > 12 {
+			return clock{}, false
+		}
+		h %= 12
+		if m[3] == "pm" {
+			h += 12
+		}
+	}
+	return clock{h, minute}, true
+}
+
+func validDay(y int, mo time.Month, d int) bool {
+	if mo < time.January || mo > time.December || d < 1 {
+		return false
+	}
+	t := time.Date(y, mo, d, 0, 0, 0, 0, time.UTC)
+	return t.Day() == d
+}
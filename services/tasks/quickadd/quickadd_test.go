@@ -0,0 +1,238 @@
+package quickadd
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+// now is Wednesday 14 October 2026, 10:30 in Lisbon.
+var now = time.Date(2026, time.October, 14, 10, 30, 0, 0, lisbon())
+
+func lisbon() *time.Location {
+	loc, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		panic(err)
+	}
+	return loc
+}
+
+func day(y int, m time.Month, d int) *time.Time {
+	t := time.Date(y, m, d, 0, 0, 0, 0, now.Location())
+	return &t
+}
+
+func at(y int, m time.Month, d, hour, min int) *time.Time {
+	t := time.Date(y, m, d, hour, min, 0, 0, now.Location())
+	return &t
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  Result
+	}{
+		{
+			input: "Pay rent tomorrow !high #finance",
+			want:  Result{Title: "Pay rent", Deadline: day(2026, 10, 15), AllDay: true, Priority: "HIGH", Labels: []string{"finance"}},
+		},
+		{
+			input: "Buy milk",
+			want:  Result{Title: "Buy milk"},
+		},
+		{
+			input: "Call the bank today",
+			want:  Result{Title: "Call the bank", Deadline: day(2026, 10, 14), AllDay: true},
+		},
+		{
+			input: "Water plants tmrw",
+			want:  Result{Title: "Water plants", Deadline: day(2026, 10, 15), AllDay: true},
+		},
+		{
+			input: "Team lunch friday",
+			want:  Result{Title: "Team lunch", Deadline: day(2026, 10, 16), AllDay: true},
+		},
+		{
+			// The same weekday as today means next week's
+			input: "Standup wednesday",
+			want:  Result{Title: "Standup", Deadline: day(2026, 10, 21), AllDay: true},
+		},
+		{
+			input: "Dentist next friday",
+			want:  Result{Title: "Dentist", Deadline: day(2026, 10, 23), AllDay: true},
+		},
+		{
+			input: "Send report on mon",
+			want:  Result{Title: "Send report", Deadline: day(2026, 10, 19), AllDay: true},
+		},
+		{
+			// Bare abbreviations are ordinary words
+			input: "Enjoy the sun",
+			want:  Result{Title: "Enjoy the sun"},
+		},
+		{
+			input: "Renew passport next week",
+			want:  Result{Title: "Renew passport", Deadline: day(2026, 10, 21), AllDay: true},
+		},
+		{
+			input: "Book review next month",
+			want:  Result{Title: "Book review", Deadline: day(2026, 11, 14), AllDay: true},
+		},
+		{
+			input: "Follow up in 3 days",
+			want:  Result{Title: "Follow up", Deadline: day(2026, 10, 17), AllDay: true},
+		},
+		{
+			input: "Follow up in 2w",
+			want:  Result{Title: "Follow up", Deadline: day(2026, 10, 28), AllDay: true},
+		},
+		{
+			input: "Check oven in 45 minutes",
+			want:  Result{Title: "Check oven", Deadline: at(2026, 10, 14, 11, 15)},
+		},
+		{
+			input: "Call back in an hour",
+			want:  Result{Title: "Call back", Deadline: at(2026, 10, 14, 11, 30)},
+		},
+		{
+			input: "Put the books in a box",
+			want:  Result{Title: "Put the books in a box"},
+		},
+		{
+			input: "File taxes 2026-11-03",
+			want:  Result{Title: "File taxes", Deadline: day(2026, 11, 3), AllDay: true},
+		},
+		{
+			input: "Conference nov 3rd",
+			want:  Result{Title: "Conference", Deadline: day(2026, 11, 3), AllDay: true},
+		},
+		{
+			input: "Conference 3 November 2027",
+			want:  Result{Title: "Conference", Deadline: day(2027, 11, 3), AllDay: true},
+		},
+		{
+			// A date already past this year means next year's
+			input: "Birthday party 2nd of march",
+			want:  Result{Title: "Birthday party", Deadline: day(2027, 3, 2), AllDay: true},
+		},
+		{
+			input: "Buy 3 apples",
+			want:  Result{Title: "Buy 3 apples"},
+		},
+		{
+			input: "Meeting tomorrow at 3pm !m",
+			want:  Result{Title: "Meeting", Deadline: at(2026, 10, 15, 15, 0), Priority: "MEDIUM"},
+		},
+		{
+			input: "Submit form by friday 17:30",
+			want:  Result{Title: "Submit form", Deadline: at(2026, 10, 16, 17, 30)},
+		},
+		{
+			input: "Lunch at noon",
+			want:  Result{Title: "Lunch", Deadline: at(2026, 10, 14, 12, 0)},
+		},
+		{
+			// Times already gone today roll over to tomorrow
+			input: "Take pills 9am",
+			want:  Result{Title: "Take pills", Deadline: at(2026, 10, 15, 9, 0)},
+		},
+		{
+			input: "Read chapter 5",
+			want:  Result{Title: "Read chapter 5"},
+		},
+		{
+			input: "Clean #home #Chores #home !low",
+			want:  Result{Title: "Clean", Priority: "LOW", Labels: []string{"home", "chores"}},
+		},
+		{
+			// The last priority wins
+			input: "Fix bug !low !1",
+			want:  Result{Title: "Fix bug", Priority: "HIGH"},
+		},
+		{
+			input: "Say hi!",
+			want:  Result{Title: "Say hi!"},
+		},
+		{
+			input: `Watch "Tomorrow never dies" friday`,
+			want:  Result{Title: "Watch Tomorrow never dies", Deadline: day(2026, 10, 16), AllDay: true},
+		},
+		{
+			// Only the first date counts, later ones stay in the title
+			input: "Move meeting tomorrow not friday",
+			want:  Result{Title: "Move meeting not friday", Deadline: day(2026, 10, 15), AllDay: true},
+		},
+		{
+			input: "Pay rent, tomorrow",
+			want:  Result{Title: "Pay rent", Deadline: day(2026, 10, 15), AllDay: true},
+		},
+		{
+			input: "Invalid date 2026-02-30",
+			want:  Result{Title: "Invalid date 2026-02-30"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := Parse(tt.input, now)
+			if err != nil {
+				t.Fatalf("Parse(%q) returned error: %v", tt.input, err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Parse(%q)\n got %+v\nwant %+v", tt.input, describe(got), describe(tt.want))
+			}
+		})
+	}
+}
+
+func TestParseEmptyTitle(t *testing.T) {
+	for _, input := range []string{"", "   ", "tomorrow !high #finance", "at 5pm"} {
+		if _, err := Parse(input, now); !errors.Is(err, ErrEmptyTitle) {
+			t.Errorf("Parse(%q) error = %v, want ErrEmptyTitle", input, err)
+		}
+	}
+}
+
+func TestParseMonthEnd(t *testing.T) {
+	endOfJanuary := time.Date(2027, time.January, 31, 9, 0, 0, 0, time.UTC)
+	got, err := Parse("Invoice next month", endOfJanuary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2027, time.February, 28, 0, 0, 0, 0, time.UTC)
+	if got.Deadline == nil || !got.Deadline.Equal(want) {
+		t.Errorf("deadline = %v, want %v", got.Deadline, want)
+	}
+}
+
+func TestParseAcrossDST(t *testing.T) {
+	// Clocks go back in Lisbon on 25 October 2026
+	saturday := time.Date(2026, time.October, 24, 20, 0, 0, 0, now.Location())
+	got, err := Parse("Gym tomorrow 8:00", saturday)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := time.Date(2026, time.October, 25, 8, 0, 0, 0, now.Location())
+	if got.Deadline == nil || !got.Deadline.Equal(want) {
+		t.Errorf("deadline = %v, want %v", got.Deadline, want)
+	}
+	if _, offset := got.Deadline.Zone(); offset != 0 {
+		t.Errorf("expected winter time, got offset %d", offset)
+	}
+}
+
+// describe prints the deadline rather than the pointer.
+func describe(r Result) any {
+	deadline := "<nil>"
+	if r.Deadline != nil {
+		deadline = r.Deadline.Format(time.RFC3339)
+	}
+	return struct {
+		Title, Deadline string
+		AllDay          bool
+		Priority        string
+		Labels          []string
+	}{r.Title, deadline, r.AllDay, r.Priority, r.Labels}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// TaskRepository stores tasks. Every lookup is scoped to the owning user, so
+// another user's task is reported as errTaskNotFound like a missing one.
+//
+// Only the task endpoints, their gRPC and GraphQL counterparts and creating
+// a task from a template go through it. Time tracking, attachments, comments,
+// the templates themselves, statistics, capacity and loadUserPrefs still
+// query the package db directly; moving them behind repositories of their own
+// is left for later.
+type TaskRepository interface {
+	// List returns up to limit of the user's tasks matching filters, skipping
+	// the first offset, and how many match in total. The filters have already
+	// been validated; prefs and now give the due filter its meaning.
+	List(ctx context.Context, userID uuid.UUID, filters Filters, prefs UserPrefs, now time.Time, offset, limit int) ([]Task, int64, error)
+	Get(ctx context.Context, userID, taskID uuid.UUID) (Task, error)
+	// Create stores a new task, filling in its ID.
+	Create(ctx context.Context, task *Task) error
+	// Save writes every field of an existing task.
+	Save(ctx context.Context, task *Task) error
+	SetArchivedAt(ctx context.Context, userID, taskID uuid.UUID, archivedAt *time.Time) error
+	// Delete removes a task along with its attachment rows and returns the
+	// storage keys of the attachments, whose blobs are left to the caller.
+	Delete(ctx context.Context, userID, taskID uuid.UUID) ([]string, error)
+
+	// UserPrefs returns the user's deadline settings, or the defaults for an unknown user.
+	UserPrefs(ctx context.Context, userID uuid.UUID) (UserPrefs, error)
+	// TrackedSeconds sums the time logged against each task up to now.
+	TrackedSeconds(ctx context.Context, taskIDs []uuid.UUID, now time.Time) (map[uuid.UUID]int64, error)
+
+	TaskRelations
+}
+
+// TaskRelations loads what hangs off a batch of tasks, for the GraphQL
+// resolvers. Each method answers for all the given IDs at once.
+type TaskRelations interface {
+	// Users returns those of the users that exist.
+	Users(ctx context.Context, userIDs []uuid.UUID) ([]User, error)
+	// Comments returns the comments on the tasks, oldest first, including
+	// deleted ones that still hold replies.
+	Comments(ctx context.Context, taskIDs []uuid.UUID) ([]*Comment, error)
+	// Attachments returns the user's attachments on the tasks, oldest first.
+	Attachments(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]Attachment, error)
+	// TimeEntries returns the user's time entries on the tasks, newest first.
+	TimeEntries(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]TimeEntry, error)
+}
+
+// GormTaskRepository is the TaskRepository backed by Postgres.
+type GormTaskRepository struct {
+	db *gorm.DB
+}
+
+func NewGormTaskRepository(db *gorm.DB) *GormTaskRepository {
+	return &GormTaskRepository{db: db}
+}
+
+func (r *GormTaskRepository) List(ctx context.Context, userID uuid.UUID, filters Filters, prefs UserPrefs, now time.Time, offset, limit int) ([]Task, int64, error) {
+	query := r.db.WithContext(ctx).Model(&Task{}).Where("user_id = ?", userID)
+
+	if filters.Status != "" {
+		query = query.Where("status = ?", filters.Status)
+	}
+
+	if filters.Priority != "" {
+		query = query.Where("priority = ?", filters.Priority)
+	}
+
+	if filters.Label != "" {
+		query = query.Where("? = ANY(labels)", strings.ToLower(filters.Label))
+	}
+
+	switch filters.Archive {
+	case "", "false":
+		query = query.Where("archived_at IS NULL")
+	case "only":
+		query = query.Where("archived_at IS NOT NULL")
+	}
+
+	if filters.Due != "" {
+		query = applyDueFilter(query, filters.Due, now, prefs)
+	}
+
+	// Apply ordering
+	if filters.Sort != "" {
+		query = query.Order(clause.OrderByColumn{
+			Column: clause.Column{Name: filters.Sort},
+			Desc:   strings.ToLower(filters.Order) == "desc",
+		})
+	}
+	// Ties keep a stable order so pages don't overlap
+	query = query.Order("task_id")
+
+	// Count total before pagination
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var tasks []Task
+	if err := query.
+		Offset(offset).
+		Limit(limit).
+		Find(&tasks).Error; err != nil {
+		return nil, 0, err
+	}
+	return tasks, total, nil
+}
+
+func (r *GormTaskRepository) Get(ctx context.Context, userID, taskID uuid.UUID) (Task, error) {
+	var task Task
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND task_id = ?", userID, taskID).First(&task).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return Task{}, errTaskNotFound
+		}
+		return Task{}, err
+	}
+	return task, nil
+}
+
+func (r *GormTaskRepository) Create(ctx context.Context, task *Task) error {
+	return r.db.WithContext(ctx).Create(task).Error
+}
+
+func (r *GormTaskRepository) Save(ctx context.Context, task *Task) error {
+	return r.db.WithContext(ctx).Save(task).Error
+}
+
+func (r *GormTaskRepository) SetArchivedAt(ctx context.Context, userID, taskID uuid.UUID, archivedAt *time.Time) error {
+	result := r.db.WithContext(ctx).Model(&Task{}).
+		Where("user_id = ? AND task_id = ?", userID, taskID).
+		Update("archived_at", archivedAt)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errTaskNotFound
+	}
+	return nil
+}
+
+func (r *GormTaskRepository) Delete(ctx context.Context, userID, taskID uuid.UUID) ([]string, error) {
+	var storageKeys []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Attachment rows cascade with the task, their keys have to be read first
+		if err := tx.Model(&Attachment{}).Where("user_id = ? AND task_id = ?", userID, taskID).
+			Pluck("storage_key", &storageKeys).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ? AND task_id = ?", userID, taskID).Delete(&Task{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errTaskNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return storageKeys, nil
+}
+
+func (r *GormTaskRepository) UserPrefs(ctx context.Context, userID uuid.UUID) (UserPrefs, error) {
+	return queryUserPrefs(r.db.WithContext(ctx), userID.String())
+}
+
+func (r *GormTaskRepository) TrackedSeconds(ctx context.Context, taskIDs []uuid.UUID, now time.Time) (map[uuid.UUID]int64, error) {
+	if len(taskIDs) == 0 {
+		return map[uuid.UUID]int64{}, nil
+	}
+	return trackedSeconds(r.db.WithContext(ctx), taskIDs, now)
+}
+
+func (r *GormTaskRepository) Users(ctx context.Context, userIDs []uuid.UUID) ([]User, error) {
+	var users []User
+	err := r.db.WithContext(ctx).Where("user_id IN ?", userIDs).Find(&users).Error
+	return users, err
+}
+
+func (r *GormTaskRepository) Comments(ctx context.Context, taskIDs []uuid.UUID) ([]*Comment, error) {
+	var comments []*Comment
+	err := r.db.WithContext(ctx).Where("task_id IN ?", taskIDs).Order("created_at").Find(&comments).Error
+	return comments, err
+}
+
+func (r *GormTaskRepository) Attachments(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]Attachment, error) {
+	var attachments []Attachment
+	err := r.db.WithContext(ctx).Where("user_id = ? AND task_id IN ?", userID, taskIDs).
+		Order("created_at").Find(&attachments).Error
+	return attachments, err
+}
+
+func (r *GormTaskRepository) TimeEntries(ctx context.Context, userID uuid.UUID, taskIDs []uuid.UUID) ([]TimeEntry, error) {
+	var entries []TimeEntry
+	err := r.db.WithContext(ctx).Where("user_id = ? AND task_id IN ?", userID, taskIDs).
+		Order("started_at DESC").Find(&entries).Error
+	return entries, err
+}
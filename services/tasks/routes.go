@@ -4,33 +4,168 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"log"
 	"net/http"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
+	"unicode/utf8"
+
+	"platform/logging"
 
 	"github.com/google/uuid"
-	"gorm.io/gorm"
-	"gorm.io/gorm/clause"
 )
 
-func ParseDate(dateStr string) (*time.Time, error) {
-	var parsedDate time.Time
-	var err error
-
-	parsedDate, err = time.Parse(time.RFC3339, dateStr)
+// ParseDate accepts an RFC 3339 timestamp or a bare YYYY-MM-DD date and reports
+// whether the result is an all-day deadline. Bare dates are kept as midnight UTC.
+func ParseDate(dateStr string) (*time.Time, bool, error) {
+	parsedDate, err := time.Parse(time.RFC3339, dateStr)
 	if err == nil {
-		return &parsedDate, nil
+		// Postgres stores microseconds, so drop anything finer to round-trip exactly
+		parsedDate = parsedDate.Truncate(time.Microsecond)
+		return &parsedDate, false, nil
 	}
 
-	parsedDate, err = time.Parse("2006-01-02", dateStr)
+	parsedDate, err = time.Parse(dateLayout, dateStr)
 	if err == nil {
-		parsedDate = parsedDate.UTC()
-		return &parsedDate, nil
+		return &parsedDate, true, nil
+	}
+
+	return nil, false, err
+}
+
+// parseDeadline resolves the deadline and all-day flag of a task request.
+// Setting all_day on a timestamp keeps only its calendar date.
+func parseDeadline(req TaskRequest) (*time.Time, bool, error) {
+	if req.Deadline == nil || *req.Deadline == "" {
+		return nil, false, nil
+	}
+
+	deadline, allDay, err := ParseDate(*req.Deadline)
+	if err != nil {
+		return nil, false, err
+	}
+
+	if req.AllDay != nil && *req.AllDay && !allDay {
+		day := calendarDay(*deadline, deadline.Location())
+		return &day, true, nil
+	}
+
+	return deadline, allDay, nil
+}
+
+// maxLabels caps how many labels a single task can carry.
+const maxLabels = 20
+
+var labelPattern = regexp.MustCompile(`^[\p{L}\p{N}_-]{1,32}$`)
+
+// normalizeLabels lowercases labels, drops a leading "#" and duplicates, and
+// rejects anything that isn't a short word. It never returns nil.
+func normalizeLabels(labels []string) ([]string, error) {
+	seen := map[string]bool{}
+	out := []string{}
+	for _, label := range labels {
+		label = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(label), "#"))
+		if !labelPattern.MatchString(label) {
+			return nil, fmt.Errorf("invalid label %q", label)
+		}
+		if !seen[label] {
+			seen[label] = true
+			out = append(out, label)
+		}
+	}
+	if len(out) > maxLabels {
+		return nil, errors.New("too many labels")
+	}
+	return out, nil
+}
+
+// maxProjectLength matches the size of the project column.
+const maxProjectLength = 50
+
+// normalizeProject trims a project name, rejecting one too long to store.
+func normalizeProject(project *string) (string, error) {
+	if project == nil {
+		return "", nil
+	}
+	name := strings.TrimSpace(*project)
+	if utf8.RuneCountInString(name) > maxProjectLength {
+		return "", fmt.Errorf("project must be at most %d characters", maxProjectLength)
+	}
+	return name, nil
+}
+
+var validStatuses = map[string]bool{
+	"TODO":        true,
+	"IN_PROGRESS": true,
+	"DONE":        true,
+}
+
+// validateTaskFields checks the fields the Tasks table constrains, so a bad
+// request is turned away here rather than failing in the database.
+func validateTaskFields(req TaskRequest) error {
+	switch {
+	case utf8.RuneCountInString(req.Title) > maxTitleLength:
+		return fmt.Errorf("Title is longer than %d characters", maxTitleLength)
+	case !validStatuses[req.Status]:
+		return errors.New("Invalid status")
+	case !validPriorities[req.Priority]:
+		return errors.New("Invalid priority")
+	}
+	return nil
+}
+
+// buildTask validates a task request and turns it into a new task for the user.
+// The errors it returns are meant for the client.
+func buildTask(userID uuid.UUID, req TaskRequest, now time.Time) (Task, error) {
+	if err := validateTaskFields(req); err != nil {
+		return Task{}, err
+	}
+
+	parsedDeadline, allDay, err := parseDeadline(req)
+	if err != nil {
+		return Task{}, errors.New("Invalid date format")
 	}
 
-	return nil, err
+	if !validEstimates(req) {
+		return Task{}, errors.New("Estimates must not be negative")
+	}
+
+	labels, err := normalizeLabels(req.Labels)
+	if err != nil {
+		return Task{}, err
+	}
+
+	project, err := normalizeProject(req.Project)
+	if err != nil {
+		return Task{}, err
+	}
+
+	var startedAt, completedAt *time.Time
+	now = now.Truncate(time.Microsecond)
+	switch req.Status {
+	case "IN_PROGRESS":
+		startedAt = &now
+	case "DONE":
+		completedAt = &now
+	}
+
+	return Task{
+		UserID:          userID,
+		CreationDate:    now,
+		Status:          req.Status,
+		Description:     req.Description,
+		Title:           req.Title,
+		Deadline:        parsedDeadline,
+		AllDay:          allDay,
+		Priority:        req.Priority,
+		EstimateMinutes: req.EstimateMinutes,
+		EstimatePoints:  req.EstimatePoints,
+		Labels:          labels,
+		Project:         project,
+		StartedAt:       startedAt,
+		CompletedAt:     completedAt,
+	}, nil
 }
 
 func getPaginationParams(r *http.Request) (int, int) {
@@ -61,6 +196,11 @@ func handleHealthCheck(w http.ResponseWriter, r *http.Request) {
 	w.Write([]byte("OK"))
 }
 
+// taskHandlers serves the task endpoints on top of the service layer.
+type taskHandlers struct {
+	tasks TaskOperations
+}
+
 var validSort = map[string]bool{
 	"creation_date": true,
 	"deadline":      true,
@@ -78,22 +218,19 @@ var validSort = map[string]bool{
 // @Param priority query string false "Filter by priority"
 // @Param sort query string false "Sort by field"
 // @Param order query string false "Order direction (asc/desc)"
-// @Success 200 {object} map[string]interface{}
+// @Param due query string false "Filter by deadline in the user's timezone (overdue, today, this_week, none)"
+// @Param archive query string false "Include archived tasks (false, true, only); defaults to false"
+// @Param label query string false "Only tasks carrying this label"
+// @Success 200 {object} TaskPage
+// @Failure 400 {string} string "Invalid filter"
 // @Failure 401 {string} string "Unauthorized"
 // @Failure 500 {string} string "Internal server error"
 // @Router /api/tasks [get]
-func handleGetTasks(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		http.Error(w, "Unauthorized User", http.StatusUnauthorized)
-		return
-	}
+func (h *taskHandlers) handleGetTasks(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
 
 	page, limit := getPaginationParams(r)
 
-	// Create a base query
-	query := db.Model(&Task{}).Where("user_id = ?", userID)
-
 	qs := r.URL.Query()
 
 	filters := Filters{
@@ -101,49 +238,52 @@ func handleGetTasks(w http.ResponseWriter, r *http.Request) {
 		Priority: qs.Get("priority"),
 		Sort:     qs.Get("sort"),
 		Order:    qs.Get("order"),
+		Due:      qs.Get("due"),
+		Archive:  qs.Get("archive"),
+		Label:    qs.Get("label"),
 	}
 
-	if filters.Status != "" {
-		query = query.Where("status = ?", filters.Status)
+	result, err := h.tasks.List(r.Context(), userID, filters, (page-1)*limit, limit)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
 	}
 
-	if filters.Priority != "" {
-		query = query.Where("priority = ?", filters.Priority)
-	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(result)
+}
 
-	// Apply ordering
-	if filters.Sort != "" && validSort[filters.Sort] {
-		query = query.Order(clause.OrderByColumn{
-			Column: clause.Column{Name: filters.Sort},
-			Desc:   strings.ToLower(filters.Order) == "desc",
-		})
-	}
+// @Summary Get a task
+// @Description Retrieve a single task of the authenticated user
+// @Tags Tasks
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 200 {object} Task
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/read/{id} [get]
+func (h *taskHandlers) handleGetTask(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
 
-	// Count total before pagination
-	var total int64
-	if err := query.Count(&total).Error; err != nil {
-		log.Printf("Error counting tasks: %v", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
-	// Apply pagination
-	var tasks []Task
-	if err := query.
-		Offset((page - 1) * limit).
-		Limit(limit).
-		Find(&tasks).Error; err != nil {
-		log.Printf("No Tasks Found: %v", err)
-		http.Error(w, err.Error(), http.StatusNotFound)
+	task, err := h.tasks.Get(r.Context(), userID, taskID)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"tasks": tasks,
-		"total": total,
-	})
+	json.NewEncoder(w).Encode(task)
 }
 
 // @Summary Create a new task
@@ -158,51 +298,17 @@ func handleGetTasks(w http.ResponseWriter, r *http.Request) {
 // @Failure 401 {string} string "Unauthorized User"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /tasks [post]
-func handleCreateTask(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		http.Error(w, "Unauthorized User", http.StatusUnauthorized)
-		return
-	}
-
+func (h *taskHandlers) handleCreateTask(w http.ResponseWriter, r *http.Request) {
 	var taskReq TaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&taskReq); err != nil {
-		log.Printf("Couldn't decode Body: %v\n", err)
+		logging.RequestLogger(r).Error("Couldn't decode Body", "err", err)
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
-	var err error
-	user_id, err := uuid.Parse(userID)
-	if err != nil {
-		http.Error(w, "Invalid User ID", http.StatusBadRequest)
-		return
-	}
-
-	var parsedDeadline *time.Time
-
-	if *taskReq.Deadline == "" {
-		parsedDeadline = nil
-	} else {
-		parsedDeadline, err = ParseDate(*taskReq.Deadline)
-		if err != nil {
-			http.Error(w, "Invalid date format", http.StatusBadRequest)
-			return
-		}
-	}
 
-	var task Task
-
-	if err := db.FirstOrCreate(&task, Task{
-		UserID:       user_id,
-		CreationDate: time.Now(),
-		Status:       taskReq.Status,
-		Description:  taskReq.Description,
-		Title:        taskReq.Title,
-		Deadline:     parsedDeadline,
-		Priority:     taskReq.Priority,
-	}).Error; err != nil {
-		fmt.Printf("Couldn't Create Task: %v\n", err)
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	task, err := h.tasks.Create(r.Context(), requestUserID(r), taskReq)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
@@ -225,58 +331,30 @@ func handleCreateTask(w http.ResponseWriter, r *http.Request) {
 // @Failure 404 {string} string "Task not found"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /tasks/{id} [put]
-func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+func (h *taskHandlers) handleUpdateTask(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
-	taskID := r.PathValue("id")
-
 	var task TaskRequest
 	if err := json.NewDecoder(r.Body).Decode(&task); err != nil {
 		http.Error(w, "Invalid input", http.StatusBadRequest)
 		return
 	}
 
-	var existingTask Task
-	if err := db.Where("user_id = ? AND task_id = ?", userID, taskID).First(&existingTask).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("Task wasn't found: %v\n", err)
-			http.Error(w, "Task not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
-		return
-	}
-
-	var parsedDeadline *time.Time
-	var err error
-	if *task.Deadline == "" {
-		parsedDeadline = nil
-	} else {
-		parsedDeadline, err = ParseDate(*task.Deadline)
-		if err != nil {
-			http.Error(w, "Invalid date format", http.StatusBadRequest)
-			return
-		}
-	}
-
-	existingTask.Title = task.Title
-	existingTask.Description = task.Description
-	existingTask.Status = task.Status
-	existingTask.Priority = task.Priority
-	existingTask.Deadline = parsedDeadline
-
-	if err := db.Save(&existingTask).Error; err != nil {
-		http.Error(w, "Failed to update task", http.StatusInternalServerError)
+	updated, err := h.tasks.Update(r.Context(), userID, taskID, task)
+	if err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusOK)
-	json.NewEncoder(w).Encode(task)
+	json.NewEncoder(w).Encode(updated)
 }
 
 // @Summary Delete a task
@@ -285,26 +363,22 @@ func handleUpdateTask(w http.ResponseWriter, r *http.Request) {
 // @Param X-User-ID header string true "User ID"
 // @Param id path string true "Task ID"
 // @Success 200 {string} string "Task deleted successfully"
+// @Failure 400 {string} string "Invalid User ID"
 // @Failure 401 {string} string "Unauthorized User"
 // @Failure 404 {string} string "Task not found"
 // @Failure 500 {string} string "Internal Server Error"
 // @Router /tasks/{id} [delete]
-func handleDeleteTask(w http.ResponseWriter, r *http.Request) {
-	userID := r.Header.Get("X-User-ID")
-	if userID == "" {
-		http.Error(w, "Unauthorized User", http.StatusUnauthorized)
+func (h *taskHandlers) handleDeleteTask(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	taskID, err := uuid.Parse(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, "Task not found", http.StatusNotFound)
 		return
 	}
 
-	taskID := r.PathValue("id")
-
-	if err := db.Where("user_id = ? AND task_id = ?", userID, taskID).Delete(&Task{}).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("Task Wasnt't Found: %v\n", err)
-			http.Error(w, "Task not found", http.StatusNotFound)
-		} else {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		}
+	if err := h.tasks.Delete(r.Context(), userID, taskID); err != nil {
+		writeServiceError(w, r, err)
 		return
 	}
 
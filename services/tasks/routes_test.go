@@ -0,0 +1,560 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+
+	"github.com/google/uuid"
+)
+
+// now is Wednesday 14 October 2026, 10:30 UTC.
+var now = time.Date(2026, time.October, 14, 10, 30, 0, 0, time.UTC)
+
+var (
+	ana = uuid.MustParse("6f1c2a9e-6d3b-4c55-9a43-2f4f0e0b8a11")
+	bob = uuid.MustParse("0d8e7c1b-3a4f-4e2d-8b6a-9c5d4e3f2a10")
+)
+
+type testServer struct {
+	repo    *MemoryTaskRepository
+	blobDir string
+	mux     *http.ServeMux
+}
+
+// newTestServer routes the task endpoints the way main does, over an
+// in-memory repository and a clock stopped at now.
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	blobDir := t.TempDir()
+	blobs, err := NewLocalBlobStore(blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewMemoryTaskRepository()
+	service := NewTaskService(repo, blobs)
+	service.now = func() time.Time { return now }
+
+	return &testServer{repo: repo, blobDir: blobDir, mux: newMux(service)}
+}
+
+// do sends a request as the given user; an empty user sends no X-User-ID.
+func (s *testServer) do(t *testing.T, method, path, user string, body any) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var buf bytes.Buffer
+	switch body := body.(type) {
+	case nil:
+	case string:
+		buf.WriteString(body)
+	default:
+		if err := json.NewEncoder(&buf).Encode(body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	req := httptest.NewRequest(method, path, &buf)
+	if user != "" {
+		req.Header.Set("X-User-ID", user)
+	}
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// add stores a task for the user directly in the repository.
+func (s *testServer) add(t *testing.T, userID uuid.UUID, task Task) Task {
+	t.Helper()
+
+	task.UserID = userID
+	if task.Status == "" {
+		task.Status = "TODO"
+	}
+	if task.Priority == "" {
+		task.Priority = "MEDIUM"
+	}
+	if task.CreationDate.IsZero() {
+		task.CreationDate = now.Add(-time.Hour)
+	}
+	if err := s.repo.Create(context.Background(), &task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("couldn't decode response %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d (body %q)", rec.Code, want, rec.Body.String())
+	}
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// TestClientRoutes keeps the routes the Go client in /client calls served.
+func TestClientRoutes(t *testing.T) {
+	mux := newMux(NewTaskService(NewMemoryTaskRepository(), nil))
+
+	for _, route := range []string{
+		"GET /api/tasks/read",
+		"GET /api/tasks/read/{id}",
+		"POST /api/tasks/create",
+		"POST /api/tasks/quick",
+		"PUT /api/tasks/update/{id}",
+		"DELETE /api/tasks/delete/{id}",
+		"PUT /api/tasks/archive/{id}",
+		"PUT /api/tasks/unarchive/{id}",
+	} {
+		method, path, _ := strings.Cut(route, " ")
+		req := httptest.NewRequest(method, strings.ReplaceAll(path, "{id}", uuid.NewString()), nil)
+		if _, pattern := mux.Handler(req); pattern != route {
+			t.Errorf("%s routed to %q", route, pattern)
+		}
+	}
+}
+
+func TestTaskHandlersRequireUser(t *testing.T) {
+	s := newTestServer(t)
+	id := uuid.NewString()
+
+	routes := []struct{ method, path string }{
+		{"POST", "/api/tasks/create"},
+		{"POST", "/api/tasks/quick"},
+		{"PUT", "/api/tasks/update/" + id},
+		{"DELETE", "/api/tasks/delete/" + id},
+		{"GET", "/api/tasks/read"},
+		{"GET", "/api/tasks/read/" + id},
+		{"PUT", "/api/tasks/archive/" + id},
+		{"PUT", "/api/tasks/unarchive/" + id},
+	}
+	body := TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW"}
+
+	for _, route := range routes {
+		t.Run(route.method+" "+route.path, func(t *testing.T) {
+			expectStatus(t, s.do(t, route.method, route.path, "", body), http.StatusUnauthorized)
+			expectStatus(t, s.do(t, route.method, route.path, "not-a-uuid", body), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestCreateTask(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   any
+		status int
+		check  func(t *testing.T, task Task)
+	}{
+		{
+			name:   "timed deadline",
+			body:   TaskRequest{Title: "Write report", Description: "Q3", Status: "TODO", Priority: "HIGH", Deadline: ptr("2026-10-20T17:00:00Z"), Labels: []string{"#Work", "work", "urgent"}, Project: ptr(" Finance ")},
+			status: http.StatusCreated,
+			check: func(t *testing.T, task Task) {
+				if task.Title != "Write report" || task.Priority != "HIGH" || task.AllDay {
+					t.Errorf("task = %+v", task)
+				}
+				if task.Deadline == nil || !task.Deadline.Equal(time.Date(2026, 10, 20, 17, 0, 0, 0, time.UTC)) {
+					t.Errorf("deadline = %v", task.Deadline)
+				}
+				if want := []string{"work", "urgent"}; !reflect.DeepEqual([]string(task.Labels), want) {
+					t.Errorf("labels = %v, want %v", task.Labels, want)
+				}
+				if task.Project != "Finance" {
+					t.Errorf("project = %q", task.Project)
+				}
+				if task.UserID != ana || task.TaskID == uuid.Nil {
+					t.Errorf("ids = %s, %s", task.UserID, task.TaskID)
+				}
+			},
+		},
+		{
+			name:   "all-day deadline",
+			body:   TaskRequest{Title: "Pay rent", Status: "TODO", Priority: "LOW", Deadline: ptr("2026-10-20")},
+			status: http.StatusCreated,
+			check: func(t *testing.T, task Task) {
+				if !task.AllDay || task.Deadline == nil || !task.Deadline.Equal(time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)) {
+					t.Errorf("deadline = %v, all day %v", task.Deadline, task.AllDay)
+				}
+			},
+		},
+		{
+			name:   "created in progress",
+			body:   TaskRequest{Title: "Already on it", Status: "IN_PROGRESS", Priority: "LOW"},
+			status: http.StatusCreated,
+			check: func(t *testing.T, task Task) {
+				if task.StartedAt == nil || !task.StartedAt.Equal(now) || task.CompletedAt != nil {
+					t.Errorf("started %v, completed %v", task.StartedAt, task.CompletedAt)
+				}
+			},
+		},
+		{name: "malformed body", body: "{", status: http.StatusBadRequest},
+		{name: "invalid deadline", body: TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Deadline: ptr("next tuesday")}, status: http.StatusBadRequest},
+		{name: "negative estimate", body: TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", EstimateMinutes: ptr(-5)}, status: http.StatusBadRequest},
+		{name: "invalid label", body: TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Labels: []string{"two words"}}, status: http.StatusBadRequest},
+		{name: "project too long", body: TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Project: ptr(strings.Repeat("p", 51))}, status: http.StatusBadRequest},
+		{name: "title too long", body: TaskRequest{Title: strings.Repeat("é", maxTitleLength+1), Status: "TODO", Priority: "LOW"}, status: http.StatusBadRequest},
+		{name: "longest title", body: TaskRequest{Title: strings.Repeat("é", maxTitleLength), Status: "TODO", Priority: "LOW"}, status: http.StatusCreated, check: func(t *testing.T, task Task) {}},
+		{name: "missing status", body: TaskRequest{Title: "Task", Priority: "LOW"}, status: http.StatusBadRequest},
+		{name: "invalid status", body: TaskRequest{Title: "Task", Status: "BLOCKED", Priority: "LOW"}, status: http.StatusBadRequest},
+		{name: "invalid priority", body: TaskRequest{Title: "Task", Status: "TODO", Priority: "urgent"}, status: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestServer(t)
+			rec := s.do(t, "POST", "/api/tasks/create", ana.String(), tt.body)
+			expectStatus(t, rec, tt.status)
+			if tt.check == nil {
+				return
+			}
+
+			created := decode[Task](t, rec)
+			tt.check(t, created)
+
+			stored, err := s.repo.Get(context.Background(), ana, created.TaskID)
+			if err != nil {
+				t.Fatalf("created task wasn't stored: %v", err)
+			}
+			if stored.Title != created.Title {
+				t.Errorf("stored title = %q, want %q", stored.Title, created.Title)
+			}
+		})
+	}
+}
+
+func TestGetTask(t *testing.T) {
+	s := newTestServer(t)
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.repo.SetUserPrefs(ana, UserPrefs{Location: lisbon, WeekStart: time.Monday})
+
+	overdue := s.add(t, ana, Task{Title: "Late", Deadline: ptr(now.Add(-time.Hour))})
+	s.repo.SetTrackedSeconds(overdue.TaskID, 90)
+	theirs := s.add(t, bob, Task{Title: "Bob's"})
+
+	rec := s.do(t, "GET", "/api/tasks/read/"+overdue.TaskID.String(), ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	got := decode[Task](t, rec)
+	if got.TaskID != overdue.TaskID || !got.IsOverdue || got.TrackedSeconds != 90 {
+		t.Errorf("task = %+v", got)
+	}
+
+	for name, id := range map[string]string{
+		"another user's task": theirs.TaskID.String(),
+		"unknown task":        uuid.NewString(),
+		"malformed id":        "42",
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", "/api/tasks/read/"+id, ana.String(), nil), http.StatusNotFound)
+		})
+	}
+}
+
+func TestListTasks(t *testing.T) {
+	s := newTestServer(t)
+	day := func(d int) *time.Time { return ptr(time.Date(2026, time.October, d, 0, 0, 0, 0, time.UTC)) }
+
+	s.add(t, ana, Task{Title: "overdue", Priority: "HIGH", Deadline: ptr(now.Add(-26 * time.Hour)), Labels: []string{"work"}})
+	s.add(t, ana, Task{Title: "today", Priority: "LOW", Deadline: day(14), AllDay: true})
+	s.add(t, ana, Task{Title: "friday", Status: "IN_PROGRESS", Deadline: day(16), AllDay: true, Labels: []string{"work", "home"}})
+	s.add(t, ana, Task{Title: "someday", Status: "DONE", CreationDate: now.Add(-3 * time.Hour)})
+	s.add(t, ana, Task{Title: "archived", ArchivedAt: ptr(now.Add(-24 * time.Hour))})
+	s.add(t, bob, Task{Title: "bob's"})
+
+	tests := []struct {
+		query  string
+		titles []string
+		total  int64
+	}{
+		{"sort=deadline", []string{"overdue", "today", "friday", "someday"}, 4},
+		{"sort=deadline&order=desc", []string{"someday", "friday", "today", "overdue"}, 4},
+		{"sort=priority&order=desc&status=TODO", []string{"overdue", "today"}, 2},
+		{"sort=deadline&priority=MEDIUM", []string{"friday", "someday"}, 2},
+		{"sort=deadline&label=WORK", []string{"overdue", "friday"}, 2},
+		{"due=overdue", []string{"overdue"}, 1},
+		{"due=today", []string{"today"}, 1},
+		{"due=this_week&sort=deadline", []string{"overdue", "today", "friday"}, 3},
+		{"due=none&archive=true&sort=creation_date", []string{"someday", "archived"}, 2},
+		{"archive=only", []string{"archived"}, 1},
+		{"sort=deadline&limit=2&page=2", []string{"friday", "someday"}, 4},
+		{"sort=deadline&limit=2&page=3", nil, 4},
+		// Unknown sort columns are ignored
+		{"sort=title;drop&status=IN_PROGRESS", []string{"friday"}, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			rec := s.do(t, "GET", "/api/tasks/read?"+tt.query, ana.String(), nil)
+			expectStatus(t, rec, http.StatusOK)
+
+			page := decode[TaskPage](t, rec)
+			var titles []string
+			for _, task := range page.Tasks {
+				titles = append(titles, task.Title)
+			}
+			if !reflect.DeepEqual(titles, tt.titles) || page.Total != tt.total {
+				t.Errorf("got %v (total %d), want %v (total %d)", titles, page.Total, tt.titles, tt.total)
+			}
+		})
+	}
+
+	for _, query := range []string{"due=tomorrow", "archive=maybe"} {
+		t.Run(query, func(t *testing.T) {
+			expectStatus(t, s.do(t, "GET", "/api/tasks/read?"+query, ana.String(), nil), http.StatusBadRequest)
+		})
+	}
+}
+
+func TestUpdateTask(t *testing.T) {
+	s := newTestServer(t)
+	task := s.add(t, ana, Task{Title: "Draft", Labels: []string{"work"}})
+	path := "/api/tasks/update/" + task.TaskID.String()
+
+	steps := []struct {
+		req                TaskRequest
+		started, completed bool
+		labels             []string
+		project            string
+	}{
+		{TaskRequest{Title: "Draft", Status: "IN_PROGRESS", Priority: "MEDIUM", Project: ptr("Website")}, true, false, []string{"work"}, "Website"},
+		{TaskRequest{Title: "Final", Status: "DONE", Priority: "HIGH", Labels: []string{"Done"}}, true, true, []string{"done"}, "Website"},
+		{TaskRequest{Title: "Final", Status: "TODO", Priority: "HIGH", Labels: []string{}, Project: ptr("")}, false, false, []string{}, ""},
+	}
+	for _, step := range steps {
+		rec := s.do(t, "PUT", path, ana.String(), step.req)
+		expectStatus(t, rec, http.StatusOK)
+
+		got := decode[Task](t, rec)
+		if got.Title != step.req.Title || got.Status != step.req.Status || got.Priority != step.req.Priority {
+			t.Errorf("%s: task = %+v", step.req.Status, got)
+		}
+		if (got.StartedAt != nil) != step.started || (got.CompletedAt != nil) != step.completed {
+			t.Errorf("%s: started %v, completed %v", step.req.Status, got.StartedAt, got.CompletedAt)
+		}
+		if !reflect.DeepEqual([]string(got.Labels), step.labels) {
+			t.Errorf("%s: labels = %v, want %v", step.req.Status, got.Labels, step.labels)
+		}
+		if got.Project != step.project {
+			t.Errorf("%s: project = %q, want %q", step.req.Status, got.Project, step.project)
+		}
+	}
+
+	stored, err := s.repo.Get(context.Background(), ana, task.TaskID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Title != "Final" || stored.Status != "TODO" {
+		t.Errorf("stored task = %+v", stored)
+	}
+
+	valid := TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW"}
+	errorCases := []struct {
+		name   string
+		user   uuid.UUID
+		path   string
+		body   any
+		status int
+	}{
+		{"another user's task", bob, path, valid, http.StatusNotFound},
+		{"unknown task", ana, "/api/tasks/update/" + uuid.NewString(), valid, http.StatusNotFound},
+		{"malformed body", ana, path, "[]", http.StatusBadRequest},
+		{"invalid deadline", ana, path, TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Deadline: ptr("31/10/2026")}, http.StatusBadRequest},
+		{"negative estimate", ana, path, TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", EstimatePoints: ptr(-1)}, http.StatusBadRequest},
+		{"invalid label", ana, path, TaskRequest{Title: "Task", Status: "TODO", Priority: "LOW", Labels: []string{"a/b"}}, http.StatusBadRequest},
+		{"title too long", ana, path, TaskRequest{Title: strings.Repeat("t", maxTitleLength+1), Status: "TODO", Priority: "LOW"}, http.StatusBadRequest},
+		{"invalid status", ana, path, TaskRequest{Title: "Task", Status: "done", Priority: "LOW"}, http.StatusBadRequest},
+		{"invalid priority", ana, path, TaskRequest{Title: "Task", Status: "TODO"}, http.StatusBadRequest},
+	}
+	for _, tt := range errorCases {
+		t.Run(tt.name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "PUT", tt.path, tt.user.String(), tt.body), tt.status)
+		})
+	}
+}
+
+func TestDeleteTask(t *testing.T) {
+	s := newTestServer(t)
+	task := s.add(t, ana, Task{Title: "With attachment"})
+
+	blobs, err := NewLocalBlobStore(s.blobDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := "attachments/" + task.TaskID.String() + "/notes.txt"
+	if err := blobs.Put(context.Background(), key, strings.NewReader("notes"), 5, "text/plain"); err != nil {
+		t.Fatal(err)
+	}
+	s.repo.AddAttachment(Attachment{AttachmentID: uuid.New(), TaskID: task.TaskID, UserID: ana, StorageKey: key})
+
+	path := "/api/tasks/delete/" + task.TaskID.String()
+	expectStatus(t, s.do(t, "DELETE", path, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusOK)
+
+	if _, err := os.Stat(filepath.Join(s.blobDir, filepath.FromSlash(key))); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("attachment blob wasn't deleted: %v", err)
+	}
+	expectStatus(t, s.do(t, "GET", "/api/tasks/read/"+task.TaskID.String(), ana.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "DELETE", path, ana.String(), nil), http.StatusNotFound)
+}
+
+func TestArchiveTask(t *testing.T) {
+	s := newTestServer(t)
+	task := s.add(t, ana, Task{Title: "Old news", Status: "DONE"})
+	id := task.TaskID.String()
+
+	listed := func() int64 {
+		rec := s.do(t, "GET", "/api/tasks/read", ana.String(), nil)
+		expectStatus(t, rec, http.StatusOK)
+		return decode[TaskPage](t, rec).Total
+	}
+
+	rec := s.do(t, "PUT", "/api/tasks/archive/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Task](t, rec); got.ArchivedAt == nil || !got.ArchivedAt.Equal(now) {
+		t.Errorf("archived_at = %v, want %v", got.ArchivedAt, now)
+	}
+	if n := listed(); n != 0 {
+		t.Errorf("archived task is still listed")
+	}
+
+	// Archiving again keeps the original time
+	s.repo.SetArchivedAt(context.Background(), ana, task.TaskID, ptr(now.Add(-time.Hour)))
+	rec = s.do(t, "PUT", "/api/tasks/archive/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Task](t, rec); got.ArchivedAt == nil || !got.ArchivedAt.Equal(now.Add(-time.Hour)) {
+		t.Errorf("archived_at = %v after archiving twice", got.ArchivedAt)
+	}
+
+	rec = s.do(t, "PUT", "/api/tasks/unarchive/"+id, ana.String(), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if got := decode[Task](t, rec); got.ArchivedAt != nil {
+		t.Errorf("archived_at = %v after unarchiving", got.ArchivedAt)
+	}
+	if n := listed(); n != 1 {
+		t.Errorf("unarchived task isn't listed")
+	}
+
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/archive/"+id, bob.String(), nil), http.StatusNotFound)
+	expectStatus(t, s.do(t, "PUT", "/api/tasks/unarchive/"+uuid.NewString(), ana.String(), nil), http.StatusNotFound)
+}
+
+func TestQuickAddTask(t *testing.T) {
+	s := newTestServer(t)
+	lisbon, err := time.LoadLocation("Europe/Lisbon")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.repo.SetUserPrefs(ana, UserPrefs{Location: lisbon, WeekStart: time.Monday})
+
+	rec := s.do(t, "POST", "/api/tasks/quick", ana.String(), QuickAddRequest{Text: "Pay rent tomorrow !high #finance"})
+	expectStatus(t, rec, http.StatusCreated)
+	got := decode[Task](t, rec)
+	if got.Title != "Pay rent" || got.Priority != "HIGH" || got.Status != "TODO" || !got.AllDay {
+		t.Errorf("task = %+v", got)
+	}
+	if got.Deadline == nil || !got.Deadline.Equal(time.Date(2026, time.October, 15, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("deadline = %v", got.Deadline)
+	}
+	if !reflect.DeepEqual([]string(got.Labels), []string{"finance"}) {
+		t.Errorf("labels = %v", got.Labels)
+	}
+
+	// Times are read in the user's timezone
+	rec = s.do(t, "POST", "/api/tasks/quick", ana.String(), QuickAddRequest{Text: "Call mum at 18:00"})
+	expectStatus(t, rec, http.StatusCreated)
+	if got := decode[Task](t, rec); got.Deadline == nil || !got.Deadline.Equal(time.Date(2026, time.October, 14, 17, 0, 0, 0, time.UTC)) {
+		t.Errorf("deadline = %v, want 18:00 Lisbon time", got.Deadline)
+	}
+
+	for name, body := range map[string]any{
+		"empty title":    QuickAddRequest{Text: "tomorrow !low"},
+		"title too long": QuickAddRequest{Text: strings.Repeat("a", maxTitleLength+1)},
+		"malformed body": "text",
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "POST", "/api/tasks/quick", ana.String(), body), http.StatusBadRequest)
+		})
+	}
+}
+
+// failingTasks is a TaskOperations whose every call fails.
+type failingTasks struct{ err error }
+
+func (f failingTasks) List(context.Context, uuid.UUID, Filters, int, int) (TaskPage, error) {
+	return TaskPage{}, f.err
+}
+func (f failingTasks) Get(context.Context, uuid.UUID, uuid.UUID) (Task, error) { return Task{}, f.err }
+func (f failingTasks) Create(context.Context, uuid.UUID, TaskRequest) (Task, error) {
+	return Task{}, f.err
+}
+func (f failingTasks) QuickAdd(context.Context, uuid.UUID, string) (Task, error) {
+	return Task{}, f.err
+}
+func (f failingTasks) Update(context.Context, uuid.UUID, uuid.UUID, TaskRequest) (Task, error) {
+	return Task{}, f.err
+}
+func (f failingTasks) Delete(context.Context, uuid.UUID, uuid.UUID) error { return f.err }
+func (f failingTasks) SetArchived(context.Context, uuid.UUID, uuid.UUID, bool) (Task, error) {
+	return Task{}, f.err
+}
+
+func TestTaskHandlersServiceErrors(t *testing.T) {
+	id := uuid.NewString()
+	routes := []struct{ method, path string }{
+		{"POST", "/api/tasks/create"},
+		{"POST", "/api/tasks/quick"},
+		{"PUT", "/api/tasks/update/" + id},
+		{"DELETE", "/api/tasks/delete/" + id},
+		{"GET", "/api/tasks/read"},
+		{"GET", "/api/tasks/read/" + id},
+		{"PUT", "/api/tasks/archive/" + id},
+		{"PUT", "/api/tasks/unarchive/" + id},
+	}
+
+	errs := []struct {
+		err    error
+		status int
+	}{
+		{errors.New("connection refused"), http.StatusInternalServerError},
+		{errTaskNotFound, http.StatusNotFound},
+		{inputError("Invalid due filter"), http.StatusBadRequest},
+	}
+
+	for _, e := range errs {
+		s := &testServer{mux: http.NewServeMux()}
+		(&taskHandlers{tasks: failingTasks{e.err}}).register(s.mux)
+		for _, route := range routes {
+			t.Run(e.err.Error()+"/"+route.method+" "+route.path, func(t *testing.T) {
+				rec := s.do(t, route.method, route.path, ana.String(), TaskRequest{Title: "Task"})
+				expectStatus(t, rec, e.status)
+				// Internal errors are logged, not shown to the client
+				if e.status == http.StatusInternalServerError && strings.Contains(rec.Body.String(), e.err.Error()) {
+					t.Errorf("body = %q", rec.Body.String())
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"time"
+
+	"google.golang.org/grpc"
+)
+
+// stopGRPCServer lets in-flight RPCs finish for up to drain, then cuts off
+// the rest. Watch streams only end when their client goes away, so they are
+// usually the ones cut off.
+func stopGRPCServer(srv *grpc.Server, drain time.Duration) {
+	stopped := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(drain):
+		srv.Stop()
+	}
+}
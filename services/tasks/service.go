@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+	"unicode/utf8"
+
+	"platform/logging"
+	"tasks/quickadd"
+
+	"github.com/google/uuid"
+)
+
+// errTaskNotFound is returned for tasks that don't exist or belong to someone else.
+var errTaskNotFound = errors.New("Task not found")
+
+// inputError is a problem with the request itself; its message is meant for the client.
+type inputError string
+
+func (e inputError) Error() string { return string(e) }
+
+// writeServiceError answers a REST request with the status matching err.
+func writeServiceError(w http.ResponseWriter, r *http.Request, err error) {
+	var input inputError
+	switch {
+	case errors.As(err, &input):
+		http.Error(w, input.Error(), http.StatusBadRequest)
+	case errors.Is(err, errTaskNotFound):
+		http.Error(w, "Task not found", http.StatusNotFound)
+	default:
+		logging.RequestLogger(r).Error("Couldn't serve task request", "err", err)
+		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
+	}
+}
+
+// TaskPage is one page of a task listing.
+type TaskPage struct {
+	Tasks []Task `json:"tasks"`
+	Total int64  `json:"total"`
+}
+
+// TaskOperations is what the REST, gRPC and GraphQL transports need from the
+// task service layer. The caller's user ID comes first; tasks of other users
+// are errTaskNotFound.
+type TaskOperations interface {
+	// List returns up to limit of the user's tasks matching filters, skipping the first offset.
+	List(ctx context.Context, userID uuid.UUID, filters Filters, offset, limit int) (TaskPage, error)
+	Get(ctx context.Context, userID, taskID uuid.UUID) (Task, error)
+	Create(ctx context.Context, userID uuid.UUID, req TaskRequest) (Task, error)
+	// QuickAdd parses a quick-add line in the user's timezone and creates the task.
+	QuickAdd(ctx context.Context, userID uuid.UUID, text string) (Task, error)
+	// Update replaces the fields of a task with req. Labels and the project
+	// are left alone when req doesn't mention them.
+	Update(ctx context.Context, userID, taskID uuid.UUID, req TaskRequest) (Task, error)
+	// Delete deletes a task along with its attachments.
+	Delete(ctx context.Context, userID, taskID uuid.UUID) error
+	SetArchived(ctx context.Context, userID, taskID uuid.UUID, archive bool) (Task, error)
+}
+
+// TaskService holds the rules for tasks: validation, deadline parsing, the
+// status timestamps and the fields derived per request. Storage is left to
+// the repository.
+type TaskService struct {
+	repo  TaskRepository
+	blobs BlobStore
+	now   func() time.Time
+}
+
+func NewTaskService(repo TaskRepository, blobs BlobStore) *TaskService {
+	return &TaskService{repo: repo, blobs: blobs, now: time.Now}
+}
+
+// decorate fills in the fields derived per request: whether a task is
+// overdue for the user and how much time has been tracked against it.
+func (s *TaskService) decorate(ctx context.Context, prefs UserPrefs, now time.Time, tasks []Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	taskIDs := make([]uuid.UUID, len(tasks))
+	for i := range tasks {
+		taskIDs[i] = tasks[i].TaskID
+	}
+	totals, err := s.repo.TrackedSeconds(ctx, taskIDs, now)
+	if err != nil {
+		return fmt.Errorf("couldn't sum tracked time: %w", err)
+	}
+
+	for i := range tasks {
+		tasks[i].IsOverdue = isOverdue(tasks[i], now, prefs)
+		tasks[i].TrackedSeconds = totals[tasks[i].TaskID]
+	}
+	return nil
+}
+
+func (s *TaskService) List(ctx context.Context, userID uuid.UUID, filters Filters, offset, limit int) (TaskPage, error) {
+	switch filters.Archive {
+	case "", "false", "true", "only":
+	default:
+		return TaskPage{}, inputError("Invalid archive filter")
+	}
+	if filters.Due != "" && !validDue[filters.Due] {
+		return TaskPage{}, inputError("Invalid due filter")
+	}
+	// Unknown sort columns are ignored rather than rejected
+	if !validSort[filters.Sort] {
+		filters.Sort = ""
+	}
+
+	prefs, err := s.repo.UserPrefs(ctx, userID)
+	if err != nil {
+		return TaskPage{}, fmt.Errorf("couldn't load user preferences: %w", err)
+	}
+	now := s.now()
+
+	tasks, total, err := s.repo.List(ctx, userID, filters, prefs, now, offset, limit)
+	if err != nil {
+		return TaskPage{}, err
+	}
+
+	if err := s.decorate(ctx, prefs, now, tasks); err != nil {
+		return TaskPage{}, err
+	}
+	return TaskPage{Tasks: tasks, Total: total}, nil
+}
+
+func (s *TaskService) Get(ctx context.Context, userID, taskID uuid.UUID) (Task, error) {
+	task, err := s.repo.Get(ctx, userID, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	prefs, err := s.repo.UserPrefs(ctx, userID)
+	if err != nil {
+		return Task{}, fmt.Errorf("couldn't load user preferences: %w", err)
+	}
+
+	tasks := []Task{task}
+	if err := s.decorate(ctx, prefs, s.now(), tasks); err != nil {
+		return Task{}, err
+	}
+	return tasks[0], nil
+}
+
+func (s *TaskService) Create(ctx context.Context, userID uuid.UUID, req TaskRequest) (Task, error) {
+	task, err := buildTask(userID, req, s.now())
+	if err != nil {
+		return Task{}, inputError(err.Error())
+	}
+
+	if err := s.repo.Create(ctx, &task); err != nil {
+		return Task{}, fmt.Errorf("couldn't create task: %w", err)
+	}
+	source := req.Source
+	if source == "" {
+		source = "form"
+	}
+	tasksCreated.WithLabelValues(source).Inc()
+	return task, nil
+}
+
+func (s *TaskService) QuickAdd(ctx context.Context, userID uuid.UUID, text string) (Task, error) {
+	prefs, err := s.repo.UserPrefs(ctx, userID)
+	if err != nil {
+		return Task{}, fmt.Errorf("couldn't load user preferences: %w", err)
+	}
+
+	now := s.now()
+	parsed, err := quickadd.Parse(text, now.In(prefs.Location))
+	if err != nil {
+		if errors.Is(err, quickadd.ErrEmptyTitle) {
+			return Task{}, inputError("Title must not be empty")
+		}
+		return Task{}, inputError(err.Error())
+	}
+	if utf8.RuneCountInString(parsed.Title) > maxTitleLength {
+		return Task{}, inputError(fmt.Sprintf("Title is longer than %d characters", maxTitleLength))
+	}
+
+	task, err := buildTask(userID, quickAddRequest(parsed), now)
+	if err != nil {
+		return Task{}, inputError(err.Error())
+	}
+
+	if err := s.repo.Create(ctx, &task); err != nil {
+		return Task{}, fmt.Errorf("couldn't quick-add task: %w", err)
+	}
+	tasksCreated.WithLabelValues("quick_add").Inc()
+	return task, nil
+}
+
+func (s *TaskService) Update(ctx context.Context, userID, taskID uuid.UUID, req TaskRequest) (Task, error) {
+	existingTask, err := s.repo.Get(ctx, userID, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if err := validateTaskFields(req); err != nil {
+		return Task{}, inputError(err.Error())
+	}
+
+	parsedDeadline, allDay, err := parseDeadline(req)
+	if err != nil {
+		return Task{}, inputError("Invalid date format")
+	}
+
+	if !validEstimates(req) {
+		return Task{}, inputError("Estimates must not be negative")
+	}
+
+	if req.Labels != nil {
+		labels, err := normalizeLabels(req.Labels)
+		if err != nil {
+			return Task{}, inputError(err.Error())
+		}
+		existingTask.Labels = labels
+	}
+
+	if req.Project != nil {
+		project, err := normalizeProject(req.Project)
+		if err != nil {
+			return Task{}, inputError(err.Error())
+		}
+		existingTask.Project = project
+	}
+
+	// Track when work on a task started and when it was finished, for the
+	// statistics and so it can be auto-archived later. Going back to TODO
+	// starts the clock over.
+	now := s.now().Truncate(time.Microsecond)
+	if req.Status == "IN_PROGRESS" && existingTask.StartedAt == nil {
+		existingTask.StartedAt = &now
+	} else if req.Status == "TODO" {
+		existingTask.StartedAt = nil
+	}
+	completed := req.Status == "DONE" && existingTask.Status != "DONE"
+	if completed {
+		existingTask.CompletedAt = &now
+	} else if req.Status != "DONE" {
+		existingTask.CompletedAt = nil
+	}
+
+	existingTask.Title = req.Title
+	existingTask.Description = req.Description
+	existingTask.Status = req.Status
+	existingTask.Priority = req.Priority
+	existingTask.Deadline = parsedDeadline
+	existingTask.AllDay = allDay
+	existingTask.EstimateMinutes = req.EstimateMinutes
+	existingTask.EstimatePoints = req.EstimatePoints
+
+	if err := s.repo.Save(ctx, &existingTask); err != nil {
+		return Task{}, fmt.Errorf("failed to update task: %w", err)
+	}
+	if completed {
+		tasksCompleted.Inc()
+	}
+	return existingTask, nil
+}
+
+func (s *TaskService) Delete(ctx context.Context, userID, taskID uuid.UUID) error {
+	storageKeys, err := s.repo.Delete(ctx, userID, taskID)
+	if err != nil {
+		return err
+	}
+
+	for _, key := range storageKeys {
+		if err := s.blobs.Delete(ctx, key); err != nil {
+			logging.ContextLogger(ctx).Error("Couldn't delete attachment blob", "key", key, "err", err)
+		}
+	}
+	return nil
+}
+
+func (s *TaskService) SetArchived(ctx context.Context, userID, taskID uuid.UUID, archive bool) (Task, error) {
+	task, err := s.repo.Get(ctx, userID, taskID)
+	if err != nil {
+		return Task{}, err
+	}
+
+	if archive && task.ArchivedAt == nil {
+		now := s.now().Truncate(time.Microsecond)
+		task.ArchivedAt = &now
+	} else if !archive {
+		task.ArchivedAt = nil
+	}
+
+	if err := s.repo.SetArchivedAt(ctx, userID, taskID, task.ArchivedAt); err != nil {
+		return Task{}, fmt.Errorf("failed to update task: %w", err)
+	}
+	return task, nil
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"platform/logging"
+)
+
+// maxStatsDays bounds the window completions are reported over.
+const maxStatsDays = 366
+
+// completionStreaks returns the current and longest runs of consecutive days
+// in days, which must be sorted and distinct. The current streak survives
+// until the end of today, so it still counts if nothing is done yet today.
+func completionStreaks(days []time.Time, today time.Time) (current, longest int) {
+	run := 0
+	for i, day := range days {
+		if i > 0 && day.Sub(days[i-1]) == 24*time.Hour {
+			run++
+		} else {
+			run = 1
+		}
+		longest = max(longest, run)
+	}
+
+	if n := len(days); n > 0 {
+		last := days[n-1]
+		if last.Equal(today) || last.Equal(today.AddDate(0, 0, -1)) {
+			current = run
+		}
+	}
+	return current, longest
+}
+
+// countBy counts the user's unarchived tasks grouped by column.
+func countBy(ctx context.Context, userID, column string) (map[string]int64, error) {
+	var rows []struct {
+		Key   string
+		Count int64
+	}
+	if err := db.WithContext(ctx).Model(&Task{}).
+		Select(column+" AS key, COUNT(*) AS count").
+		Where("user_id = ? AND archived_at IS NULL", userID).
+		Group(column).
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.Key] = row.Count
+	}
+	return counts, nil
+}
+
+// @Summary Task statistics
+// @Description Counts of unarchived tasks by status and priority, the overdue count, tasks completed per day over a window with average cycle time (creation to done) and active time (started to done), and completion streaks. Days are calendar days in the user's timezone.
+// @Tags Tasks
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param from query string false "First day of the window (YYYY-MM-DD); defaults to 29 days before today"
+// @Param to query string false "Last day of the window (YYYY-MM-DD); defaults to today"
+// @Success 200 {object} TaskStats
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/stats [get]
+func handleGetStats(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	prefs, err := loadUserPrefs(userID)
+	if err != nil {
+		logging.RequestLogger(r).Error("Couldn't load user preferences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	today := calendarDay(now, prefs.Location)
+	from, to, err := parseDayRange(r.URL.Query(), today.AddDate(0, 0, -29), today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	days := int(to.Sub(from).Hours()/24) + 1
+	if days > maxStatsDays {
+		http.Error(w, "Date range too long", http.StatusBadRequest)
+		return
+	}
+
+	stats := TaskStats{
+		From:            from.Format(dateLayout),
+		To:              to.Format(dateLayout),
+		CompletedPerDay: make([]DayCount, days),
+	}
+	for i := range stats.CompletedPerDay {
+		stats.CompletedPerDay[i].Date = from.AddDate(0, 0, i).Format(dateLayout)
+	}
+
+	if stats.ByStatus, err = countBy(r.Context(), userID, "status"); err != nil {
+		logging.RequestLogger(r).Error("Couldn't count tasks by status", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if stats.ByPriority, err = countBy(r.Context(), userID, "priority"); err != nil {
+		logging.RequestLogger(r).Error("Couldn't count tasks by priority", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	overdue := applyDueFilter(db.WithContext(r.Context()).Model(&Task{}).Where("user_id = ? AND archived_at IS NULL", userID), "overdue", now, prefs)
+	if err := overdue.Count(&stats.Overdue).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't count overdue tasks", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// Archived tasks still count towards the completion history
+	var completed []Task
+	if err := db.WithContext(r.Context()).Select("creation_date", "started_at", "completed_at").
+		Where("user_id = ? AND completed_at >= ? AND completed_at < ?",
+			userID, startOfDay(from, prefs.Location), startOfDay(to.AddDate(0, 0, 1), prefs.Location)).
+		Find(&completed).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't load completed tasks", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var cycle, active float64
+	var started int
+	for _, task := range completed {
+		if i := int(calendarDay(*task.CompletedAt, prefs.Location).Sub(from).Hours() / 24); i >= 0 && i < days {
+			stats.CompletedPerDay[i].Count++
+		}
+		cycle += task.CompletedAt.Sub(task.CreationDate).Seconds()
+		if task.StartedAt != nil {
+			active += task.CompletedAt.Sub(*task.StartedAt).Seconds()
+			started++
+		}
+	}
+	stats.Completed = len(completed)
+	if stats.Completed > 0 {
+		avg := cycle / float64(stats.Completed)
+		stats.AverageCycleSeconds = &avg
+	}
+	if started > 0 {
+		avg := active / float64(started)
+		stats.AverageActiveSeconds = &avg
+	}
+
+	var completionDays []time.Time
+	if err := db.WithContext(r.Context()).Raw(`
+		SELECT DISTINCT (completed_at AT TIME ZONE ?)::date AS day
+		FROM tasks
+		WHERE user_id = ? AND completed_at IS NOT NULL
+		ORDER BY day`, prefs.Location.String(), userID).
+		Scan(&completionDays).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't load completion days", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	stats.CurrentStreak, stats.LongestStreak = completionStreaks(completionDays, today)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(stats)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+// taskChangesChannel is the Postgres channel the tasks trigger notifies on.
+const taskChangesChannel = "task_changes"
+
+// subscriberBuffer is how many changes a watcher can fall behind before it's dropped.
+const subscriberBuffer = 64
+
+// taskChange is the payload of a task_changes notification.
+type taskChange struct {
+	Op     string    `json:"op"` // INSERT, UPDATE or DELETE
+	UserID uuid.UUID `json:"user_id"`
+	TaskID uuid.UUID `json:"task_id"`
+}
+
+// taskBroker fans task changes out to the watchers of each user.
+type taskBroker struct {
+	mu   sync.Mutex
+	subs map[uuid.UUID]map[chan taskChange]bool
+}
+
+var taskEvents = &taskBroker{subs: map[uuid.UUID]map[chan taskChange]bool{}}
+
+// subscribe registers a watcher for the user's changes. The channel is closed
+// when the watcher falls too far behind or changes may have been missed, after
+// which the caller should subscribe again and reload its tasks. The returned
+// function unsubscribes.
+func (b *taskBroker) subscribe(userID uuid.UUID) (<-chan taskChange, func()) {
+	ch := make(chan taskChange, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = map[chan taskChange]bool{}
+	}
+	b.subs[userID][ch] = true
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, ch)
+	}
+}
+
+// remove drops and closes a watcher's channel unless it's already gone. b.mu must be held.
+func (b *taskBroker) remove(userID uuid.UUID, ch chan taskChange) {
+	if !b.subs[userID][ch] {
+		return
+	}
+	delete(b.subs[userID], ch)
+	if len(b.subs[userID]) == 0 {
+		delete(b.subs, userID)
+	}
+	close(ch)
+}
+
+// publish hands a change to the user's watchers without blocking on slow ones.
+func (b *taskBroker) publish(change taskChange) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.subs[change.UserID] {
+		select {
+		case ch <- change:
+		default:
+			b.remove(change.UserID, ch)
+		}
+	}
+}
+
+// reset drops every watcher, for when changes may have been missed.
+func (b *taskBroker) reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for userID, chans := range b.subs {
+		for ch := range chans {
+			b.remove(userID, ch)
+		}
+	}
+}
+
+// runTaskListener forwards task_changes notifications to taskEvents until ctx is done.
+// It holds its own connection, which pq re-establishes if it drops.
+func runTaskListener(ctx context.Context, dsn string) {
+	listener := pq.NewListener(dsn, time.Second, time.Minute, func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			slog.Error("Task change listener", "err", err)
+		}
+	})
+	defer listener.Close()
+
+	if err := listener.Listen(taskChangesChannel); err != nil {
+		slog.Error("Couldn't listen for task changes", "err", err)
+		return
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case n := <-listener.Notify:
+			// A nil notification means the connection was re-established and
+			// anything sent in the meantime is lost
+			if n == nil {
+				taskEvents.reset()
+				continue
+			}
+
+			var change taskChange
+			if err := json.Unmarshal([]byte(n.Extra), &change); err != nil {
+				slog.Error("Couldn't decode task change", "err", err)
+				continue
+			}
+			taskEvents.publish(change)
+		case <-time.After(90 * time.Second):
+			// Notice a dead connection even when no tasks are changing
+			go listener.Ping()
+		}
+	}
+}
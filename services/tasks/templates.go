@@ -0,0 +1,391 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"platform/logging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// maxTitleLength mirrors the size of Tasks.title.
+const maxTitleLength = 50
+
+var validPriorities = map[string]bool{
+	"LOW":    true,
+	"MEDIUM": true,
+	"HIGH":   true,
+}
+
+var (
+	offsetPattern      = regexp.MustCompile(`^\+(\d{1,4})([mhdw])$`)
+	placeholderPattern = regexp.MustCompile(`\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`)
+)
+
+// resolveOffset turns a relative offset such as "+3d" into a deadline. Day and week
+// offsets give an all-day deadline counted from the user's today; hour and minute
+// offsets give an exact time counted from now.
+func resolveOffset(offset string, now time.Time, loc *time.Location) (*time.Time, bool, error) {
+	if offset == "" {
+		return nil, false, nil
+	}
+
+	m := offsetPattern.FindStringSubmatch(offset)
+	if m == nil {
+		return nil, false, fmt.Errorf("invalid deadline offset %q", offset)
+	}
+	n, _ := strconv.Atoi(m[1])
+
+	var deadline time.Time
+	switch m[2] {
+	case "m":
+		deadline = now.Add(time.Duration(n) * time.Minute).Truncate(time.Microsecond)
+	case "h":
+		deadline = now.Add(time.Duration(n) * time.Hour).Truncate(time.Microsecond)
+	case "d":
+		deadline = calendarDay(now, loc).AddDate(0, 0, n)
+		return &deadline, true, nil
+	case "w":
+		deadline = calendarDay(now, loc).AddDate(0, 0, 7*n)
+		return &deadline, true, nil
+	}
+	return &deadline, false, nil
+}
+
+// taskOffset expresses a task's deadline as an offset from when the task was
+// created, so a template saved from it resolves to a deadline as far out.
+func taskOffset(task Task, loc *time.Location) string {
+	if task.Deadline == nil {
+		return ""
+	}
+	if task.AllDay {
+		days := int(deadlineDay(task, loc).Sub(calendarDay(task.CreationDate, loc)) / (24 * time.Hour))
+		return fmt.Sprintf("+%dd", min(max(days, 0), 9999))
+	}
+
+	minutes := max(int(task.Deadline.Sub(task.CreationDate).Round(time.Minute)/time.Minute), 0)
+	if minutes%60 == 0 || minutes > 9999 {
+		return fmt.Sprintf("+%dh", min((minutes+30)/60, 9999))
+	}
+	return fmt.Sprintf("+%dm", minutes)
+}
+
+// renderPlaceholders replaces {{name}} placeholders with values from vars,
+// failing on the first placeholder it has no value for.
+func renderPlaceholders(text string, vars map[string]string) (string, error) {
+	var missing string
+	out := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		name := placeholderPattern.FindStringSubmatch(match)[1]
+		if v, ok := vars[name]; ok {
+			return v
+		}
+		if missing == "" {
+			missing = name
+		}
+		return match
+	})
+	if missing != "" {
+		return "", fmt.Errorf("no value for placeholder {{%s}}", missing)
+	}
+	return out, nil
+}
+
+func validateTemplate(req TemplateRequest) error {
+	switch {
+	case strings.TrimSpace(req.Name) == "" || utf8.RuneCountInString(req.Name) > 100:
+		return errors.New("name must be between 1 and 100 characters")
+	case strings.TrimSpace(req.Title) == "" || utf8.RuneCountInString(req.Title) > 255:
+		return errors.New("title must be between 1 and 255 characters")
+	case !validPriorities[req.Priority]:
+		return errors.New("invalid priority")
+	case req.DeadlineOffset != "" && !offsetPattern.MatchString(req.DeadlineOffset):
+		return errors.New("deadline offset must look like +30m, +4h, +3d or +2w")
+	case (req.EstimateMinutes != nil && *req.EstimateMinutes < 0) || (req.EstimatePoints != nil && *req.EstimatePoints < 0):
+		return errors.New("estimates must not be negative")
+	}
+	return nil
+}
+
+// @Summary List task templates
+// @Description Retrieve the authenticated user's task templates
+// @Tags Templates
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Success 200 {array} TaskTemplate
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/templates [get]
+func handleListTemplates(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	templates := []TaskTemplate{}
+	if err := db.WithContext(r.Context()).Where("user_id = ?", userID).Order("name").Find(&templates).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(templates)
+}
+
+// @Summary Create a task template
+// @Description Save a reusable task. Title and description may contain placeholders such as {{date}}, {{weekday}}, {{time}} and {{deadline}}, or custom ones supplied at instantiation.
+// @Tags Templates
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param template body TemplateRequest true "Template details"
+// @Success 201 {object} TaskTemplate "Template created"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 409 {string} string "A template with this name already exists"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/templates/create [post]
+func handleCreateTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req TemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	saveTemplate(w, r, userID, req)
+}
+
+// @Summary Save a task as a template
+// @Description Save one of the authenticated user's tasks as a template, keeping its title, description, priority and estimates. The deadline becomes an offset from when the task was created unless deadline_offset is given.
+// @Tags Templates
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Param template body TemplateFromTaskRequest true "Template name and optional deadline offset"
+// @Success 201 {object} TaskTemplate "Template created"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 409 {string} string "A template with this name already exists"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/templates/from-task/{id} [post]
+func handleCreateTemplateFromTask(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req TemplateFromTaskRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	task, err := findUserTask(r.Context(), userID.String(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	offset := ""
+	if req.DeadlineOffset != nil {
+		offset = *req.DeadlineOffset
+	} else if task.Deadline != nil {
+		prefs, err := loadUserPrefs(userID.String())
+		if err != nil {
+			logging.RequestLogger(r).Error("Couldn't load user preferences", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		offset = taskOffset(task, prefs.Location)
+	}
+
+	saveTemplate(w, r, userID, TemplateRequest{
+		Name:            req.Name,
+		Title:           task.Title,
+		Description:     task.Description,
+		Priority:        task.Priority,
+		DeadlineOffset:  offset,
+		EstimateMinutes: task.EstimateMinutes,
+		EstimatePoints:  task.EstimatePoints,
+	})
+}
+
+// saveTemplate validates and stores a template for the user and answers with it.
+func saveTemplate(w http.ResponseWriter, r *http.Request, userID uuid.UUID, req TemplateRequest) {
+	if err := validateTemplate(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	template := TaskTemplate{
+		UserID:          userID,
+		Name:            req.Name,
+		Title:           req.Title,
+		Description:     req.Description,
+		Priority:        req.Priority,
+		DeadlineOffset:  req.DeadlineOffset,
+		EstimateMinutes: req.EstimateMinutes,
+		EstimatePoints:  req.EstimatePoints,
+	}
+	if err := db.WithContext(r.Context()).Create(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			http.Error(w, "A template with this name already exists", http.StatusConflict)
+		} else {
+			logging.RequestLogger(r).Error("Couldn't create template", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(template)
+}
+
+// @Summary Delete a task template
+// @Description Delete one of the authenticated user's task templates
+// @Tags Templates
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Template ID"
+// @Success 200 {string} string "Template deleted successfully"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Template not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/templates/delete/{id} [delete]
+func handleDeleteTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	result := db.WithContext(r.Context()).Where("user_id = ? AND template_id = ?", userID, r.PathValue("id")).Delete(&TaskTemplate{})
+	if result.Error != nil {
+		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// @Summary Create a task from a template
+// @Description Instantiate a template as a new TODO task, rendering placeholders and resolving the deadline offset in the user's timezone
+// @Tags Templates
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Template ID"
+// @Param variables body FromTemplateRequest false "Values for custom placeholders"
+// @Success 201 {object} Task "Task created successfully"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Template not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/from-template/{id} [post]
+func (h *taskHandlers) handleCreateFromTemplate(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	if _, err := uuid.Parse(r.PathValue("id")); err != nil {
+		http.Error(w, "Template not found", http.StatusNotFound)
+		return
+	}
+
+	// The body is optional, templates without custom placeholders need none
+	var req FromTemplateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	var template TaskTemplate
+	if err := db.WithContext(r.Context()).Where("user_id = ? AND template_id = ?", userID, r.PathValue("id")).First(&template).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Template not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	prefs, err := loadUserPrefs(userID)
+	if err != nil {
+		logging.RequestLogger(r).Error("Couldn't load user preferences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	deadline, allDay, err := resolveOffset(template.DeadlineOffset, now, prefs.Location)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	vars := make(map[string]string, len(req.Variables)+4)
+	for k, v := range req.Variables {
+		vars[k] = v
+	}
+	local := now.In(prefs.Location)
+	vars["date"] = local.Format(dateLayout)
+	vars["time"] = local.Format("15:04")
+	vars["weekday"] = local.Weekday().String()
+	vars["deadline"] = ""
+	if deadline != nil {
+		vars["deadline"] = deadlineDay(Task{Deadline: deadline, AllDay: allDay}, prefs.Location).Format(dateLayout)
+	}
+
+	title, err := renderPlaceholders(template.Title, vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	description, err := renderPlaceholders(template.Description, vars)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	taskReq := TaskRequest{
+		Title:           title,
+		Description:     description,
+		Status:          "TODO",
+		Priority:        template.Priority,
+		EstimateMinutes: template.EstimateMinutes,
+		EstimatePoints:  template.EstimatePoints,
+		Source:          "template",
+	}
+	if deadline != nil {
+		formatted := deadline.Format(time.RFC3339Nano)
+		if allDay {
+			formatted = deadline.Format(dateLayout)
+		}
+		taskReq.Deadline = &formatted
+	}
+	task, err := h.tasks.Create(r.Context(), requestUserID(r), taskReq)
+	if err != nil {
+		writeServiceError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(task)
+}
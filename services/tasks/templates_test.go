@@ -0,0 +1,44 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestTaskOffset(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Created late in the evening in New York, already the next day in UTC
+	created := time.Date(2025, 3, 10, 23, 30, 0, 0, newYork)
+	at := func(d time.Duration) *time.Time { deadline := created.Add(d); return &deadline }
+	day := func(y int, m time.Month, d int) *time.Time {
+		deadline := time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+		return &deadline
+	}
+
+	tests := []struct {
+		name string
+		task Task
+		want string
+	}{
+		{"no deadline", Task{}, ""},
+		{"all day", Task{Deadline: day(2025, 3, 15), AllDay: true}, "+5d"},
+		{"all day on the day it was created", Task{Deadline: day(2025, 3, 10), AllDay: true}, "+0d"},
+		{"all day in the past", Task{Deadline: day(2025, 3, 1), AllDay: true}, "+0d"},
+		{"whole hours", Task{Deadline: at(36 * time.Hour)}, "+36h"},
+		{"minutes", Task{Deadline: at(90 * time.Minute)}, "+90m"},
+		{"too many minutes", Task{Deadline: at(200*time.Hour + 20*time.Minute)}, "+200h"},
+		{"too many hours", Task{Deadline: at(20000 * time.Hour)}, "+9999h"},
+		{"already due", Task{Deadline: at(-time.Hour)}, "+0h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.task.CreationDate = created
+			if got := taskOffset(tt.task, newYork); got != tt.want {
+				t.Errorf("taskOffset() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
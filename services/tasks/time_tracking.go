@@ -0,0 +1,325 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"sort"
+	"time"
+
+	"platform/logging"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var validGroupBy = map[string]bool{
+	"day":      true,
+	"week":     true,
+	"priority": true,
+	"task":     true,
+	"project":  true,
+}
+
+// findUserTask loads a task only if it belongs to the given user. A malformed
+// task ID is reported as not found.
+func findUserTask(ctx context.Context, userID, taskID string) (Task, error) {
+	if _, err := uuid.Parse(taskID); err != nil {
+		return Task{}, gorm.ErrRecordNotFound
+	}
+
+	var task Task
+	err := db.WithContext(ctx).Where("user_id = ? AND task_id = ?", userID, taskID).First(&task).Error
+	return task, err
+}
+
+// trackedSeconds sums the time logged against each task, counting running timers up to now.
+func trackedSeconds(tx *gorm.DB, taskIDs []uuid.UUID, now time.Time) (map[uuid.UUID]int64, error) {
+	var rows []struct {
+		TaskID  uuid.UUID
+		Seconds int64
+	}
+	if err := tx.Model(&TimeEntry{}).
+		Select("task_id, CAST(SUM(EXTRACT(EPOCH FROM (COALESCE(ended_at, ?) - started_at))) AS BIGINT) AS seconds", now).
+		Where("task_id IN ?", taskIDs).
+		Group("task_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	totals := make(map[uuid.UUID]int64, len(rows))
+	for _, row := range rows {
+		totals[row.TaskID] = row.Seconds
+	}
+	return totals, nil
+}
+
+// @Summary Start a timer
+// @Description Start tracking time on a task. Only one timer can run per user at a time.
+// @Tags Time Tracking
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Success 201 {object} TimeEntry "Timer started"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 409 {string} string "A timer is already running"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/timer/start/{id} [post]
+func handleStartTimer(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	task, err := findUserTask(r.Context(), userID.String(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	entry := TimeEntry{
+		TaskID:    task.TaskID,
+		UserID:    userID,
+		StartedAt: time.Now().Truncate(time.Microsecond),
+	}
+	if err := db.WithContext(r.Context()).Create(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			http.Error(w, "A timer is already running", http.StatusConflict)
+		} else {
+			logging.RequestLogger(r).Error("Couldn't start timer", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// @Summary Stop the running timer
+// @Description Stop the authenticated user's running timer, whichever task it is on
+// @Tags Time Tracking
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Success 200 {object} TimeEntry "Timer stopped"
+// @Failure 400 {string} string "Invalid User ID"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "No running timer"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/timer/stop [post]
+func handleStopTimer(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	var entry TimeEntry
+	result := db.WithContext(r.Context()).Model(&entry).
+		Clauses(clause.Returning{}).
+		Where("user_id = ? AND ended_at IS NULL", userID).
+		Update("ended_at", time.Now().Truncate(time.Microsecond))
+	if result.Error != nil {
+		logging.RequestLogger(r).Error("Couldn't stop timer", "err", result.Error)
+		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		http.Error(w, "No running timer", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// @Summary Log time manually
+// @Description Record a finished time entry on a task, given either its end time or its duration
+// @Tags Time Tracking
+// @Accept json
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param id path string true "Task ID"
+// @Param entry body TimeEntryRequest true "Time entry"
+// @Success 201 {object} TimeEntry "Time entry created"
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 404 {string} string "Task not found"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/time/create/{id} [post]
+func handleCreateTimeEntry(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req TimeEntryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, req.StartedAt)
+	if err != nil {
+		http.Error(w, "Invalid started_at", http.StatusBadRequest)
+		return
+	}
+	startedAt = startedAt.Truncate(time.Microsecond)
+
+	var endedAt time.Time
+	switch {
+	case req.EndedAt != nil:
+		endedAt, err = time.Parse(time.RFC3339, *req.EndedAt)
+		if err != nil {
+			http.Error(w, "Invalid ended_at", http.StatusBadRequest)
+			return
+		}
+		endedAt = endedAt.Truncate(time.Microsecond)
+	case req.DurationMinutes != nil && *req.DurationMinutes > 0:
+		endedAt = startedAt.Add(time.Duration(*req.DurationMinutes) * time.Minute)
+	default:
+		http.Error(w, "Either ended_at or a positive duration_minutes is required", http.StatusBadRequest)
+		return
+	}
+	if endedAt.Before(startedAt) {
+		http.Error(w, "ended_at must not be before started_at", http.StatusBadRequest)
+		return
+	}
+
+	task, err := findUserTask(r.Context(), userID.String(), r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "Task not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	entry := TimeEntry{
+		TaskID:    task.TaskID,
+		UserID:    userID,
+		StartedAt: startedAt,
+		EndedAt:   &endedAt,
+		Note:      req.Note,
+	}
+	if err := db.WithContext(r.Context()).Create(&entry).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't create time entry", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(entry)
+}
+
+// @Summary Time report
+// @Description Aggregate tracked time by day, week, priority, task or project. Entries count towards the day they started on in the user's timezone.
+// @Tags Time Tracking
+// @Produce json
+// @Param X-User-ID header string true "User ID"
+// @Param group_by query string false "Grouping (day, week, priority, task, project); defaults to day"
+// @Param from query string false "First day (YYYY-MM-DD); defaults to six days ago"
+// @Param to query string false "Last day (YYYY-MM-DD); defaults to today"
+// @Success 200 {object} TimeReport
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized User"
+// @Failure 500 {string} string "Internal Server Error"
+// @Router /tasks/time/report [get]
+func handleTimeReport(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r).String()
+
+	qs := r.URL.Query()
+
+	groupBy := qs.Get("group_by")
+	if groupBy == "" {
+		groupBy = "day"
+	}
+	if !validGroupBy[groupBy] {
+		http.Error(w, "Invalid group_by", http.StatusBadRequest)
+		return
+	}
+
+	prefs, err := loadUserPrefs(userID)
+	if err != nil {
+		logging.RequestLogger(r).Error("Couldn't load user preferences", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	now := time.Now()
+	today := calendarDay(now, prefs.Location)
+	from, to, err := parseDayRange(qs, today.AddDate(0, 0, -6), today)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var rows []struct {
+		TaskID    uuid.UUID
+		Title     string
+		Priority  string
+		Project   string
+		StartedAt time.Time
+		EndedAt   *time.Time
+	}
+	if err := db.WithContext(r.Context()).Table("time_entries").
+		Select("time_entries.task_id, tasks.title, tasks.priority, tasks.project, time_entries.started_at, time_entries.ended_at").
+		Joins("JOIN tasks ON tasks.task_id = time_entries.task_id").
+		Where("time_entries.user_id = ? AND time_entries.started_at >= ? AND time_entries.started_at < ?",
+			userID, startOfDay(from, prefs.Location), startOfDay(to.AddDate(0, 0, 1), prefs.Location)).
+		Scan(&rows).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't load time entries", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	report := TimeReport{
+		GroupBy: groupBy,
+		From:    from.Format(dateLayout),
+		To:      to.Format(dateLayout),
+		Buckets: []TimeReportBucket{},
+	}
+	index := map[string]int{}
+	for _, row := range rows {
+		end := now
+		if row.EndedAt != nil {
+			end = *row.EndedAt
+		}
+		seconds := int64(end.Sub(row.StartedAt).Seconds())
+
+		var key, label string
+		switch groupBy {
+		case "day":
+			key = calendarDay(row.StartedAt, prefs.Location).Format(dateLayout)
+		case "week":
+			key = weekStart(calendarDay(row.StartedAt, prefs.Location), prefs.WeekStart).Format(dateLayout)
+		case "priority":
+			key = row.Priority
+		case "task":
+			key, label = row.TaskID.String(), row.Title
+		case "project":
+			key = row.Project
+			if key == "" {
+				label = "No project"
+			}
+		}
+
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Buckets)
+			index[key] = i
+			report.Buckets = append(report.Buckets, TimeReportBucket{Key: key, Label: label})
+		}
+		report.Buckets[i].Seconds += seconds
+		report.TotalSeconds += seconds
+	}
+	sort.Slice(report.Buckets, func(i, j int) bool {
+		return report.Buckets[i].Key < report.Buckets[j].Key
+	})
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(report)
+}
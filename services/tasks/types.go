@@ -10,8 +10,40 @@ type TaskRequest struct {
 	Title       string  `json:"title"`
 	Description string  `json:"description"`
 	Deadline    *string `json:"deadline"`
+	AllDay      *bool   `json:"all_day"`
 	Status      string  `json:"status"`
 	Priority    string  `json:"priority"`
+
+	EstimateMinutes *int `json:"estimate_minutes"`
+	EstimatePoints  *int `json:"estimate_points"`
+
+	Labels []string `json:"labels"`
+	// Project is left alone on update when omitted, an empty one clears it
+	Project *string `json:"project"`
+
+	// Source is how the task was entered, for tasknest_tasks_created_total:
+	// "form" when empty, or "template". Clients can't set it.
+	Source string `json:"-"`
+}
+
+type QuickAddRequest struct {
+	Text string `json:"text" example:"Pay rent tomorrow !high #finance"`
+}
+
+type GraphQLRequest struct {
+	Query         string         `json:"query" example:"{ tasks(first: 5) { edges { node { id title comments { body } } } } }"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+type GraphQLError struct {
+	Message string `json:"message"`
+	Path    []any  `json:"path,omitempty"`
+}
+
+type GraphQLResponse struct {
+	Data   any            `json:"data"`
+	Errors []GraphQLError `json:"errors,omitempty"`
 }
 
 type Filters struct {
@@ -19,4 +51,93 @@ type Filters struct {
 	Priority string
 	Sort     string
 	Order    string
+	Due      string
+	Archive  string
+	Label    string
+}
+
+type TimeEntryRequest struct {
+	StartedAt       string  `json:"started_at"`
+	EndedAt         *string `json:"ended_at"`
+	DurationMinutes *int    `json:"duration_minutes"`
+	Note            string  `json:"note"`
+}
+
+type TimeReportBucket struct {
+	Key     string `json:"key"`
+	Label   string `json:"label,omitempty"`
+	Seconds int64  `json:"seconds"`
+}
+
+type TimeReport struct {
+	GroupBy      string             `json:"group_by"`
+	From         string             `json:"from"`
+	To           string             `json:"to"`
+	Buckets      []TimeReportBucket `json:"buckets"`
+	TotalSeconds int64              `json:"total_seconds"`
+}
+
+type CapacityDay struct {
+	Date            string `json:"date"`
+	Tasks           int    `json:"tasks"`
+	Unestimated     int    `json:"unestimated"`
+	EstimateMinutes int    `json:"estimate_minutes"`
+	EstimatePoints  int    `json:"estimate_points"`
+	Overloaded      bool   `json:"overloaded"`
+}
+
+type CapacitySummary struct {
+	From            string        `json:"from"`
+	To              string        `json:"to"`
+	CapacityMinutes int           `json:"capacity_minutes"`
+	CapacityPoints  int           `json:"capacity_points"`
+	Days            []CapacityDay `json:"days"`
+}
+
+type CommentRequest struct {
+	Body     string  `json:"body"`
+	ParentID *string `json:"parent_id"`
+}
+
+type TemplateRequest struct {
+	Name            string `json:"name"`
+	Title           string `json:"title"`
+	Description     string `json:"description"`
+	Priority        string `json:"priority"`
+	DeadlineOffset  string `json:"deadline_offset"`
+	EstimateMinutes *int   `json:"estimate_minutes"`
+	EstimatePoints  *int   `json:"estimate_points"`
+}
+
+type TemplateFromTaskRequest struct {
+	Name string `json:"name"`
+	// DeadlineOffset replaces the offset worked out from the task's deadline
+	DeadlineOffset *string `json:"deadline_offset"`
+}
+
+type FromTemplateRequest struct {
+	Variables map[string]string `json:"variables"`
+}
+
+type DayCount struct {
+	Date  string `json:"date"`
+	Count int    `json:"count"`
+}
+
+type TaskStats struct {
+	From            string           `json:"from"`
+	To              string           `json:"to"`
+	ByStatus        map[string]int64 `json:"by_status"`
+	ByPriority      map[string]int64 `json:"by_priority"`
+	Overdue         int64            `json:"overdue"`
+	CompletedPerDay []DayCount       `json:"completed_per_day"`
+	Completed       int              `json:"completed"`
+
+	// Averages over the tasks completed in the window; null when there are none.
+	AverageCycleSeconds  *float64 `json:"average_cycle_seconds"`
+	AverageActiveSeconds *float64 `json:"average_active_seconds"`
+
+	// Consecutive days with at least one completed task
+	CurrentStreak int `json:"current_streak"`
+	LongestStreak int `json:"longest_streak"`
 }
@@ -3,7 +3,8 @@ package main
 import (
 	"context"
 	"fmt"
-	"log"
+
+	"platform/logging"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
@@ -17,7 +18,7 @@ func getParameter(ssmClient *ssm.Client, parameterName string, ctx context.Conte
 		WithDecryption: &withDecryption,
 	})
 	if err != nil {
-		log.Fatalf("Failed to fetch parameter %s: %v", parameterName, err)
+		logging.Fatal("Failed to fetch parameter", "parameter", parameterName, "err", err)
 	}
 	return aws.ToString(param.Parameter.Value)
 }
@@ -2,10 +2,16 @@ package main
 
 import (
 	"fmt"
+	"log/slog"
 	"strings"
+	"time"
+
+	"platform/metrics"
+	"platform/tracing"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
 func InitDB(rdsEndpoint, dbUser, dbPassword, dbName string) (*gorm.DB, error) {
@@ -17,10 +23,24 @@ func InitDB(rdsEndpoint, dbUser, dbPassword, dbName string) (*gorm.DB, error) {
 		host, dbUser, dbPassword, dbName,
 	)
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{
+		// Slow statements and errors go to the JSON log, record not found is
+		// an answer rather than a failure
+		Logger: logger.New(slog.NewLogLogger(slog.Default().Handler(), slog.LevelWarn), logger.Config{
+			SlowThreshold:             200 * time.Millisecond,
+			LogLevel:                  logger.Warn,
+			IgnoreRecordNotFoundError: true,
+		}),
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to the database: %w", err)
 	}
+	if err := db.Use(metrics.GORM{}); err != nil {
+		return nil, fmt.Errorf("failed to instrument the database: %w", err)
+	}
+	if err := db.Use(tracing.GORM{}); err != nil {
+		return nil, fmt.Errorf("failed to instrument the database: %w", err)
+	}
 
 	return db, nil
 }
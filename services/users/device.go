@@ -0,0 +1,475 @@
+package main
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"platform/logging"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Device-code login (RFC 8628) lets the CLI sign in without handling the
+// user's password. The CLI asks for a code pair, the user enters the short
+// user code on the device page in a browser where they are signed in, and the
+// CLI polls until it receives that browser session's tokens.
+
+const (
+	deviceCodeTTL      = 10 * time.Minute
+	devicePollInterval = 5 * time.Second
+)
+
+// Consonants only, so user codes can't spell words and are easy to read out.
+const userCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+func hashDeviceCode(deviceCode string) string {
+	sum := sha256.Sum256([]byte(deviceCode))
+	return hex.EncodeToString(sum[:])
+}
+
+// newUserCode draws each letter uniformly. Random bytes past the last whole
+// multiple of the alphabet size are skipped, as they would favour its first letters.
+func newUserCode() (string, error) {
+	const limit = 256 - 256%len(userCodeAlphabet)
+
+	code := make([]byte, 0, 8)
+	b := make([]byte, 16)
+	for len(code) < cap(code) {
+		if _, err := rand.Read(b); err != nil {
+			return "", err
+		}
+		for _, c := range b {
+			if int(c) < limit && len(code) < cap(code) {
+				code = append(code, userCodeAlphabet[int(c)%len(userCodeAlphabet)])
+			}
+		}
+	}
+	return string(code), nil
+}
+
+// deviceTokenCipher encrypts the tokens of approved logins while they wait
+// to be collected, so they can't be read from the database alone. The key is
+// derived from the app client secret.
+func deviceTokenCipher() (cipher.AEAD, error) {
+	mac := hmac.New(sha256.New, []byte(clientSecret))
+	mac.Write([]byte("tasknest device authorization tokens"))
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealDeviceToken encrypts a token for the login with the given user code,
+// which binds the result to that row.
+func sealDeviceToken(token, userCode string) (string, error) {
+	aead, err := deviceTokenCipher()
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(token)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(token), []byte(userCode))), nil
+}
+
+// openDeviceToken reverses sealDeviceToken.
+func openDeviceToken(sealed, userCode string) (string, error) {
+	aead, err := deviceTokenCipher()
+	if err != nil {
+		return "", err
+	}
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("sealed token too short")
+	}
+	token, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], []byte(userCode))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// normalizeUserCode accepts codes typed in lower case or with the dash and
+// spaces they are displayed with.
+func normalizeUserCode(code string) string {
+	code = strings.ToUpper(code)
+	return strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, code)
+}
+
+func formatUserCode(code string) string {
+	return code[:4] + "-" + code[4:]
+}
+
+// deviceVerificationURI is the device page on the same host as the Cognito
+// callback, where the browser holds the session cookies.
+func deviceVerificationURI() string {
+	u, err := url.Parse(redirectURL)
+	if err != nil {
+		return "/api/users/device"
+	}
+	u.Path = "/api/users/device"
+	u.RawQuery = ""
+	return u.String()
+}
+
+// @Summary Start Device Login
+// @Description Issues a device code and a short user code for signing in a CLI. The user enters the user code at verification_uri while signed in; the device then polls /users/device/token every interval seconds.
+// @Tags authentication
+// @Produce json
+// @Success 200 {object} DeviceCodeResponse
+// @Failure 500 {string} string "Internal server error"
+// @Router /users/device/code [post]
+func handleDeviceCode(w http.ResponseWriter, r *http.Request) {
+	now := time.Now()
+
+	// Expired requests are never collected, clear them out as new ones come in
+	if err := db.WithContext(r.Context()).Where("expires_at < ?", now).Delete(&DeviceAuthorization{}).Error; err != nil {
+		logging.RequestLogger(r).Error("Couldn't delete expired device authorizations", "err", err)
+	}
+
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		http.Error(w, "Failed to generate device code", http.StatusInternalServerError)
+		return
+	}
+	deviceCode := base64.RawURLEncoding.EncodeToString(secret)
+
+	auth := DeviceAuthorization{
+		DeviceCode: hashDeviceCode(deviceCode),
+		ExpiresAt:  now.Add(deviceCodeTTL),
+	}
+
+	// User codes are short enough to collide now and then, draw again if so
+	for attempt := 0; ; attempt++ {
+		code, err := newUserCode()
+		if err != nil {
+			http.Error(w, "Failed to generate device code", http.StatusInternalServerError)
+			return
+		}
+		auth.UserCode = code
+
+		result := db.WithContext(r.Context()).Clauses(clause.OnConflict{DoNothing: true}).Create(&auth)
+		if result.Error != nil {
+			logging.RequestLogger(r).Error("Couldn't store device authorization", "err", result.Error)
+			http.Error(w, "Failed to store device code", http.StatusInternalServerError)
+			return
+		}
+		if result.RowsAffected == 1 {
+			break
+		}
+		if attempt == 4 {
+			http.Error(w, "Failed to generate device code", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	verificationURI := deviceVerificationURI()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(DeviceCodeResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatUserCode(auth.UserCode),
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(formatUserCode(auth.UserCode)),
+		ExpiresIn:               int(deviceCodeTTL.Seconds()),
+		Interval:                int(devicePollInterval.Seconds()),
+	})
+}
+
+func writeDeviceTokenError(w http.ResponseWriter, code string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(DeviceTokenError{Error: code})
+}
+
+// tokenExpiresIn reads how long an ID token has left from its exp claim.
+// The token was verified when it was stored.
+func tokenExpiresIn(idToken string, now time.Time) int {
+	var claims jwt.StandardClaims
+	if _, _, err := new(jwt.Parser).ParseUnverified(idToken, &claims); err != nil || claims.ExpiresAt == 0 {
+		return 0
+	}
+	return max(int(claims.ExpiresAt-now.Unix()), 0)
+}
+
+// @Summary Poll Device Login
+// @Description Exchanges an approved device code for the user's tokens, once. Until then it answers 400 with an RFC 8628 error: authorization_pending, slow_down when polled faster than the interval, access_denied, expired_token or invalid_grant.
+// @Tags authentication
+// @Accept json
+// @Produce json
+// @Param request body DeviceTokenRequest true "Device code"
+// @Success 200 {object} DeviceTokenResponse
+// @Failure 400 {object} DeviceTokenError
+// @Failure 500 {string} string "Internal server error"
+// @Router /users/device/token [post]
+func handleDeviceToken(w http.ResponseWriter, r *http.Request) {
+	var req DeviceTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.DeviceCode == "" {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+	deviceCode := hashDeviceCode(req.DeviceCode)
+	now := time.Now()
+
+	// Collect a decided request; deleting it makes sure the tokens are handed out only once
+	var decided []DeviceAuthorization
+	if err := db.WithContext(r.Context()).Clauses(clause.Returning{}).
+		Where("device_code = ? AND expires_at > ? AND (id_token IS NOT NULL OR denied)", deviceCode, now).
+		Delete(&decided).Error; err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(decided) == 1 {
+		auth := decided[0]
+		if auth.Denied {
+			writeDeviceTokenError(w, "access_denied")
+			return
+		}
+
+		idToken, err := openDeviceToken(*auth.IDToken, auth.UserCode)
+		if err != nil {
+			// Sealed under another client secret; the device has to start over
+			logging.RequestLogger(r).Error("Couldn't decrypt device tokens", "err", err)
+			writeDeviceTokenError(w, "expired_token")
+			return
+		}
+		resp := DeviceTokenResponse{
+			IDToken:   idToken,
+			ExpiresIn: tokenExpiresIn(idToken, now),
+		}
+		if auth.RefreshToken != nil {
+			if resp.RefreshToken, err = openDeviceToken(*auth.RefreshToken, auth.UserCode); err != nil {
+				logging.RequestLogger(r).Error("Couldn't decrypt device tokens", "err", err)
+				writeDeviceTokenError(w, "expired_token")
+				return
+			}
+		}
+
+		logins.WithLabelValues("device").Inc()
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		w.WriteHeader(http.StatusOK)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
+
+	var auth DeviceAuthorization
+	if err := db.WithContext(r.Context()).First(&auth, "device_code = ?", deviceCode).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			writeDeviceTokenError(w, "invalid_grant")
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	if !auth.ExpiresAt.After(now) {
+		writeDeviceTokenError(w, "expired_token")
+		return
+	}
+
+	// Allow a second of slack so clients polling right on the interval aren't told to slow down
+	result := db.WithContext(r.Context()).Model(&DeviceAuthorization{}).
+		Where("device_code = ? AND (polled_at IS NULL OR polled_at <= ?)", deviceCode, now.Add(-devicePollInterval+time.Second)).
+		Update("polled_at", now)
+	if result.Error != nil {
+		http.Error(w, result.Error.Error(), http.StatusInternalServerError)
+		return
+	}
+	if result.RowsAffected == 0 {
+		writeDeviceTokenError(w, "slow_down")
+		return
+	}
+	writeDeviceTokenError(w, "authorization_pending")
+}
+
+var devicePage = template.Must(template.New("device").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>tasknest · Device login</title>
+<style>
+body { font-family: system-ui, sans-serif; max-width: 28rem; margin: 4rem auto; padding: 0 1rem; color: #1f2937; }
+input { font: inherit; font-family: ui-monospace, monospace; letter-spacing: .1em; text-transform: uppercase; padding: .4rem; width: 10rem; }
+button { font: inherit; padding: .4rem 1rem; margin-right: .5rem; }
+.error { color: #b91c1c; }
+</style>
+</head>
+<body>
+<h1>Device login</h1>
+{{if .Message}}<p{{if .Error}} class="error"{{end}}>{{.Message}}</p>{{end}}
+{{if .SignInURL}}<p><a href="{{.SignInURL}}">Sign in to tasknest</a>, then come back to this page.</p>
+{{else if .ShowForm}}<p>Signed in as <strong>{{.Email}}</strong>. Enter the code shown by the tasknest CLI to let it act on your behalf.</p>
+<form method="post">
+<p><input name="user_code" value="{{.UserCode}}" autocomplete="off" autofocus required></p>
+<p><button name="action" value="approve">Approve</button><button name="action" value="deny">Deny</button></p>
+</form>
+{{end}}
+</body>
+</html>
+`))
+
+type devicePageData struct {
+	Message   string
+	Error     bool
+	SignInURL string
+	ShowForm  bool
+	Email     string
+	UserCode  string
+}
+
+func renderDevicePage(w http.ResponseWriter, r *http.Request, status int, data devicePageData) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := devicePage.Execute(w, data); err != nil {
+		logging.RequestLogger(r).Error("Couldn't render device page", "err", err)
+	}
+}
+
+// deviceSession returns the claims of the browser's signed-in session, or
+// nil when the user still has to sign in.
+func deviceSession(r *http.Request) map[string]any {
+	idTokenCookie, err := r.Cookie("id_token")
+	if err != nil {
+		return nil
+	}
+	claims, err := tokenVerifier.VerifyID(r.Context(), idTokenCookie.Value)
+	if err != nil {
+		return nil
+	}
+	return claims
+}
+
+// @Summary Device Login Page
+// @Description Browser page where a signed-in user approves or denies a CLI login by its user code.
+// @Tags authentication
+// @Produce html
+// @Param user_code query string false "User code shown by the CLI"
+// @Success 200 {string} string "HTML page"
+// @Router /users/device [get]
+func handleDevicePage(w http.ResponseWriter, r *http.Request) {
+	claims := deviceSession(r)
+	if claims == nil {
+		renderDevicePage(w, r, http.StatusOK, devicePageData{SignInURL: frontendURL})
+		return
+	}
+
+	email, _ := claims["email"].(string)
+	renderDevicePage(w, r, http.StatusOK, devicePageData{
+		ShowForm: true,
+		Email:    email,
+		UserCode: r.URL.Query().Get("user_code"),
+	})
+}
+
+// @Summary Approve Device Login
+// @Description Approves or denies the pending CLI login with the given user code on behalf of the signed-in user. The session cookies are SameSite=Strict, so the form only works from the device page itself.
+// @Tags authentication
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param user_code formData string true "User code shown by the CLI"
+// @Param action formData string true "approve or deny"
+// @Success 200 {string} string "HTML page"
+// @Failure 400 {string} string "HTML page"
+// @Failure 401 {string} string "HTML page"
+// @Router /users/device [post]
+func handleDeviceApproval(w http.ResponseWriter, r *http.Request) {
+	claims := deviceSession(r)
+	if claims == nil {
+		renderDevicePage(w, r, http.StatusUnauthorized, devicePageData{SignInURL: frontendURL})
+		return
+	}
+	email, _ := claims["email"].(string)
+	sub, _ := claims["sub"].(string)
+	userID, err := uuid.Parse(sub)
+	if err != nil {
+		renderDevicePage(w, r, http.StatusUnauthorized, devicePageData{SignInURL: frontendURL})
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		renderDevicePage(w, r, http.StatusBadRequest, devicePageData{Message: "Invalid input", Error: true, ShowForm: true, Email: email})
+		return
+	}
+	userCode := normalizeUserCode(r.PostForm.Get("user_code"))
+	action := r.PostForm.Get("action")
+	if action != "approve" && action != "deny" {
+		renderDevicePage(w, r, http.StatusBadRequest, devicePageData{Message: "Invalid input", Error: true, ShowForm: true, Email: email})
+		return
+	}
+
+	updates := map[string]any{"user_id": userID}
+	if action == "approve" {
+		idTokenCookie, _ := r.Cookie("id_token")
+		tokens := map[string]string{"id_token": idTokenCookie.Value}
+		if refreshTokenCookie, err := r.Cookie("refresh_token"); err == nil {
+			tokens["refresh_token"] = refreshTokenCookie.Value
+		}
+		for column, token := range tokens {
+			sealed, err := sealDeviceToken(token, userCode)
+			if err != nil {
+				logging.RequestLogger(r).Error("Couldn't encrypt device tokens", "err", err)
+				renderDevicePage(w, r, http.StatusInternalServerError, devicePageData{Message: "Something went wrong, please try again.", Error: true, ShowForm: true, Email: email})
+				return
+			}
+			updates[column] = sealed
+		}
+	} else {
+		updates["denied"] = true
+	}
+
+	result := db.WithContext(r.Context()).Model(&DeviceAuthorization{}).
+		Where("user_code = ? AND expires_at > ? AND user_id IS NULL", userCode, time.Now()).
+		Updates(updates)
+	if result.Error != nil {
+		logging.RequestLogger(r).Error("Couldn't update device authorization", "err", result.Error)
+		renderDevicePage(w, r, http.StatusInternalServerError, devicePageData{Message: "Something went wrong, please try again.", Error: true, ShowForm: true, Email: email})
+		return
+	}
+	if result.RowsAffected == 0 {
+		renderDevicePage(w, r, http.StatusBadRequest, devicePageData{
+			Message:  "That code is invalid or has expired. Check the code or run the login again.",
+			Error:    true,
+			ShowForm: true,
+			Email:    email,
+			UserCode: r.PostForm.Get("user_code"),
+		})
+		return
+	}
+	deviceDecisions.WithLabelValues(action).Inc()
+
+	if action == "deny" {
+		renderDevicePage(w, r, http.StatusOK, devicePageData{Message: "Login denied. You can close this page."})
+		return
+	}
+	renderDevicePage(w, r, http.StatusOK, devicePageData{Message: "Device approved. You can close this page and return to your terminal."})
+}
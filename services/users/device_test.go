@@ -0,0 +1,60 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestNewUserCode(t *testing.T) {
+	seen := map[rune]bool{}
+	for range 200 {
+		code, err := newUserCode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(code) != 8 {
+			t.Fatalf("code %q has %d letters", code, len(code))
+		}
+		for _, r := range code {
+			if !strings.ContainsRune(userCodeAlphabet, r) {
+				t.Fatalf("code %q has %q outside the alphabet", code, r)
+			}
+			seen[r] = true
+		}
+	}
+	if len(seen) != len(userCodeAlphabet) {
+		t.Errorf("only %d of %d letters drawn", len(seen), len(userCodeAlphabet))
+	}
+}
+
+func TestSealDeviceToken(t *testing.T) {
+	saved := clientSecret
+	t.Cleanup(func() { clientSecret = saved })
+	clientSecret = "client-secret"
+
+	sealed, err := sealDeviceToken("id-token", "BCDFGHJK")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "id-token") {
+		t.Errorf("sealed token %q shows the token", sealed)
+	}
+	if again, _ := sealDeviceToken("id-token", "BCDFGHJK"); again == sealed {
+		t.Error("sealing twice gave the same result")
+	}
+
+	if token, err := openDeviceToken(sealed, "BCDFGHJK"); err != nil || token != "id-token" {
+		t.Errorf("opened %q, %v", token, err)
+	}
+	if _, err := openDeviceToken(sealed, "LMNPQRST"); err == nil {
+		t.Error("token opened for another login")
+	}
+	if _, err := openDeviceToken("id-token", "BCDFGHJK"); err == nil {
+		t.Error("plaintext token opened")
+	}
+
+	clientSecret = "rotated"
+	if _, err := openDeviceToken(sealed, "BCDFGHJK"); err == nil {
+		t.Error("token opened with another client secret")
+	}
+}
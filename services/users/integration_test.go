@@ -0,0 +1,562 @@
+//go:build integration
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"auth"
+	"platform/health"
+	"platform/testdb"
+	"platform/tracing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestMain(m *testing.M) {
+	dsn, stop, err := testdb.Database()
+	if err != nil {
+		log.Fatalf("Couldn't set up the test database: %v", err)
+	}
+
+	db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	if err != nil {
+		stop()
+		log.Fatalf("Couldn't connect to the test database: %v", err)
+	}
+
+	clientID = "tasknest-web"
+	clientSecret = "client-secret"
+	userPoolID = "us-east-1_TEST"
+	frontendURL = "https://tasknest.example.com/"
+	redirectURL = "https://tasknest.example.com/api/users/callback"
+	tokenVerifier = auth.NewVerifier(getEnv("AWS_REGION", "us-east-1"), userPoolID, clientID, tracing.Client)
+	tokenVerifier.Keys.Set(map[string]*rsa.PublicKey{testKeyID: &signingKey.PublicKey})
+
+	code := m.Run()
+
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+	stop()
+	os.Exit(code)
+}
+
+type testServer struct {
+	mux *http.ServeMux
+}
+
+// newIntegrationServer empties the database and routes requests the way main does.
+func newIntegrationServer(t *testing.T) *testServer {
+	t.Helper()
+
+	if err := db.Exec("TRUNCATE device_authorizations, users CASCADE").Error; err != nil {
+		t.Fatalf("couldn't empty the database: %v", err)
+	}
+	return &testServer{mux: newMux()}
+}
+
+func (s *testServer) serve(req *http.Request) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.mux.ServeHTTP(rec, req)
+	return rec
+}
+
+// do sends a request with the given cookies. A string body is sent as is,
+// anything else as JSON.
+func (s *testServer) do(t *testing.T, method, path string, body any, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		raw, err := json.Marshal(b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = strings.NewReader(string(raw))
+	}
+
+	req := httptest.NewRequest(method, path, reader)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return s.serve(req)
+}
+
+func createUser(t *testing.T, userID uuid.UUID, email string) {
+	t.Helper()
+	if err := db.Create(&User{UserID: userID, Email: email}).Error; err != nil {
+		t.Fatalf("couldn't create user %s: %v", email, err)
+	}
+}
+
+func responseCookie(rec *httptest.ResponseRecorder, name string) *http.Cookie {
+	for _, cookie := range rec.Result().Cookies() {
+		if cookie.Name == name {
+			return cookie
+		}
+	}
+	return nil
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestIntegrationHealthCheck(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "GET", "/api/users/", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if rec.Body.String() != "OK" {
+		t.Errorf("body = %q", rec.Body.String())
+	}
+}
+
+func TestIntegrationReadiness(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "GET", "/api/users/ready", nil)
+	expectStatus(t, rec, http.StatusOK)
+	report := decode[health.Report](t, rec)
+	if report.Status != "ok" || len(report.Checks) != 3 {
+		t.Fatalf("report = %+v", report)
+	}
+	for i, name := range []string{"database", "migrations", "jwks"} {
+		if report.Checks[i].Name != name || report.Checks[i].Status != "ok" {
+			t.Errorf("check %d = %+v, want %s ok", i, report.Checks[i], name)
+		}
+	}
+
+	// With the cache expired the keys have to come from Cognito, which knows
+	// nothing of the test user pool
+	tokenVerifier.Keys.Expire()
+	defer tokenVerifier.Keys.Set(map[string]*rsa.PublicKey{testKeyID: &signingKey.PublicKey})
+
+	// The failure is reported, but doesn't take the service out of rotation
+	rec = s.do(t, "GET", "/api/users/ready", nil)
+	expectStatus(t, rec, http.StatusOK)
+	if report := decode[health.Report](t, rec); report.Status != "degraded" || report.Checks[2].Status != "failed" || report.Checks[0].Status != "ok" {
+		t.Errorf("report = %+v", report)
+	}
+
+	expectStatus(t, s.do(t, "GET", "/api/users/live", nil), http.StatusOK)
+}
+
+func TestIntegrationAuthCheck(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "GET", "/api/users/auth/check", nil, session(idToken(t, ana, "ana@example.com", nil)))
+	expectStatus(t, rec, http.StatusOK)
+	body := decode[map[string]any](t, rec)
+	if body["isAuthenticated"] != true || body["user"].(map[string]any)["email"] != "ana@example.com" {
+		t.Errorf("body = %v", body)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{"sub": ana.String(), "aud": clientID, "iss": issuer(), "exp": time.Now().Add(time.Hour).Unix()})
+	forged.Header["kid"] = testKeyID
+	forgedToken, err := forged.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rejected := map[string][]*http.Cookie{
+		"no cookie":      nil,
+		"garbage":        {session("not-a-jwt")},
+		"expired":        {session(idToken(t, ana, "ana@example.com", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}))},
+		"wrong audience": {session(idToken(t, ana, "ana@example.com", jwt.MapClaims{"aud": "another-client"}))},
+		"wrong issuer":   {session(idToken(t, ana, "ana@example.com", jwt.MapClaims{"iss": "https://evil.example.com"}))},
+		"wrong key":      {session(forgedToken)},
+	}
+	for name, cookies := range rejected {
+		t.Run(name, func(t *testing.T) {
+			rec := s.do(t, "GET", "/api/users/auth/check", nil, cookies...)
+			expectStatus(t, rec, http.StatusUnauthorized)
+			if body := decode[map[string]any](t, rec); body["isAuthenticated"] != false {
+				t.Errorf("body = %v", body)
+			}
+		})
+	}
+}
+
+// stubTokenEndpoint stands in for Cognito's OAuth2 token endpoint, answering
+// the authorization code "good" with the given ID token.
+func stubTokenEndpoint(t *testing.T, token string) {
+	t.Helper()
+
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/oauth2/token" || r.FormValue("grant_type") != "authorization_code" ||
+			r.FormValue("client_id") != clientID || r.FormValue("client_secret") != clientSecret ||
+			r.FormValue("redirect_uri") != redirectURL {
+			http.Error(w, `{"error":"invalid_request"}`, http.StatusBadRequest)
+			return
+		}
+		if r.FormValue("code") != "good" {
+			http.Error(w, `{"error":"invalid_grant"}`, http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]string{
+			"id_token":      token,
+			"access_token":  "access",
+			"refresh_token": "refresh",
+		})
+	}))
+	t.Cleanup(stub.Close)
+
+	previous := cognitoDomain
+	cognitoDomain = stub.URL
+	t.Cleanup(func() { cognitoDomain = previous })
+}
+
+func TestIntegrationCallback(t *testing.T) {
+	s := newIntegrationServer(t)
+	token := idToken(t, ana, "ana@example.com", nil)
+	stubTokenEndpoint(t, token)
+
+	created, webLogins := testutil.ToFloat64(usersCreated), testutil.ToFloat64(logins.WithLabelValues("web"))
+
+	expectStatus(t, s.do(t, "GET", "/api/users/callback", nil), http.StatusBadRequest)
+	expectStatus(t, s.do(t, "GET", "/api/users/callback?code=stale", nil), http.StatusUnauthorized)
+
+	for range 2 {
+		rec := s.do(t, "GET", "/api/users/callback?code=good", nil)
+		expectStatus(t, rec, http.StatusFound)
+		if location := rec.Header().Get("Location"); location != frontendURL {
+			t.Errorf("redirected to %q", location)
+		}
+		if cookie := responseCookie(rec, "id_token"); cookie == nil || cookie.Value != token || !cookie.HttpOnly || !cookie.Secure {
+			t.Errorf("id_token cookie = %+v", cookie)
+		}
+		if cookie := responseCookie(rec, "refresh_token"); cookie == nil || cookie.Value != "refresh" {
+			t.Errorf("refresh_token cookie = %+v", cookie)
+		}
+	}
+
+	// Signing in again doesn't duplicate the user
+	var users []User
+	if err := db.Find(&users).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(users) != 1 || users[0].UserID != ana || users[0].Email != "ana@example.com" || users[0].Timezone != "UTC" {
+		t.Errorf("users = %+v", users)
+	}
+	if got := testutil.ToFloat64(usersCreated) - created; got != 1 {
+		t.Errorf("%v users counted as created, want 1", got)
+	}
+	if got := testutil.ToFloat64(logins.WithLabelValues("web")) - webLogins; got != 2 {
+		t.Errorf("%v web logins counted, want 2", got)
+	}
+}
+
+func TestIntegrationCallbackUnverifiedToken(t *testing.T) {
+	s := newIntegrationServer(t)
+	stubTokenEndpoint(t, idToken(t, ana, "ana@example.com", jwt.MapClaims{"aud": "another-client"}))
+
+	expectStatus(t, s.do(t, "GET", "/api/users/callback?code=good", nil), http.StatusInternalServerError)
+
+	var count int64
+	if err := db.Model(&User{}).Count(&count).Error; err != nil {
+		t.Fatal(err)
+	}
+	if count != 0 {
+		t.Errorf("%d users created from an unverified token", count)
+	}
+}
+
+func TestIntegrationRefresh(t *testing.T) {
+	s := newIntegrationServer(t)
+	refreshed := idToken(t, ana, "ana@example.com", nil)
+
+	// Stands in for Cognito's InitiateAuth API
+	stub := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var input struct {
+			AuthFlow       string
+			ClientId       string
+			AuthParameters map[string]string
+		}
+		json.NewDecoder(r.Body).Decode(&input)
+
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		if r.Header.Get("X-Amz-Target") != "AWSCognitoIdentityProviderService.InitiateAuth" ||
+			input.AuthFlow != "REFRESH_TOKEN_AUTH" || input.ClientId != clientID || input.AuthParameters["REFRESH_TOKEN"] != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"__type":"NotAuthorizedException","message":"Invalid Refresh Token"}`)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{
+			"AuthenticationResult": map[string]any{"IdToken": refreshed, "ExpiresIn": 3600, "TokenType": "Bearer"},
+		})
+	}))
+	defer stub.Close()
+
+	previous := cognitoClient
+	cognitoClient = cognitoidentityprovider.New(cognitoidentityprovider.Options{
+		Region:       "us-east-1",
+		BaseEndpoint: aws.String(stub.URL),
+		Credentials:  aws.AnonymousCredentials{},
+	})
+	defer func() { cognitoClient = previous }()
+
+	expectStatus(t, s.do(t, "POST", "/api/users/refresh", nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, "POST", "/api/users/refresh", nil, &http.Cookie{Name: "refresh_token", Value: "revoked"}), http.StatusUnauthorized)
+
+	rec := s.do(t, "POST", "/api/users/refresh", nil, &http.Cookie{Name: "refresh_token", Value: "refresh"})
+	expectStatus(t, rec, http.StatusOK)
+	if cookie := responseCookie(rec, "id_token"); cookie == nil || cookie.Value != refreshed {
+		t.Errorf("id_token cookie = %+v", cookie)
+	}
+}
+
+func TestIntegrationLogout(t *testing.T) {
+	s := newIntegrationServer(t)
+
+	rec := s.do(t, "GET", "/api/users/logout", nil, session(idToken(t, ana, "ana@example.com", nil)))
+	expectStatus(t, rec, http.StatusFound)
+	if location := rec.Header().Get("Location"); location != frontendURL {
+		t.Errorf("redirected to %q", location)
+	}
+	for _, name := range []string{"id_token", "refresh_token"} {
+		if cookie := responseCookie(rec, name); cookie == nil || cookie.Value != "" || cookie.Expires.After(time.Now()) {
+			t.Errorf("%s cookie = %+v", name, cookie)
+		}
+	}
+}
+
+func TestIntegrationProfile(t *testing.T) {
+	s := newIntegrationServer(t)
+	createUser(t, ana, "ana@example.com")
+	anaSession := session(idToken(t, ana, "ana@example.com", nil))
+
+	expectStatus(t, s.do(t, "GET", "/api/users/me", nil), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, "PATCH", "/api/users/me", ProfileRequest{}), http.StatusUnauthorized)
+	expectStatus(t, s.do(t, "GET", "/api/users/me", nil, session(idToken(t, bob, "bob@example.com", nil))), http.StatusNotFound)
+	expectStatus(t, s.do(t, "PATCH", "/api/users/me", ProfileRequest{WeekStart: ptr(0)}, session(idToken(t, bob, "bob@example.com", nil))), http.StatusNotFound)
+
+	// The ID token also works as a bearer token, which is how the CLI calls in
+	req := httptest.NewRequest("GET", "/api/users/me", nil)
+	req.Header.Set("Authorization", "Bearer "+idToken(t, ana, "ana@example.com", nil))
+	rec := s.serve(req)
+	expectStatus(t, rec, http.StatusOK)
+	user := decode[User](t, rec)
+	if user.Email != "ana@example.com" || user.Timezone != "UTC" || user.Locale != "en-US" || user.DailyCapacityMinutes != 480 || user.AutoArchiveDays != nil {
+		t.Errorf("defaults = %+v", user)
+	}
+
+	rec = s.do(t, "PATCH", "/api/users/me", ProfileRequest{
+		Timezone:             ptr("Europe/Lisbon"),
+		Locale:               ptr("pt-pt"),
+		WeekStart:            ptr(0),
+		DateFormat:           ptr("DD/MM/YYYY"),
+		DailyCapacityMinutes: ptr(360),
+		DailyCapacityPoints:  ptr(8),
+		AutoArchiveDays:      ptr(30),
+		DigestFrequency:      ptr("WEEKLY"),
+		DigestHour:           ptr(7),
+	}, anaSession)
+	expectStatus(t, rec, http.StatusOK)
+	want := User{
+		UserID:               ana,
+		Email:                "ana@example.com",
+		Timezone:             "Europe/Lisbon",
+		Locale:               "pt-PT",
+		WeekStart:            0,
+		DateFormat:           "DD/MM/YYYY",
+		DailyCapacityMinutes: 360,
+		DailyCapacityPoints:  8,
+		DigestFrequency:      "WEEKLY",
+		DigestHour:           7,
+	}
+	if user := decode[User](t, rec); user.AutoArchiveDays == nil || *user.AutoArchiveDays != 30 {
+		t.Errorf("auto_archive_days = %v", user.AutoArchiveDays)
+	} else if user.AutoArchiveDays = nil; user != want {
+		t.Errorf("profile = %+v, want %+v", user, want)
+	}
+
+	// Omitted fields stay, a zero auto-archive period turns it off
+	rec = s.do(t, "PATCH", "/api/users/me", ProfileRequest{AutoArchiveDays: ptr(0)}, anaSession)
+	expectStatus(t, rec, http.StatusOK)
+	if user := decode[User](t, rec); user.AutoArchiveDays != nil || user.Timezone != "Europe/Lisbon" || user.DigestHour != 7 {
+		t.Errorf("profile = %+v", user)
+	}
+
+	invalid := map[string]any{
+		"malformed body":   "{",
+		"local timezone":   ProfileRequest{Timezone: ptr("Local")},
+		"unknown timezone": ProfileRequest{Timezone: ptr("Mars/Olympus_Mons")},
+		"invalid locale":   ProfileRequest{Locale: ptr("not a locale!")},
+		"locale too long":  ProfileRequest{Locale: ptr("en-US-u-ca-gregory-nu-latn-co-phonebk-hc-h23")},
+		"week start":       ProfileRequest{WeekStart: ptr(7)},
+		"date format":      ProfileRequest{DateFormat: ptr("YY-M-D")},
+		"capacity minutes": ProfileRequest{DailyCapacityMinutes: ptr(24*60 + 1)},
+		"capacity points":  ProfileRequest{DailyCapacityPoints: ptr(-1)},
+		"auto-archive":     ProfileRequest{AutoArchiveDays: ptr(-1)},
+		"digest frequency": ProfileRequest{DigestFrequency: ptr("HOURLY")},
+		"digest hour":      ProfileRequest{DigestHour: ptr(24)},
+	}
+	for name, body := range invalid {
+		t.Run(name, func(t *testing.T) {
+			expectStatus(t, s.do(t, "PATCH", "/api/users/me", body, anaSession), http.StatusBadRequest)
+		})
+	}
+
+	rec = s.do(t, "GET", "/api/users/me", nil, anaSession)
+	expectStatus(t, rec, http.StatusOK)
+	if user := decode[User](t, rec); user.Timezone != "Europe/Lisbon" || user.WeekStart != 0 || user.DigestFrequency != "WEEKLY" {
+		t.Errorf("profile changed by rejected updates: %+v", user)
+	}
+}
+
+func (s *testServer) requestDeviceCode(t *testing.T) DeviceCodeResponse {
+	t.Helper()
+	rec := s.do(t, "POST", "/api/users/device/code", nil)
+	expectStatus(t, rec, http.StatusOK)
+	return decode[DeviceCodeResponse](t, rec)
+}
+
+func (s *testServer) pollDeviceToken(t *testing.T, deviceCode string) *httptest.ResponseRecorder {
+	t.Helper()
+	return s.do(t, "POST", "/api/users/device/token", DeviceTokenRequest{DeviceCode: deviceCode})
+}
+
+func expectDeviceError(t *testing.T, rec *httptest.ResponseRecorder, code string) {
+	t.Helper()
+	expectStatus(t, rec, http.StatusBadRequest)
+	if got := decode[DeviceTokenError](t, rec); got.Error != code {
+		t.Errorf("error = %q, want %q", got.Error, code)
+	}
+}
+
+func (s *testServer) decideDevice(t *testing.T, userCode, action string, cookies ...*http.Cookie) *httptest.ResponseRecorder {
+	t.Helper()
+	form := url.Values{"user_code": {userCode}, "action": {action}}
+	req := httptest.NewRequest("POST", "/api/users/device", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	return s.serve(req)
+}
+
+func TestIntegrationDeviceLogin(t *testing.T) {
+	s := newIntegrationServer(t)
+	createUser(t, ana, "ana@example.com")
+	token := idToken(t, ana, "ana@example.com", nil)
+	cookies := []*http.Cookie{session(token), {Name: "refresh_token", Value: "refresh"}}
+
+	code := s.requestDeviceCode(t)
+	if code.DeviceCode == "" || len(code.UserCode) != 9 || code.UserCode[4] != '-' ||
+		code.VerificationURI != "https://tasknest.example.com/api/users/device" ||
+		code.VerificationURIComplete != code.VerificationURI+"?user_code="+code.UserCode ||
+		code.ExpiresIn != int(deviceCodeTTL.Seconds()) || code.Interval != int(devicePollInterval.Seconds()) {
+		t.Errorf("device code = %+v", code)
+	}
+
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "authorization_pending")
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "slow_down")
+	expectDeviceError(t, s.pollDeviceToken(t, "unknown"), "invalid_grant")
+	expectStatus(t, s.do(t, "POST", "/api/users/device/token", "{}"), http.StatusBadRequest)
+
+	// The page asks signed-out users to sign in and prefills the code for signed-in ones
+	rec := s.do(t, "GET", "/api/users/device?user_code="+url.QueryEscape(code.UserCode), nil)
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, "Sign in to tasknest") || strings.Contains(body, "<form") {
+		t.Errorf("signed-out page = %s", body)
+	}
+	rec = s.do(t, "GET", "/api/users/device?user_code="+url.QueryEscape(code.UserCode), nil, cookies...)
+	expectStatus(t, rec, http.StatusOK)
+	if body := rec.Body.String(); !strings.Contains(body, "ana@example.com") || !strings.Contains(body, `value="`+code.UserCode+`"`) {
+		t.Errorf("signed-in page = %s", body)
+	}
+
+	expectStatus(t, s.decideDevice(t, code.UserCode, "approve"), http.StatusUnauthorized)
+	expectStatus(t, s.decideDevice(t, code.UserCode, "maybe", cookies...), http.StatusBadRequest)
+	expectStatus(t, s.decideDevice(t, "BCDF-GHJK", "approve", cookies...), http.StatusBadRequest)
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "slow_down")
+
+	// Codes are accepted in lower case and without the dash
+	rec = s.decideDevice(t, strings.ToLower(strings.ReplaceAll(code.UserCode, "-", "")), "approve", cookies...)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "Device approved") {
+		t.Errorf("approval page = %s", rec.Body.String())
+	}
+	expectStatus(t, s.decideDevice(t, code.UserCode, "deny", cookies...), http.StatusBadRequest)
+
+	// The tokens wait encrypted, not as they came from the cookies
+	var stored DeviceAuthorization
+	if err := db.First(&stored, "user_code = ?", normalizeUserCode(code.UserCode)).Error; err != nil {
+		t.Fatal(err)
+	}
+	if stored.IDToken == nil || *stored.IDToken == token || stored.RefreshToken == nil || *stored.RefreshToken == "refresh" {
+		t.Errorf("stored tokens = %v, %v", stored.IDToken, stored.RefreshToken)
+	}
+
+	rec = s.pollDeviceToken(t, code.DeviceCode)
+	expectStatus(t, rec, http.StatusOK)
+	resp := decode[DeviceTokenResponse](t, rec)
+	if resp.IDToken != token || resp.RefreshToken != "refresh" || resp.ExpiresIn <= 0 || resp.ExpiresIn > 3600 {
+		t.Errorf("tokens = %+v", resp)
+	}
+
+	// The tokens are handed out once
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "invalid_grant")
+}
+
+func TestIntegrationDeviceLoginDenied(t *testing.T) {
+	s := newIntegrationServer(t)
+	createUser(t, ana, "ana@example.com")
+	anaSession := session(idToken(t, ana, "ana@example.com", nil))
+
+	code := s.requestDeviceCode(t)
+	rec := s.decideDevice(t, code.UserCode, "deny", anaSession)
+	expectStatus(t, rec, http.StatusOK)
+	if !strings.Contains(rec.Body.String(), "Login denied") {
+		t.Errorf("denial page = %s", rec.Body.String())
+	}
+
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "access_denied")
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "invalid_grant")
+}
+
+func TestIntegrationDeviceLoginExpired(t *testing.T) {
+	s := newIntegrationServer(t)
+	createUser(t, ana, "ana@example.com")
+
+	code := s.requestDeviceCode(t)
+	if err := db.Model(&DeviceAuthorization{}).Where("1 = 1").Update("expires_at", time.Now().Add(-time.Minute)).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "expired_token")
+	expectStatus(t, s.decideDevice(t, code.UserCode, "approve", session(idToken(t, ana, "ana@example.com", nil))), http.StatusBadRequest)
+
+	// Requesting a new code clears out the expired one
+	s.requestDeviceCode(t)
+	expectDeviceError(t, s.pollDeviceToken(t, code.DeviceCode), "invalid_grant")
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"platform/logging"
+)
+
+// captureLogs sends the default logger to a buffer for the rest of the test.
+func captureLogs(t *testing.T) *bytes.Buffer {
+	t.Helper()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(logging.NewHandler(&buf)))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+	return &buf
+}
+
+// logLines decodes the JSON log lines written to buf.
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	t.Helper()
+
+	var lines []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatalf("log line %q isn't JSON: %v", line, err)
+		}
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+// TestRequestLogging checks the logs carry the user whose ID token cookie
+// requireUser verified, never an X-User-ID header the caller sent along.
+func TestRequestLogging(t *testing.T) {
+	buf := captureLogs(t)
+	verifyTokens(t)
+
+	mux := http.NewServeMux()
+	mux.Handle("GET /api/users/me", requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		logging.RequestLogger(r).Warn("Loading profile")
+		http.Error(w, "User not found", http.StatusNotFound)
+	})))
+	handler := newHandler(mux, "")
+
+	serve := func(cookie *http.Cookie) []map[string]any {
+		t.Helper()
+		buf.Reset()
+		req := httptest.NewRequest("GET", "/api/users/me", nil)
+		req.Header.Set("X-User-ID", bob.String())
+		req.Header.Set(logging.RequestIDHeader, "apigw-4f1c")
+		req.AddCookie(cookie)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+		return logLines(t, buf)
+	}
+
+	lines := serve(session(idToken(t, ana, "ana@example.com", nil)))
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2", len(lines))
+	}
+	for _, entry := range lines {
+		if entry["request_id"] != "apigw-4f1c" || entry["route"] != "GET /api/users/me" || entry["user_id"] != ana.String() {
+			t.Errorf("log line missing request context: %v", entry)
+		}
+	}
+
+	lines = serve(session("not-a-token"))
+	if len(lines) != 1 || lines[0]["status"] != float64(http.StatusUnauthorized) || lines[0]["user_id"] != nil {
+		t.Errorf("rejected request logged as %v", lines)
+	}
+}
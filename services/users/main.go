@@ -1,22 +1,32 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
-	"fmt"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
-
-	"context"
-
+	_ "time/tzdata"
+
+	"auth"
+	"platform/health"
+	"platform/logging"
+	"platform/metrics"
+	"platform/server"
+	"platform/tracing"
 	_ "users/docs"
 
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
 	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	httpSwagger "github.com/swaggo/http-swagger/v2"
 	"gorm.io/gorm"
 )
@@ -34,6 +44,7 @@ var (
 	redirectURL   string
 	userPoolID    string
 	clientSecret  string
+	tokenVerifier *auth.Verifier
 	db            *gorm.DB
 )
 
@@ -45,13 +56,17 @@ func getEnv(key, defaultValue string) string {
 }
 
 func main() {
+	if err := logging.Init(getEnv("LOG_LEVEL", "INFO")); err != nil {
+		logging.Fatal("Invalid logging configuration", "err", err)
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	cfg, err := config.LoadDefaultConfig(ctx,
 		config.WithRegion(getEnv("AWS_REGION", "us-east-1")),
 	)
 	if err != nil {
-		log.Fatalf("Failed to create AWS config: %v", err)
+		logging.Fatal("Failed to create AWS config", "err", err)
 	}
 
 	ssmClient := ssm.NewFromConfig(cfg)
@@ -60,18 +75,19 @@ func main() {
 	frontendURL = getParameter(ssmClient, "frontend_url", ctx)
 	redirectURL = getParameter(ssmClient, "redirect_uri", ctx)
 	userPoolID = getParameter(ssmClient, "userpool_id", ctx)
+	tokenVerifier = auth.NewVerifier(getEnv("AWS_REGION", "us-east-1"), userPoolID, clientID, tracing.Client)
 
 	cognitoClient = cognitoidentityprovider.NewFromConfig(cfg)
 
 	secretsManagerClient := secretsmanager.NewFromConfig(cfg)
 	val, err := getSecretValue(secretsManagerClient, "postgres", ctx)
 	if err != nil {
-		log.Fatal("Can't get credentials:", err)
+		logging.Fatal("Can't get credentials", "err", err)
 	}
 
 	var creds DBCreds
 	if err := json.Unmarshal(val, &creds); err != nil {
-		log.Fatalf("unable to parse secret: %v", err)
+		logging.Fatal("Unable to parse secret", "err", err)
 	}
 
 	rdsEndpoint := getParameter(ssmClient, "rds_endpoint", ctx)
@@ -79,40 +95,100 @@ func main() {
 
 	db, err = InitDB(rdsEndpoint, creds.Username, creds.Password, dbName)
 	if err != nil {
-		log.Fatalf("Error initializing database: %v\n", err)
+		logging.Fatal("Error initializing database", "err", err)
 	}
 
 	sqlDB, err := db.DB()
 	if err != nil {
-		log.Fatalf("failed to get sql.DB from gorm.DB: %v", err)
+		logging.Fatal("Failed to get sql.DB from gorm.DB", "err", err)
 	}
 
 	if err := sqlDB.Ping(); err != nil {
-		log.Fatalf("database connection test failed: %v", err)
+		logging.Fatal("Database connection test failed", "err", err)
 	}
 
-	log.Println("Database initialized successfully")
-	defer sqlDB.Close()
+	slog.Info("Database initialized successfully")
+	prometheus.MustRegister(collectors.NewDBStatsCollector(sqlDB, dbName))
 
 	val, err = getSecretValue(secretsManagerClient, "cognitoSecret", ctx)
 	if err != nil {
-		log.Fatal("Can't get CognitoSecret:", err)
+		logging.Fatal("Can't get CognitoSecret", "err", err)
 	}
 	clientSecret = strings.TrimSpace(string(val))
 
-	http.HandleFunc("GET /api/users/swagger/", httpSwagger.WrapHandler)
-	http.HandleFunc("GET /api/users/{$}", handleHealthCheck)
-	http.HandleFunc("/api/users/callback", handleCognitoCallback)
-	http.HandleFunc("/api/users/logout", handleLogoutCallback)
-	http.HandleFunc("/api/users/auth/check", handleAuthCheck)
-	http.HandleFunc("/api/users/refresh", handleTokenRefresh)
+	serverCfg, err := server.LoadConfig()
+	if err != nil {
+		logging.Fatal("Invalid server configuration", "err", err)
+	}
+
+	shutdownTracing, err := tracing.Init(ctx, "users")
+	if err != nil {
+		logging.Fatal("Error initializing tracing", "err", err)
+	}
+
+	// ECS sends SIGTERM when it drains the task, Ctrl-C sends SIGINT locally
+	stopCtx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	metricsPort := getEnv("METRICS_PORT", "9100")
+	metricsListener, err := net.Listen("tcp", ":"+metricsPort)
+	if err != nil {
+		logging.Fatal("Failed to listen on metrics port", "err", err)
+	}
+	go func() {
+		slog.Info("Serving metrics", "port", metricsPort)
+		if err := server.Serve(stopCtx, server.New(metrics.NewMux(), serverCfg), metricsListener, serverCfg.ShutdownTimeout); err != nil {
+			slog.Error("Metrics server stopped", "err", err)
+		}
+	}()
 
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "8080"
 	}
-	fmt.Printf("Starting server on port %s\n", port)
-	if err = http.ListenAndServe(":"+port, nil); err != nil {
-		log.Fatalf("Failed to create AWS session: %v", err)
+	listener, err := net.Listen("tcp", ":"+port)
+	if err != nil {
+		logging.Fatal("Failed to listen", "port", port, "err", err)
+	}
+	slog.Info("Starting server", "port", port)
+	handler := newHandler(newMux(), frontendURL)
+	err = server.Serve(stopCtx, server.New(handler, serverCfg), listener, serverCfg.ShutdownTimeout)
+	if err != nil {
+		slog.Error("HTTP server stopped", "err", err)
+	}
+
+	if err := sqlDB.Close(); err != nil {
+		slog.Error("Couldn't close the database", "err", err)
 	}
+
+	// Flush the spans still buffered, on a fresh context since stopCtx is done
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancelFlush()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Error("Couldn't flush traces", "err", err)
+	}
+	slog.Info("Server stopped")
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// newMux routes the authentication, profile and device login endpoints.
+func newMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/swagger/", httpSwagger.WrapHandler)
+	mux.HandleFunc("GET /api/users/{$}", handleHealthCheck)
+	mux.HandleFunc("GET /api/users/live", handleLiveness)
+	mux.HandleFunc("GET /api/users/ready", newReadinessHandler(health.Database(db), health.Migrations(db), jwksCheck()))
+	mux.HandleFunc("/api/users/callback", handleCognitoCallback)
+	mux.HandleFunc("/api/users/logout", handleLogoutCallback)
+	mux.HandleFunc("/api/users/auth/check", handleAuthCheck)
+	mux.HandleFunc("/api/users/refresh", handleTokenRefresh)
+	mux.Handle("GET /api/users/me", requireUser(http.HandlerFunc(handleGetProfile)))
+	mux.Handle("PATCH /api/users/me", requireUser(http.HandlerFunc(handleUpdateProfile)))
+	mux.HandleFunc("POST /api/users/device/code", handleDeviceCode)
+	mux.HandleFunc("POST /api/users/device/token", handleDeviceToken)
+	mux.HandleFunc("GET /api/users/device", handleDevicePage)
+	mux.HandleFunc("POST /api/users/device", handleDeviceApproval)
+	return mux
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	usersCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "tasknest_users_created_total",
+		Help: "Users created on their first sign-in.",
+	})
+
+	logins = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_logins_total",
+		Help: "Completed sign-ins, through the web (hosted UI callback) or a device (CLI device login).",
+	}, []string{"via"})
+
+	deviceDecisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "tasknest_device_authorizations_total",
+		Help: "Device logins approved or denied on the device page.",
+	}, []string{"action"})
+)
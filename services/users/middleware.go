@@ -0,0 +1,56 @@
+package main
+
+import (
+	"context"
+	"net/http"
+
+	"platform/logging"
+	"platform/metrics"
+	"platform/middleware"
+	"platform/tracing"
+
+	"github.com/google/uuid"
+)
+
+// maxBodySize caps request bodies. The service only takes small JSON and
+// form bodies.
+const maxBodySize = 1 << 20
+
+// newHandler wraps the mux in the stack every request goes through.
+// tracing.Handler, logging.LogRequests and metrics.Handler read the route
+// the mux matched off the request they pass down, so nothing between them
+// and the mux may replace the request; route-specific middleware such as
+// requireUser goes on the routes instead.
+func newHandler(mux *http.ServeMux, frontendURL string) http.Handler {
+	return middleware.Chain(mux,
+		logging.WithRequestID,
+		tracing.Handler,
+		logging.LogRequests,
+		metrics.Handler,
+		middleware.RecoverPanics,
+		middleware.CORS(frontendURL),
+		middleware.LimitBody(maxBodySize),
+	)
+}
+
+type userIDKey struct{}
+
+// requireUser rejects requests without a valid ID token and hands the user
+// it was issued to on to next, and to the request's logs.
+func requireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := userIDFromRequest(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		logging.SetUser(r.Context(), userID)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userIDKey{}, userID)))
+	})
+}
+
+// requestUserID returns the user requireUser let the request through for.
+func requestUserID(r *http.Request) uuid.UUID {
+	userID, _ := r.Context().Value(userIDKey{}).(uuid.UUID)
+	return userID
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+func TestRequireUser(t *testing.T) {
+	verifyTokens(t)
+
+	var got uuid.UUID
+	h := requireUser(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = requestUserID(r)
+	}))
+
+	tests := []struct {
+		name   string
+		bearer string
+		cookie string
+		status int
+	}{
+		{"no token", "", "", http.StatusUnauthorized},
+		{"not a token", "", "not-a-token", http.StatusUnauthorized},
+		{"expired", "", idToken(t, ana, "ana@example.com", jwt.MapClaims{"exp": time.Now().Add(-time.Minute).Unix()}), http.StatusUnauthorized},
+		{"another app's token", "", idToken(t, ana, "ana@example.com", jwt.MapClaims{"aud": "another-app"}), http.StatusUnauthorized},
+		{"cookie", "", idToken(t, ana, "ana@example.com", nil), http.StatusOK},
+		{"bearer token", idToken(t, ana, "ana@example.com", nil), "", http.StatusOK},
+		{"bearer token wins over the cookie", "not-a-token", idToken(t, ana, "ana@example.com", nil), http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got = uuid.Nil
+			req := httptest.NewRequest("GET", "/api/users/me", nil)
+			// The header the tasks service trusts means nothing here
+			req.Header.Set("X-User-ID", bob.String())
+			if tt.bearer != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.bearer)
+			}
+			if tt.cookie != "" {
+				req.AddCookie(session(tt.cookie))
+			}
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, req)
+
+			expectStatus(t, rec, tt.status)
+			want := uuid.Nil
+			if tt.status == http.StatusOK {
+				want = ana
+			}
+			if got != want {
+				t.Errorf("handler saw user %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestRecoverPanics(t *testing.T) {
+	buf := captureLogs(t)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/users/me", func(w http.ResponseWriter, r *http.Request) {
+		var user *User
+		_ = user.Email
+	})
+	h := newHandler(mux, "")
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/users/me", nil))
+	expectStatus(t, rec, http.StatusInternalServerError)
+
+	lines := logLines(t, buf)
+	if len(lines) != 2 {
+		t.Fatalf("%d log lines, want 2", len(lines))
+	}
+	if panicked := lines[0]; panicked["msg"] != "Handler panicked" || panicked["route"] != "GET /api/users/me" {
+		t.Errorf("panic log = %v", panicked)
+	}
+	if access := lines[1]; access["status"] != float64(http.StatusInternalServerError) {
+		t.Errorf("access log = %v", access)
+	}
+}
+
+// TestCORS checks newHandler answers the frontend's preflights, which the
+// mux has no routes for.
+func TestCORS(t *testing.T) {
+	verifyTokens(t)
+	h := newHandler(newMux(), "https://tasknest.example.com/")
+
+	req := httptest.NewRequest("OPTIONS", "/api/users/me", nil)
+	req.Header.Set("Origin", "https://tasknest.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PATCH")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+
+	expectStatus(t, rec, http.StatusNoContent)
+	if rec.Header().Get("Access-Control-Allow-Origin") != "https://tasknest.example.com" || !strings.Contains(rec.Header().Get("Access-Control-Allow-Methods"), "PATCH") {
+		t.Errorf("preflight got headers %v", rec.Header())
+	}
+}
+
+func TestBodyLimit(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /api/users/device/token", func(w http.ResponseWriter, r *http.Request) {
+		if _, err := io.ReadAll(r.Body); err != nil {
+			var tooLarge *http.MaxBytesError
+			if !errors.As(err, &tooLarge) {
+				t.Errorf("err = %v", err)
+			}
+			http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		}
+	})
+	h := newHandler(mux, "")
+
+	for size, status := range map[int]int{maxBodySize: http.StatusOK, maxBodySize + 1: http.StatusRequestEntityTooLarge} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("POST", "/api/users/device/token", strings.NewReader(strings.Repeat("a", size))))
+		expectStatus(t, rec, status)
+	}
+}
+
+// TestRoutes keeps the endpoints the frontend and the CLI call served, and
+// the profile behind requireUser.
+func TestRoutes(t *testing.T) {
+	verifyTokens(t)
+	mux := newMux()
+
+	for _, route := range []string{
+		"GET /api/users/me",
+		"PATCH /api/users/me",
+		"POST /api/users/device/code",
+		"POST /api/users/device/token",
+		"GET /api/users/device",
+		"POST /api/users/device",
+		"GET /api/users/live",
+		"GET /api/users/ready",
+	} {
+		method, path, _ := strings.Cut(route, " ")
+		if _, pattern := mux.Handler(httptest.NewRequest(method, path, nil)); pattern != route {
+			t.Errorf("%s routed to %q", route, pattern)
+		}
+	}
+	for _, path := range []string{"/api/users/callback", "/api/users/logout", "/api/users/auth/check", "/api/users/refresh"} {
+		if _, pattern := mux.Handler(httptest.NewRequest("GET", path, nil)); pattern != path {
+			t.Errorf("%s routed to %q", path, pattern)
+		}
+	}
+
+	for _, method := range []string{"GET", "PATCH"} {
+		rec := httptest.NewRecorder()
+		mux.ServeHTTP(rec, httptest.NewRequest(method, "/api/users/me", nil))
+		expectStatus(t, rec, http.StatusUnauthorized)
+	}
+}
@@ -1,11 +1,39 @@
 package main
 
 import (
+	"time"
+
 	"github.com/google/uuid"
 	_ "github.com/jinzhu/gorm/dialects/postgres"
 )
 
 type User struct {
-	UserID uuid.UUID `gorm:"primary_key;type:uuid;default:uuid_generate_v4()"`
-	Email  string    `gorm:"not null;unique"`
+	UserID     uuid.UUID `gorm:"primary_key;type:uuid;default:uuid_generate_v4()" json:"user_id"`
+	Email      string    `gorm:"not null;unique" json:"email"`
+	Timezone   string    `gorm:"size:64;not null;default:UTC" json:"timezone"`
+	Locale     string    `gorm:"size:35;not null;default:en-US" json:"locale"`
+	WeekStart  int       `gorm:"type:smallint;not null;default:1" json:"week_start"`
+	DateFormat string    `gorm:"size:16;not null;default:YYYY-MM-DD" json:"date_format"`
+
+	DailyCapacityMinutes int `gorm:"not null;default:480" json:"daily_capacity_minutes"`
+	DailyCapacityPoints  int `gorm:"not null;default:0" json:"daily_capacity_points"`
+
+	AutoArchiveDays *int `json:"auto_archive_days"`
+
+	DigestFrequency string `gorm:"size:8;not null;default:NONE" json:"digest_frequency"`
+	DigestHour      int    `gorm:"type:smallint;not null;default:8" json:"digest_hour"`
+}
+
+// DeviceAuthorization is a pending CLI login, see device.go. The tokens are
+// stored encrypted with sealDeviceToken.
+type DeviceAuthorization struct {
+	DeviceCode   string     `gorm:"primary_key;type:char(64)"`
+	UserCode     string     `gorm:"type:char(8);not null;unique"`
+	UserID       *uuid.UUID `gorm:"type:uuid"`
+	IDToken      *string    `gorm:"column:id_token;type:text"`
+	RefreshToken *string    `gorm:"type:text"`
+	Denied       bool       `gorm:"not null;default:false"`
+	PolledAt     *time.Time `gorm:"type:timestamptz"`
+	ExpiresAt    time.Time  `gorm:"type:timestamptz;not null"`
+	CreatedAt    time.Time  `gorm:"type:timestamptz;not null;default:now()"`
 }
@@ -2,21 +2,42 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"net/url"
 	"strings"
 	"time"
 
+	"platform/logging"
+	"platform/tracing"
+
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider"
 	"github.com/aws/aws-sdk-go-v2/service/cognitoidentityprovider/types"
-	"github.com/golang-jwt/jwt"
 	"github.com/google/uuid"
+	"golang.org/x/text/language"
+	"gorm.io/gorm"
 )
 
+// maxLocaleLength is the length of the locale column. Valid BCP 47 tags can
+// be longer, with extensions and variants, so they are checked against it.
+const maxLocaleLength = 35
+
+var validDateFormats = map[string]bool{
+	"YYYY-MM-DD": true,
+	"DD/MM/YYYY": true,
+	"MM/DD/YYYY": true,
+	"DD.MM.YYYY": true,
+}
+
+var validDigestFrequencies = map[string]bool{
+	"NONE":   true,
+	"DAILY":  true,
+	"WEEKLY": true,
+}
+
 // @Summary Health Check
 // @Description Returns the health status of the API.
 // @Tags health
@@ -47,7 +68,7 @@ func handleAuthCheck(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Verify the ID token
-	claims, err := verifyIDToken(idTokenCookie.Value)
+	claims, err := tokenVerifier.VerifyID(r.Context(), idTokenCookie.Value)
 	if err != nil {
 		w.WriteHeader(http.StatusUnauthorized)
 		json.NewEncoder(w).Encode(map[string]any{
@@ -82,8 +103,6 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	log.Printf("Cognito Domain: %s", cognitoDomain)
-
 	tokenURL := fmt.Sprintf("%s/oauth2/token", cognitoDomain)
 
 	reqBody := url.Values{}
@@ -93,16 +112,16 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 	reqBody.Set("redirect_uri", redirectURL)
 	reqBody.Set("code", code)
 
-	req, err := http.NewRequest("POST", tokenURL, strings.NewReader(reqBody.Encode()))
+	req, err := http.NewRequestWithContext(r.Context(), "POST", tokenURL, strings.NewReader(reqBody.Encode()))
 	if err != nil {
 		http.Error(w, "Failed to create token exchange request", http.StatusInternalServerError)
 		return
 	}
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := tracing.Client.Do(req)
 	if err != nil {
-		log.Printf("Error When Sending Token: %v\n", err)
+		logging.RequestLogger(r).Error("Couldn't send token exchange request", "err", err)
 		http.Error(w, "Failed to send token exchange request", http.StatusInternalServerError)
 		return
 	}
@@ -110,8 +129,7 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		log.Printf("Response Body: %s\n", string(bodyBytes))
-		log.Printf("Status Code: %d\n", resp.StatusCode)
+		logging.RequestLogger(r).Warn("Token exchange failed", "status", resp.StatusCode, "body", string(bodyBytes))
 		http.Error(w, "Token exchange failed", http.StatusUnauthorized)
 		return
 	}
@@ -126,7 +144,7 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	claims, err := verifyIDToken(tokenResponse.IdToken)
+	claims, err := tokenVerifier.VerifyID(r.Context(), tokenResponse.IdToken)
 	if err != nil {
 		http.Error(w, "Failed to parse ID token", http.StatusInternalServerError)
 		return
@@ -139,12 +157,15 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var user User
-	log.Printf("ID: %s :: EMAIL: %s\n", cognitoID, claims["email"].(string))
-	if err := db.FirstOrCreate(&user, User{UserID: cognitoID, Email: claims["email"].(string)}).Error; err != nil {
-		http.Error(w, "Error creating user in database: "+err.Error(), http.StatusInternalServerError)
+	result := db.WithContext(r.Context()).FirstOrCreate(&user, User{UserID: cognitoID, Email: claims["email"].(string)})
+	if result.Error != nil {
+		http.Error(w, "Error creating user in database: "+result.Error.Error(), http.StatusInternalServerError)
 		return
 	}
-	log.Printf("USER::ID {%s} | USER::EMAIL {%s}\n", user.UserID, user.Email)
+	if result.RowsAffected == 1 {
+		usersCreated.Inc()
+	}
+	logging.RequestLogger(r).Info("User signed in", "user_id", user.UserID, "new_user", result.RowsAffected == 1)
 
 	// Set tokens as cookies
 	http.SetCookie(w, &http.Cookie{
@@ -166,64 +187,10 @@ func handleCognitoCallback(w http.ResponseWriter, r *http.Request) {
 		SameSite: http.SameSiteStrictMode,
 	})
 
+	logins.WithLabelValues("web").Inc()
 	http.Redirect(w, r, frontendURL, http.StatusFound)
 }
 
-func verifyIDToken(idToken string) (map[string]any, error) {
-	jwksURL := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s/.well-known/jwks.json",
-		getEnv("AWS_REGION", "us-east-1"),
-		userPoolID)
-
-	token, _, err := new(jwt.Parser).ParseUnverified(idToken, jwt.MapClaims{})
-	if err != nil {
-		return nil, fmt.Errorf("error parsing token: %v", err)
-	}
-
-	kid, ok := token.Header["kid"].(string)
-	if !ok {
-		return nil, fmt.Errorf("kid not found in token header")
-	}
-
-	key, err := getPublicKeyFromJWKS(jwksURL, kid)
-	if err != nil {
-		return nil, fmt.Errorf("error getting public key: %v", err)
-	}
-
-	// Verify the token
-	token, err = jwt.Parse(idToken, func(token *jwt.Token) (any, error) {
-		// Verify signing algorithm
-		if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return key, nil
-	})
-
-	if err != nil {
-		return nil, fmt.Errorf("error verifying token: %v", err)
-	}
-
-	if claims, ok := token.Claims.(jwt.MapClaims); ok && token.Valid {
-		if err := claims.Valid(); err != nil {
-			return nil, fmt.Errorf("invalid claims: %v", err)
-		}
-
-		if !claims.VerifyAudience(clientID, true) {
-			return nil, fmt.Errorf("invalid audience")
-		}
-
-		expectedIssuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s",
-			getEnv("AWS_REGION", "us-east-1"),
-			userPoolID)
-		if !claims.VerifyIssuer(expectedIssuer, true) {
-			return nil, fmt.Errorf("invalid issuer")
-		}
-
-		return claims, nil
-	}
-
-	return nil, fmt.Errorf("invalid token")
-}
-
 // @Summary Token Refresh
 // @Description Refreshes the ID token using the refresh token stored in cookies.
 // @Tags authentication
@@ -292,3 +259,177 @@ func handleLogoutCallback(w http.ResponseWriter, r *http.Request) {
 
 	http.Redirect(w, r, frontendURL, http.StatusFound)
 }
+
+// userIDFromRequest verifies the caller's ID token and returns the Cognito subject.
+// The token comes from an "Authorization: Bearer" header or else the id_token cookie.
+func userIDFromRequest(r *http.Request) (uuid.UUID, error) {
+	idToken, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		idTokenCookie, err := r.Cookie("id_token")
+		if err != nil {
+			return uuid.Nil, err
+		}
+		idToken = idTokenCookie.Value
+	}
+
+	claims, err := tokenVerifier.VerifyID(r.Context(), idToken)
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	sub, _ := claims["sub"].(string)
+	return uuid.Parse(sub)
+}
+
+// @Summary Get Profile
+// @Description Returns the authenticated user's profile and preferences.
+// @Tags users
+// @Produce json
+// @Success 200 {object} User
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /users/me [get]
+func handleGetProfile(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var user User
+	if err := db.WithContext(r.Context()).First(&user, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
+
+// @Summary Update Profile
+// @Description Updates the authenticated user's timezone, locale, week start day, date format, daily capacity, auto-archive period and email digest schedule. Omitted fields are left unchanged; a capacity of 0 disables overload flags, an auto-archive period of 0 turns auto-archiving off and a digest frequency of NONE stops digests. Digests go out at digest_hour (0-23) in the user's timezone, weekly ones on the first day of the week.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param profile body ProfileRequest true "Profile fields to change"
+// @Success 200 {object} User
+// @Failure 400 {string} string "Invalid input"
+// @Failure 401 {string} string "Unauthorized"
+// @Failure 404 {string} string "User not found"
+// @Failure 500 {string} string "Internal server error"
+// @Router /users/me [patch]
+func handleUpdateProfile(w http.ResponseWriter, r *http.Request) {
+	userID := requestUserID(r)
+
+	var req ProfileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid input", http.StatusBadRequest)
+		return
+	}
+
+	updates := map[string]any{}
+	if req.Timezone != nil {
+		if *req.Timezone == "" || *req.Timezone == "Local" {
+			http.Error(w, "Invalid timezone", http.StatusBadRequest)
+			return
+		}
+		if _, err := time.LoadLocation(*req.Timezone); err != nil {
+			http.Error(w, "Invalid timezone", http.StatusBadRequest)
+			return
+		}
+		updates["timezone"] = *req.Timezone
+	}
+	if req.Locale != nil {
+		tag, err := language.Parse(*req.Locale)
+		if err != nil {
+			http.Error(w, "Invalid locale", http.StatusBadRequest)
+			return
+		}
+		if len(tag.String()) > maxLocaleLength {
+			http.Error(w, fmt.Sprintf("Locale is longer than %d characters", maxLocaleLength), http.StatusBadRequest)
+			return
+		}
+		updates["locale"] = tag.String()
+	}
+	if req.WeekStart != nil {
+		if *req.WeekStart < 0 || *req.WeekStart > 6 {
+			http.Error(w, "Invalid week start day", http.StatusBadRequest)
+			return
+		}
+		updates["week_start"] = *req.WeekStart
+	}
+	if req.DateFormat != nil {
+		if !validDateFormats[*req.DateFormat] {
+			http.Error(w, "Invalid date format", http.StatusBadRequest)
+			return
+		}
+		updates["date_format"] = *req.DateFormat
+	}
+	if req.DailyCapacityMinutes != nil {
+		if *req.DailyCapacityMinutes < 0 || *req.DailyCapacityMinutes > 24*60 {
+			http.Error(w, "Invalid daily capacity", http.StatusBadRequest)
+			return
+		}
+		updates["daily_capacity_minutes"] = *req.DailyCapacityMinutes
+	}
+	if req.DailyCapacityPoints != nil {
+		if *req.DailyCapacityPoints < 0 {
+			http.Error(w, "Invalid daily capacity", http.StatusBadRequest)
+			return
+		}
+		updates["daily_capacity_points"] = *req.DailyCapacityPoints
+	}
+	if req.AutoArchiveDays != nil {
+		switch {
+		case *req.AutoArchiveDays < 0 || *req.AutoArchiveDays > 3650:
+			http.Error(w, "Invalid auto-archive period", http.StatusBadRequest)
+			return
+		case *req.AutoArchiveDays == 0:
+			updates["auto_archive_days"] = nil
+		default:
+			updates["auto_archive_days"] = *req.AutoArchiveDays
+		}
+	}
+	if req.DigestFrequency != nil {
+		if !validDigestFrequencies[*req.DigestFrequency] {
+			http.Error(w, "Invalid digest frequency", http.StatusBadRequest)
+			return
+		}
+		updates["digest_frequency"] = *req.DigestFrequency
+	}
+	if req.DigestHour != nil {
+		if *req.DigestHour < 0 || *req.DigestHour > 23 {
+			http.Error(w, "Invalid digest hour", http.StatusBadRequest)
+			return
+		}
+		updates["digest_hour"] = *req.DigestHour
+	}
+
+	var user User
+	if err := db.WithContext(r.Context()).First(&user, "user_id = ?", userID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			http.Error(w, "User not found", http.StatusNotFound)
+		} else {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	if len(updates) > 0 {
+		if err := db.WithContext(r.Context()).Model(&user).Updates(updates).Error; err != nil {
+			logging.RequestLogger(r).Error("Couldn't update profile", "err", err)
+			http.Error(w, "Failed to update profile", http.StatusInternalServerError)
+			return
+		}
+		if err := db.WithContext(r.Context()).First(&user, "user_id = ?", userID).Error; err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(user)
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"auth"
+
+	"github.com/golang-jwt/jwt"
+	"github.com/google/uuid"
+)
+
+const testKeyID = "test-key"
+
+// signingKey signs the ID tokens the tests present. Its public half is put in
+// the verifier's key set so it never reaches out to Cognito.
+var signingKey = func() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}()
+
+var (
+	ana = uuid.MustParse("6f1c2a9e-6d3b-4c55-9a43-2f4f0e0b8a11")
+	bob = uuid.MustParse("0d8e7c1b-3a4f-4e2d-8b6a-9c5d4e3f2a10")
+)
+
+// verifyTokens trusts tokens signed with signingKey for the rest of the test.
+func verifyTokens(t *testing.T) {
+	t.Helper()
+
+	savedVerifier, savedClientID, savedPoolID := tokenVerifier, clientID, userPoolID
+	t.Cleanup(func() { tokenVerifier, clientID, userPoolID = savedVerifier, savedClientID, savedPoolID })
+
+	clientID = "tasknest-web"
+	userPoolID = "us-east-1_TEST"
+	tokenVerifier = auth.NewVerifier(getEnv("AWS_REGION", "us-east-1"), userPoolID, clientID, http.DefaultClient)
+	tokenVerifier.Keys.Set(map[string]*rsa.PublicKey{testKeyID: &signingKey.PublicKey})
+}
+
+func issuer() string {
+	return auth.Issuer(getEnv("AWS_REGION", "us-east-1"), userPoolID)
+}
+
+// idToken signs an ID token for the user, with claims overriding the defaults.
+func idToken(t *testing.T, userID uuid.UUID, email string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	all := jwt.MapClaims{
+		"sub":   userID.String(),
+		"email": email,
+		"aud":   clientID,
+		"iss":   issuer(),
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Hour).Unix(),
+	}
+	for k, v := range claims {
+		all[k] = v
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, all)
+	token.Header["kid"] = testKeyID
+	signed, err := token.SignedString(signingKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed
+}
+
+func session(token string) *http.Cookie {
+	return &http.Cookie{Name: "id_token", Value: token}
+}
+
+func decode[T any](t *testing.T, rec *httptest.ResponseRecorder) T {
+	t.Helper()
+	var v T
+	if err := json.NewDecoder(rec.Body).Decode(&v); err != nil {
+		t.Fatalf("couldn't decode %q: %v", rec.Body.String(), err)
+	}
+	return v
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if rec.Code != status {
+		t.Errorf("status = %d, want %d (body %q)", rec.Code, status, rec.Body.String())
+	}
+}
+
+func TestUpdateProfileLocale(t *testing.T) {
+	verifyTokens(t)
+	h := requireUser(http.HandlerFunc(handleUpdateProfile))
+
+	// Rejected before the database is touched
+	for locale, want := range map[string]string{
+		"not a locale!": "Invalid locale",
+		"en-US-u-ca-gregory-nu-latn-co-phonebk-hc-h23": "Locale is longer than 35 characters",
+	} {
+		body, _ := json.Marshal(ProfileRequest{Locale: &locale})
+		req := httptest.NewRequest("PATCH", "/api/users/me", bytes.NewReader(body))
+		req.AddCookie(session(idToken(t, ana, "ana@example.com", nil)))
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		expectStatus(t, rec, http.StatusBadRequest)
+		if got := strings.TrimSpace(rec.Body.String()); got != want {
+			t.Errorf("locale %q: body = %q, want %q", locale, got, want)
+		}
+	}
+}
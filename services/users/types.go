@@ -4,3 +4,43 @@ type DBCreds struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
+
+type ProfileRequest struct {
+	Timezone   *string `json:"timezone"`
+	Locale     *string `json:"locale"`
+	WeekStart  *int    `json:"week_start"`
+	DateFormat *string `json:"date_format"`
+
+	DailyCapacityMinutes *int `json:"daily_capacity_minutes"`
+	DailyCapacityPoints  *int `json:"daily_capacity_points"`
+
+	AutoArchiveDays *int `json:"auto_archive_days"`
+
+	DigestFrequency *string `json:"digest_frequency"`
+	DigestHour      *int    `json:"digest_hour"`
+}
+
+type DeviceCodeResponse struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	ExpiresIn               int    `json:"expires_in"`
+	Interval                int    `json:"interval"`
+}
+
+type DeviceTokenRequest struct {
+	DeviceCode string `json:"device_code"`
+}
+
+type DeviceTokenResponse struct {
+	IDToken      string `json:"id_token"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// DeviceTokenError follows RFC 8628: authorization_pending, slow_down,
+// access_denied, expired_token or invalid_grant.
+type DeviceTokenError struct {
+	Error string `json:"error"`
+}